@@ -0,0 +1,81 @@
+package models
+
+// Category is a PCPartPicker product category, as used in /products/<category>/ URLs.
+type Category string
+
+const (
+	CategoryCPU               Category = "cpu"
+	CategoryCPUCooler         Category = "cpu-cooler"
+	CategoryMotherboard       Category = "motherboard"
+	CategoryMemory            Category = "memory"
+	CategoryInternalHardDrive Category = "internal-hard-drive"
+	CategoryVideoCard         Category = "video-card"
+	CategoryCase              Category = "case"
+	CategoryPowerSupply       Category = "power-supply"
+	CategoryOperatingSystem   Category = "os"
+	CategoryMonitor           Category = "monitor"
+	CategoryCaseFan           Category = "case-fan"
+	CategoryThermalPaste      Category = "thermal-paste"
+)
+
+var categories = map[Category]bool{
+	CategoryCPU:               true,
+	CategoryCPUCooler:         true,
+	CategoryMotherboard:       true,
+	CategoryMemory:            true,
+	CategoryInternalHardDrive: true,
+	CategoryVideoCard:         true,
+	CategoryCase:              true,
+	CategoryPowerSupply:       true,
+	CategoryOperatingSystem:   true,
+	CategoryMonitor:           true,
+	CategoryCaseFan:           true,
+	CategoryThermalPaste:      true,
+}
+
+// Valid reports whether the category is a known PCPartPicker category.
+func (c Category) Valid() bool {
+	return categories[c]
+}
+
+// CategorySort is a sort order supported by PCPartPicker product listings.
+type CategorySort string
+
+const (
+	SortDefault    CategorySort = ""
+	SortPrice      CategorySort = "price"
+	SortPriceDesc  CategorySort = "-price"
+	SortName       CategorySort = "name"
+	SortNameDesc   CategorySort = "-name"
+	SortRating     CategorySort = "rating"
+	SortRatingDesc CategorySort = "-rating"
+)
+
+// CategoryFilter holds the filters applied to a product listing.
+// Manufacturers and Chipsets take PCPartPicker's filter IDs, prices are in the
+// region currency and a zero MaxPrice means no upper bound. Extra is passed as is,
+// keyed by PCPartPicker's own filter names.
+type CategoryFilter struct {
+	Manufacturers []string            `json:"manufacturers"`
+	Chipsets      []string            `json:"chipsets"`
+	MinPrice      float64             `json:"minPrice"`
+	MaxPrice      float64             `json:"maxPrice"`
+	Sort          CategorySort        `json:"sort"`
+	Extra         map[string][]string `json:"extra"`
+}
+
+type CategoryPart struct {
+	Name   string
+	Image  string
+	URL    string
+	Vendor Vendor
+	Specs  []PartSpec
+}
+
+type CategoryResults struct {
+	Category Category
+	Parts    []CategoryPart
+	Total    uint
+	Page     uint
+	HasMore  bool
+}
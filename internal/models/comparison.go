@@ -0,0 +1,38 @@
+package models
+
+// RegionPrice is the offer of a product on one PCPartPicker region. Vendor is
+// the cheapest in-stock vendor and is empty when no vendor has the product in stock.
+type RegionPrice struct {
+	Region  string
+	URL     string
+	InStock bool
+	Vendor  Vendor
+}
+
+// RegionError is a region whose product page couldn't be scraped. Code is the
+// machine-readable kind of the failure.
+type RegionError struct {
+	Region string
+	Code   string
+	Error  string
+}
+
+// RegionComparison compares the offers of a product across regions. Currency is
+// the ISO 4217 currency prices were normalized to, and Cheapest the region with
+// the lowest normalized total. Both are empty when prices are left in the
+// currency of their region. Unconverted lists the regions left in their own
+// currency for lack of an exchange rate. Missing lists the regions without the
+// product, Unavailable the regions that couldn't be fetched and Errors the
+// regions whose page couldn't be parsed.
+type RegionComparison struct {
+	ID          string
+	Name        string
+	Type        string
+	Currency    string
+	Cheapest    string
+	Regions     []RegionPrice
+	Unconverted []string
+	Missing     []string
+	Unavailable []string
+	Errors      []RegionError
+}
@@ -15,6 +15,13 @@ type SearchPart struct {
 	Vendor Vendor
 }
 
+type SearchResults struct {
+	Parts   []SearchPart
+	Total   uint
+	Page    uint
+	HasMore bool
+}
+
 type RatingStats struct {
 	Stars   uint
 	Count   uint
@@ -27,21 +34,53 @@ type PartSpec struct {
 }
 
 type Part struct {
-	Type    string
-	Name    string
-	Images  []string
-	URL     string
-	Vendors []Vendor
-	Specs   []PartSpec
-	Rating  RatingStats
+	Type       string
+	Name       string
+	Images     []string
+	URL        string
+	Vendors    []Vendor
+	Specs      []PartSpec
+	TypedSpecs TypedSpecs
+	Rating     RatingStats
 }
 
 type ListPart struct {
-	Type   string
-	Name   string
-	Image  string
-	URL    string
-	Vendor Vendor
+	Type     string
+	Name     string
+	Image    string
+	URL      string
+	Vendor   Vendor
+	Quantity int
+	Custom   bool
+}
+
+// PriceOverride replaces the vendor and price PCPartPicker picked for a part of a list.
+type PriceOverride struct {
+	Vendor string
+	Price  float64
+}
+
+// CustomPart describes a part PCPartPicker doesn't know about.
+type CustomPart struct {
+	Type string
+	Name string
+	URL  string
+}
+
+// ListItem is an item of a part list to build: the product at URL, or a custom
+// part when URL is empty, added Quantity times.
+type ListItem struct {
+	URL      string
+	Quantity int
+	Override *PriceOverride
+	Custom   *CustomPart
+}
+
+// SavedList is a part list saved to a PCPartPicker account.
+type SavedList struct {
+	ID   string
+	Name string
+	URL  string
 }
 
 type CompatibilityInfo struct {
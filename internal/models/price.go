@@ -1,60 +1,413 @@
 package models
 
 import (
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
+// Price is the price of a part in major units of CurrencyCode. Minor holds the
+// same amounts in minor units, which are exact where the floats may round.
 type Price struct {
-	Base        float64
-	Shipping    float64
-	Tax         float64
-	Discounts   float64
-	Total       float64
-	Currency    string
-	TotalString string
+	Base         float64
+	Shipping     float64
+	Tax          float64
+	Discounts    float64
+	Total        float64
+	Currency     string
+	CurrencyCode string
+	TotalString  string
+	Minor        MinorPrice
 }
 
+// MinorPrice is a price in minor units of its currency (cents for USD, forints
+// for HUF), as given by CurrencyExponent.
+type MinorPrice struct {
+	Base      int64
+	Shipping  int64
+	Tax       int64
+	Discounts int64
+	Total     int64
+}
+
+// PriceKind tells apart the different forms a scraped price cell can take.
+type PriceKind string
+
+const (
+	PriceKindEmpty       PriceKind = "empty"
+	PriceKindAmount      PriceKind = "amount"
+	PriceKindDiscount    PriceKind = "discount"
+	PriceKindFree        PriceKind = "free"
+	PriceKindUnavailable PriceKind = "unavailable"
+)
+
+// ParsedPrice is a price parsed from its text. Amount is in minor units of the
+// ISO 4217 Currency (cents for USD, forints for HUF) and is always positive,
+// discounts included.
+type ParsedPrice struct {
+	Amount   int64
+	Currency string
+	Symbol   string
+	Kind     PriceKind
+}
+
+// Float returns the amount in major units.
+func (p ParsedPrice) Float() float64 {
+	return float64(p.Amount) / math.Pow10(CurrencyExponent(p.Currency))
+}
+
+// numberFormat is the way a region writes numbers. The first of groups is the
+// group separator used when formatting.
+type numberFormat struct {
+	currency    string
+	decimal     rune
+	groups      string
+	symbolAfter bool
+}
+
+const (
+	nbsp       = "\u00a0"
+	narrowNbsp = "\u202f"
+	spaces     = " " + nbsp + narrowNbsp
+)
+
+func pointDecimal(currency string) numberFormat {
+	return numberFormat{currency: currency, decimal: '.', groups: "," + spaces}
+}
+
+func commaDecimal(currency string) numberFormat {
+	return numberFormat{currency: currency, decimal: ',', groups: "." + spaces + "'", symbolAfter: true}
+}
+
+var (
+	// regionFormats holds the number format and currency of each PCPartPicker region.
+	regionFormats = map[string]numberFormat{
+		"us": pointDecimal("USD"),
+		"ca": pointDecimal("CAD"),
+		"au": pointDecimal("AUD"),
+		"nz": pointDecimal("NZD"),
+		"uk": pointDecimal("GBP"),
+		"ie": pointDecimal("EUR"),
+		"in": pointDecimal("INR"),
+		"sa": pointDecimal("SAR"),
+		"de": commaDecimal("EUR"),
+		"at": commaDecimal("EUR"),
+		"be": commaDecimal("EUR"),
+		"es": commaDecimal("EUR"),
+		"fi": commaDecimal("EUR"),
+		"fr": commaDecimal("EUR"),
+		"it": commaDecimal("EUR"),
+		"nl": commaDecimal("EUR"),
+		"pt": commaDecimal("EUR"),
+		"sk": commaDecimal("EUR"),
+		"se": commaDecimal("SEK"),
+		"no": commaDecimal("NOK"),
+		"dk": commaDecimal("DKK"),
+		"cz": commaDecimal("CZK"),
+		"hu": commaDecimal("HUF"),
+		"ro": commaDecimal("RON"),
+		"ar": commaDecimal("ARS"),
+	}
+
+	// currencySymbols maps the symbols that identify a single currency.
+	currencySymbols = map[string]string{
+		"€":   "EUR",
+		"£":   "GBP",
+		"₹":   "INR",
+		"rs":  "INR",
+		"kč":  "CZK",
+		"ft":  "HUF",
+		"lei": "RON",
+		"zł":  "PLN",
+		"us$": "USD",
+		"ca$": "CAD",
+		"c$":  "CAD",
+		"a$":  "AUD",
+		"au$": "AUD",
+		"nz$": "NZD",
+		"sr":  "SAR",
+	}
+
+	// currencyExponents holds the number of decimals of the currencies not written
+	// with two. They follow ISO 4217, except for HUF and CZK whose minor units are
+	// no longer in use: their prices are written without decimals.
+	currencyExponents = map[string]int{
+		"HUF": 0, "CZK": 0, "JPY": 0, "KRW": 0, "ISK": 0, "CLP": 0, "VND": 0,
+		"KWD": 3, "BHD": 3, "OMR": 3, "JOD": 3, "TND": 3,
+	}
+
+	currencyCodes = map[string]bool{
+		"USD": true, "CAD": true, "AUD": true, "NZD": true, "GBP": true, "EUR": true, "INR": true,
+		"SAR": true, "SEK": true, "NOK": true, "DKK": true, "CZK": true, "HUF": true, "RON": true,
+		"ARS": true, "PLN": true, "CHF": true,
+	}
+)
+
+// RegionCurrency returns the ISO 4217 currency of a PCPartPicker region, or an
+// empty string if the region is unknown.
+func RegionCurrency(region string) string {
+	return regionFormats[region].currency
+}
+
+// CurrencyExponent returns the number of decimals amounts of an ISO 4217
+// currency are written with, so that minor units are a tenth to that power of
+// a major unit. Unknown currencies use two decimals.
+func CurrencyExponent(code string) int {
+	if exponent, ok := currencyExponents[code]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// ParsePrice parses a price without knowing its region and returns its amount and currency symbol.
+// Use ParseRegionPrice when the region is known.
 func ParsePrice(price string) (float64, string, error) {
+	parsed, err := ParseRegionPrice(price, "")
+	if err != nil {
+		return 0, "", err
+	}
+	return parsed.Float(), parsed.Symbol, nil
+}
+
+// ParseRegionPrice parses a price as written on the given PCPartPicker region, such as
+// "$1,299.99" on us or "1.299,99 €" on de. "FREE", "No Prices Available" and discounts
+// ("-$10.00", "$10.00 off") are reported through the kind of the parsed price.
+// When the region is unknown, or the price does not follow the region format, the
+// decimal separator is guessed from the digits following the last separator.
+func ParseRegionPrice(price string, region string) (ParsedPrice, error) {
+	parsed := ParsedPrice{Kind: PriceKindAmount}
 	price = strings.TrimSpace(price)
+	lower := strings.ToLower(price)
 
-	if price == "" {
-		return 0, "", nil
+	switch {
+	case price == "":
+		parsed.Kind = PriceKindEmpty
+		return parsed, nil
+	case strings.Contains(lower, "no prices available"):
+		parsed.Kind = PriceKindUnavailable
+		return parsed, nil
+	case lower == "free" || !strings.ContainsFunc(price, unicode.IsDigit) && strings.Contains(lower, "free"):
+		parsed.Kind = PriceKindFree
+		parsed.Currency = RegionCurrency(region)
+		return parsed, nil
 	}
 
-	currency, number := "", ""
+	if strings.HasPrefix(price, "-") || strings.HasPrefix(price, "−") || strings.HasSuffix(lower, " off") || strings.HasPrefix(lower, "save ") {
+		parsed.Kind = PriceKindDiscount
+	}
+	for _, word := range []string{"save ", " off"} {
+		lower = strings.Replace(lower, word, " ", 1)
+	}
 
-	for _, char := range price {
-		currency, number = processCharacter(char, currency, number)
+	number, symbol, code := splitPrice(lower)
+	if number == "" {
+		return ParsedPrice{}, fmt.Errorf("no amount in price %q", price)
 	}
+	parsed.Currency = currencyCode(code, symbol, region)
+	exponent := CurrencyExponent(parsed.Currency)
 
-	float, err := strconv.ParseFloat(number, 64)
+	format, known := regionFormats[region]
+	amount, ok := int64(0), false
+	if known {
+		amount, ok = parseAmount(number, format.decimal, format.groups, exponent)
+	}
+	if !ok {
+		amount, ok = guessAmount(number, exponent)
+	}
+	if !ok {
+		return ParsedPrice{}, fmt.Errorf("invalid amount in price %q", price)
+	}
 
+	parsed.Amount = amount
+	parsed.Symbol = symbolCase(price, symbol)
+	return parsed, nil
+}
+
+// FormatRegionPrice writes an amount in minor units with its currency symbol as
+// prices are written on the given PCPartPicker region, such as "$1,299.99" on us,
+// "1.299,99 €" on de or "32.990 Kč" on cz. Unknown regions use the format of us.
+func FormatRegionPrice(amount int64, symbol string, region string) string {
+	format, known := regionFormats[region]
+	if !known {
+		format = regionFormats["us"]
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	exponent := CurrencyExponent(format.currency)
+	scale := int64(math.Pow10(exponent))
+	integer := strconv.FormatInt(amount/scale, 10)
+	var number strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			number.WriteByte(format.groups[0])
+		}
+		number.WriteRune(digit)
+	}
+	if exponent > 0 {
+		number.WriteRune(format.decimal)
+		fmt.Fprintf(&number, "%0*d", exponent, amount%scale)
+	}
+
+	switch {
+	case symbol == "":
+		return sign + number.String()
+	case format.symbolAfter:
+		return sign + number.String() + " " + symbol
+	default:
+		return sign + symbol + number.String()
+	}
+}
+
+// splitPrice separates the number of a lower cased price from its currency symbol and ISO code.
+func splitPrice(price string) (string, string, string) {
+	var number, symbol strings.Builder
+	code := ""
+
+	for _, field := range strings.FieldsFunc(price, func(r rune) bool { return unicode.IsDigit(r) || strings.ContainsRune(".,'+-−"+spaces, r) }) {
+		if upper := strings.ToUpper(field); currencyCodes[upper] {
+			code = upper
+		} else {
+			symbol.WriteString(field)
+		}
+	}
+
+	first := strings.IndexFunc(price, unicode.IsDigit)
+	last := strings.LastIndexFunc(price, unicode.IsDigit)
+	if first >= 0 {
+		number.WriteString(price[first : last+1])
+	}
+	return number.String(), symbol.String(), code
+}
+
+// parseAmount parses a number into minor units of a currency with the given
+// exponent, using the given decimal separator and group separators. It rejects
+// numbers with unexpected characters or more decimals than the exponent, up to
+// two zero decimals being allowed ("499 990,00 Ft").
+func parseAmount(number string, decimal rune, groups string, exponent int) (int64, bool) {
+	integer, fraction := number, ""
+	if i := strings.LastIndexFunc(number, func(r rune) bool { return r == decimal }); i >= 0 {
+		integer, fraction = number[:i], number[i+utf8.RuneLen(decimal):]
+		if strings.ContainsRune(integer, decimal) {
+			return 0, false
+		}
+	}
+
+	integer = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(groups, r) {
+			return -1
+		}
+		return r
+	}, integer)
+
+	if integer == "" {
+		integer = "0"
+	}
+	if len(fraction) > max(exponent, 2) || !isDigits(integer) || !isDigits(fraction) {
+		return 0, false
+	}
+	if len(fraction) > exponent {
+		if strings.Trim(fraction[exponent:], "0") != "" {
+			return 0, false
+		}
+		fraction = fraction[:exponent]
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	amount, err := strconv.ParseInt(integer+fraction, 10, 64)
 	if err != nil {
-		return 0, "", err
+		return 0, false
+	}
+	return amount, true
+}
+
+// guessAmount parses a number whose format is unknown: the last separator is the
+// decimal separator when it is followed by one or two digits, or by as many
+// digits as the exponent.
+func guessAmount(number string, exponent int) (int64, bool) {
+	i := strings.LastIndexAny(number, ".,")
+	if i >= 0 && len(number)-i-1 <= max(exponent, 2) {
+		return parseAmount(number, rune(number[i]), ".,'"+spaces, exponent)
+	}
+	return parseAmount(number, 0, ".,'"+spaces, exponent)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// symbolCase returns the lower cased symbol as written in the original price.
+// The price is matched rune by rune, lower casing changing the length of some.
+func symbolCase(price string, symbol string) string {
+	if symbol == "" {
+		return symbol
+	}
+	for i := range price {
+		if n, ok := lowerPrefix(price[i:], symbol); ok {
+			return price[i : i+n]
+		}
 	}
+	return symbol
+}
 
-	return float, currency, nil
+// lowerPrefix returns the length of the prefix of s reading lower as lower
+// cased, if s starts with one.
+func lowerPrefix(s string, lower string) (int, bool) {
+	n := 0
+	for _, want := range lower {
+		got, size := utf8.DecodeRuneInString(s[n:])
+		if size == 0 || unicode.ToLower(got) != want {
+			return 0, false
+		}
+		n += size
+	}
+	return n, true
 }
 
-func processCharacter(char rune, currency, number string) (string, string) {
-	if isSpaceOrPlus(char) {
-		return currency, number
-	} else if isSeparatorChar(char) {
-		number += "."
-	} else if unicode.IsDigit(char) {
-		number += string(char)
-	} else {
-		currency += string(char)
+// currencyCode picks the ISO 4217 code of a price: an explicit code first, then an
+// unambiguous symbol and finally the currency of the region.
+func currencyCode(code string, symbol string, region string) string {
+	if code != "" {
+		return code
 	}
-	return currency, number
+	if currency, ok := currencySymbols[symbol]; ok {
+		return currency
+	}
+	if currency := RegionCurrency(region); currency != "" {
+		return currency
+	}
+	if symbol == "$" {
+		return "USD"
+	}
+	return ""
+}
+
+type PricePoint struct {
+	Time  time.Time
+	Price Price
 }
 
-func isSeparatorChar(char rune) bool {
-	return char == '.' || char == ','
+type VendorPriceHistory struct {
+	Vendor string
+	Points []PricePoint
+	Lowest PricePoint
 }
 
-func isSpaceOrPlus(char rune) bool {
-	return char == ' ' || char == '+'
+type PriceHistory struct {
+	URL          string
+	Region       string
+	Vendors      []VendorPriceHistory
+	Lowest       PricePoint
+	LowestVendor string
 }
@@ -0,0 +1,134 @@
+package models
+
+import "testing"
+
+func TestParseRegionPrice(t *testing.T) {
+	tests := []struct {
+		region string
+		price  string
+		want   ParsedPrice
+	}{
+		{"us", "$1,299.99", ParsedPrice{Amount: 129999, Currency: "USD", Symbol: "$", Kind: PriceKindAmount}},
+		{"us", "$449.00", ParsedPrice{Amount: 44900, Currency: "USD", Symbol: "$", Kind: PriceKindAmount}},
+		{"us", "+$4.99", ParsedPrice{Amount: 499, Currency: "USD", Symbol: "$", Kind: PriceKindAmount}},
+		{"us", "-$10.00", ParsedPrice{Amount: 1000, Currency: "USD", Symbol: "$", Kind: PriceKindDiscount}},
+		{"us", "$10.00 off", ParsedPrice{Amount: 1000, Currency: "USD", Symbol: "$", Kind: PriceKindDiscount}},
+		{"us", "FREE", ParsedPrice{Currency: "USD", Kind: PriceKindFree}},
+		{"us", "No Prices Available", ParsedPrice{Kind: PriceKindUnavailable}},
+		{"us", "", ParsedPrice{Kind: PriceKindEmpty}},
+		{"ca", "$1,049.99", ParsedPrice{Amount: 104999, Currency: "CAD", Symbol: "$", Kind: PriceKindAmount}},
+		{"au", "$2,199.00", ParsedPrice{Amount: 219900, Currency: "AUD", Symbol: "$", Kind: PriceKindAmount}},
+		{"nz", "$2,399.00", ParsedPrice{Amount: 239900, Currency: "NZD", Symbol: "$", Kind: PriceKindAmount}},
+		{"uk", "£1,099.98", ParsedPrice{Amount: 109998, Currency: "GBP", Symbol: "£", Kind: PriceKindAmount}},
+		{"ie", "€1,199.00", ParsedPrice{Amount: 119900, Currency: "EUR", Symbol: "€", Kind: PriceKindAmount}},
+		{"in", "₹1,29,999.00", ParsedPrice{Amount: 12999900, Currency: "INR", Symbol: "₹", Kind: PriceKindAmount}},
+		{"de", "1.299,99 €", ParsedPrice{Amount: 129999, Currency: "EUR", Symbol: "€", Kind: PriceKindAmount}},
+		{"de", "-10,00 €", ParsedPrice{Amount: 1000, Currency: "EUR", Symbol: "€", Kind: PriceKindDiscount}},
+		{"at", "€ 1.299,99", ParsedPrice{Amount: 129999, Currency: "EUR", Symbol: "€", Kind: PriceKindAmount}},
+		{"be", "€1.299,99", ParsedPrice{Amount: 129999, Currency: "EUR", Symbol: "€", Kind: PriceKindAmount}},
+		{"es", "1.299,99 €", ParsedPrice{Amount: 129999, Currency: "EUR", Symbol: "€", Kind: PriceKindAmount}},
+		{"it", "€1.299,99", ParsedPrice{Amount: 129999, Currency: "EUR", Symbol: "€", Kind: PriceKindAmount}},
+		{"nl", "€ 1.299,99", ParsedPrice{Amount: 129999, Currency: "EUR", Symbol: "€", Kind: PriceKindAmount}},
+		{"fr", "1 299,99 €", ParsedPrice{Amount: 129999, Currency: "EUR", Symbol: "€", Kind: PriceKindAmount}},
+		{"fr", "1 299,99 €", ParsedPrice{Amount: 129999, Currency: "EUR", Symbol: "€", Kind: PriceKindAmount}},
+		{"fi", "1 299,99 €", ParsedPrice{Amount: 129999, Currency: "EUR", Symbol: "€", Kind: PriceKindAmount}},
+		{"pt", "1 299,99 €", ParsedPrice{Amount: 129999, Currency: "EUR", Symbol: "€", Kind: PriceKindAmount}},
+		{"sk", "1 299,99 €", ParsedPrice{Amount: 129999, Currency: "EUR", Symbol: "€", Kind: PriceKindAmount}},
+		{"se", "12 990 kr", ParsedPrice{Amount: 1299000, Currency: "SEK", Symbol: "kr", Kind: PriceKindAmount}},
+		{"se", "1 299,50 kr", ParsedPrice{Amount: 129950, Currency: "SEK", Symbol: "kr", Kind: PriceKindAmount}},
+		{"no", "kr 12 990,00", ParsedPrice{Amount: 1299000, Currency: "NOK", Symbol: "kr", Kind: PriceKindAmount}},
+		{"dk", "9.999,00 kr.", ParsedPrice{Amount: 999900, Currency: "DKK", Symbol: "kr", Kind: PriceKindAmount}},
+		{"cz", "32 990 Kč", ParsedPrice{Amount: 32990, Currency: "CZK", Symbol: "Kč", Kind: PriceKindAmount}},
+		{"cz", "32.990 KČ", ParsedPrice{Amount: 32990, Currency: "CZK", Symbol: "KČ", Kind: PriceKindAmount}},
+		{"hu", "499 990 Ft", ParsedPrice{Amount: 499990, Currency: "HUF", Symbol: "Ft", Kind: PriceKindAmount}},
+		{"hu", "499 990,00 Ft", ParsedPrice{Amount: 499990, Currency: "HUF", Symbol: "Ft", Kind: PriceKindAmount}},
+		{"", "499.990 Ft", ParsedPrice{Amount: 499990, Currency: "HUF", Symbol: "Ft", Kind: PriceKindAmount}},
+		{"ro", "5.999,99 lei", ParsedPrice{Amount: 599999, Currency: "RON", Symbol: "lei", Kind: PriceKindAmount}},
+		{"ar", "$1.299.999,00", ParsedPrice{Amount: 129999900, Currency: "ARS", Symbol: "$", Kind: PriceKindAmount}},
+		{"sa", "SAR 4,299.00", ParsedPrice{Amount: 429900, Currency: "SAR", Symbol: "", Kind: PriceKindAmount}},
+		{"de", "USD 1,299.99", ParsedPrice{Amount: 129999, Currency: "USD", Symbol: "", Kind: PriceKindAmount}},
+		{"", "1.299,99 €", ParsedPrice{Amount: 129999, Currency: "EUR", Symbol: "€", Kind: PriceKindAmount}},
+		{"", "$1,299", ParsedPrice{Amount: 129900, Currency: "USD", Symbol: "$", Kind: PriceKindAmount}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region+"/"+tt.price, func(t *testing.T) {
+			got, err := ParseRegionPrice(tt.price, tt.region)
+			if err != nil {
+				t.Fatalf("ParseRegionPrice(%q, %q) returned error: %v", tt.price, tt.region, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRegionPrice(%q, %q) = %+v, want %+v", tt.price, tt.region, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRegionPriceErrors(t *testing.T) {
+	for _, price := range []string{"$", "Call for price", "1.2.3,4,5"} {
+		if got, err := ParseRegionPrice(price, "us"); err == nil {
+			t.Errorf("ParseRegionPrice(%q) = %+v, expected an error", price, got)
+		}
+	}
+	// Forints have no decimals
+	if got, err := ParseRegionPrice("499 990,50 Ft", "hu"); err == nil {
+		t.Errorf("ParseRegionPrice(%q) = %+v, expected an error", "499 990,50 Ft", got)
+	}
+}
+
+func TestParsedPriceFloat(t *testing.T) {
+	for _, test := range []struct {
+		price ParsedPrice
+		want  float64
+	}{
+		{ParsedPrice{Amount: 129999, Currency: "USD"}, 1299.99},
+		{ParsedPrice{Amount: 499990, Currency: "HUF"}, 499990},
+		{ParsedPrice{Amount: 1250, Currency: "KWD"}, 1.25},
+		{ParsedPrice{Amount: 1250}, 12.5},
+	} {
+		if got := test.price.Float(); got != test.want {
+			t.Errorf("%+v.Float() = %v, want %v", test.price, got, test.want)
+		}
+	}
+}
+
+func TestSymbolCaseMatchesTheOriginalRunes(t *testing.T) {
+	// Lower casing shortens İ, which shifted the symbol in the original price
+	if got := symbolCase("İ 10 KČ", "kč"); got != "KČ" {
+		t.Errorf("symbolCase returned %q, want %q", got, "KČ")
+	}
+	if got := symbolCase("10 €", "kč"); got != "kč" {
+		t.Errorf("expected a symbol missing from the price to be kept, got %q", got)
+	}
+}
+
+func TestFormatRegionPrice(t *testing.T) {
+	tests := []struct {
+		amount int64
+		symbol string
+		region string
+		want   string
+	}{
+		{129999, "$", "us", "$1,299.99"},
+		{5, "£", "uk", "£0.05"},
+		{123456789, "$", "ca", "$1,234,567.89"},
+		{129999, "€", "de", "1.299,99 €"},
+		{99900, "kr", "se", "999,00 kr"},
+		{-1000, "€", "fr", "-10,00 €"},
+		{4490, "", "us", "44.90"},
+		{4490, "$", "", "$44.90"},
+		{32990, "Kč", "cz", "32.990 Kč"},
+		{499990, "Ft", "hu", "499.990 Ft"},
+	}
+
+	for _, test := range tests {
+		got := FormatRegionPrice(test.amount, test.symbol, test.region)
+		if got != test.want {
+			t.Errorf("FormatRegionPrice(%d, %q, %q) = %q, want %q", test.amount, test.symbol, test.region, got, test.want)
+			continue
+		}
+		if parsed, err := ParseRegionPrice(got, test.region); err != nil || parsed.Amount != max(test.amount, -test.amount) {
+			t.Errorf("ParseRegionPrice(%q, %q) = %+v, %v", got, test.region, parsed, err)
+		}
+	}
+}
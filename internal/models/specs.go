@@ -0,0 +1,432 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Quantity is a spec value made of a number and its unit, such as "3.6 GHz" or "650 W".
+// Raw keeps the scraped text in case the value could not be parsed.
+type Quantity struct {
+	Value float64
+	Unit  string
+	Raw   string
+}
+
+// Valid reports whether the quantity was parsed from its raw text.
+func (q Quantity) Valid() bool {
+	return q.Unit != "" || q.Value != 0
+}
+
+type Socket string
+
+type FormFactor string
+
+const (
+	FormFactorEATX     FormFactor = "EATX"
+	FormFactorATX      FormFactor = "ATX"
+	FormFactorMicroATX FormFactor = "Micro ATX"
+	FormFactorMiniITX  FormFactor = "Mini ITX"
+	FormFactorMiniDTX  FormFactor = "Mini DTX"
+	FormFactorSFX      FormFactor = "SFX"
+	FormFactorSFXL     FormFactor = "SFX-L"
+	FormFactorTFX      FormFactor = "TFX"
+)
+
+var formFactors = map[string]FormFactor{
+	"eatx":     FormFactorEATX,
+	"e-atx":    FormFactorEATX,
+	"atx":      FormFactorATX,
+	"microatx": FormFactorMicroATX,
+	"matx":     FormFactorMicroATX,
+	"miniitx":  FormFactorMiniITX,
+	"mini-itx": FormFactorMiniITX,
+	"itx":      FormFactorMiniITX,
+	"minidtx":  FormFactorMiniDTX,
+	"sfx":      FormFactorSFX,
+	"sfx-l":    FormFactorSFXL,
+	"tfx":      FormFactorTFX,
+}
+
+type MemoryType string
+
+const (
+	MemoryTypeDDR3 MemoryType = "DDR3"
+	MemoryTypeDDR4 MemoryType = "DDR4"
+	MemoryTypeDDR5 MemoryType = "DDR5"
+)
+
+type CPUSpecs struct {
+	Manufacturer       string
+	Series             string
+	Microarchitecture  string
+	Socket             Socket
+	CoreCount          int
+	ThreadCount        int
+	CoreClock          Quantity
+	BoostClock         Quantity
+	TDP                Quantity
+	IntegratedGraphics string
+	ECCSupport         bool
+	IncludesCooler     bool
+}
+
+type MotherboardSpecs struct {
+	Manufacturer string
+	Socket       Socket
+	FormFactor   FormFactor
+	Chipset      string
+	MemoryType   MemoryType
+	MemorySlots  int
+	MemoryMax    Quantity
+	M2Slots      int
+}
+
+type MemorySpecs struct {
+	Manufacturer string
+	MemoryType   MemoryType
+	Speed        Quantity
+	Modules      int
+	ModuleSize   Quantity
+	CASLatency   float64
+	Voltage      Quantity
+}
+
+type GPUSpecs struct {
+	Manufacturer string
+	Chipset      string
+	Memory       Quantity
+	MemoryType   string
+	CoreClock    Quantity
+	BoostClock   Quantity
+	Length       Quantity
+	TDP          Quantity
+	SlotWidth    int
+}
+
+type PSUSpecs struct {
+	Manufacturer     string
+	FormFactor       FormFactor
+	Wattage          Quantity
+	EfficiencyRating string
+	Modular          string
+	Length           Quantity
+}
+
+type CaseSpecs struct {
+	Manufacturer           string
+	Type                   string
+	MotherboardFormFactors []FormFactor
+	MaxGPULength           Quantity
+	MaxCoolerHeight        Quantity
+	IncludesPowerSupply    bool
+	ExpansionSlots         int
+}
+
+type StorageSpecs struct {
+	Manufacturer string
+	Capacity     Quantity
+	Type         string
+	SSD          bool
+	FormFactor   string
+	Interface    string
+	NVMe         bool
+}
+
+type CoolerSpecs struct {
+	Manufacturer string
+	Sockets      []Socket
+	Height       Quantity
+	WaterCooled  bool
+	RadiatorSize Quantity
+	Fanless      bool
+}
+
+// TypedSpecs is the parsed view of a part's specs. Only the field matching the
+// part category is set.
+type TypedSpecs struct {
+	CPU         *CPUSpecs
+	Motherboard *MotherboardSpecs
+	Memory      *MemorySpecs
+	GPU         *GPUSpecs
+	PSU         *PSUSpecs
+	Case        *CaseSpecs
+	Storage     *StorageSpecs
+	Cooler      *CoolerSpecs
+}
+
+// categoryTypes maps the part types shown by PCPartPicker to their category.
+var categoryTypes = map[string]Category{
+	"CPU":              CategoryCPU,
+	"CPU Cooler":       CategoryCPUCooler,
+	"Motherboard":      CategoryMotherboard,
+	"Memory":           CategoryMemory,
+	"Storage":          CategoryInternalHardDrive,
+	"Video Card":       CategoryVideoCard,
+	"Case":             CategoryCase,
+	"Power Supply":     CategoryPowerSupply,
+	"Operating System": CategoryOperatingSystem,
+	"Monitor":          CategoryMonitor,
+	"Case Fan":         CategoryCaseFan,
+	"Thermal Compound": CategoryThermalPaste,
+}
+
+// CategoryFromType returns the category of a part type such as "Video Card",
+// or an empty Category if the type is unknown.
+func CategoryFromType(partType string) Category {
+	return categoryTypes[strings.TrimSpace(partType)]
+}
+
+// specValues indexes raw specs by name.
+type specValues map[string][]string
+
+func (v specValues) first(names ...string) string {
+	for _, name := range names {
+		if values := v[name]; len(values) > 0 {
+			return strings.TrimSpace(values[0])
+		}
+	}
+	return ""
+}
+
+func (v specValues) quantity(names ...string) Quantity {
+	return ParseQuantity(v.first(names...))
+}
+
+func (v specValues) integer(names ...string) int {
+	return int(ParseQuantity(v.first(names...)).Value)
+}
+
+func (v specValues) boolean(names ...string) bool {
+	return ParseBool(v.first(names...))
+}
+
+// ParseTypedSpecs parses the raw specs of a part of the given category.
+func ParseTypedSpecs(category Category, specs []PartSpec) TypedSpecs {
+	values := specValues{}
+	for _, spec := range specs {
+		values[spec.Name] = spec.Values
+	}
+
+	var typed TypedSpecs
+
+	switch category {
+	case CategoryCPU:
+		typed.CPU = &CPUSpecs{
+			Manufacturer:       values.first("Manufacturer"),
+			Series:             values.first("Series"),
+			Microarchitecture:  values.first("Microarchitecture"),
+			Socket:             ParseSocket(values.first("Socket")),
+			CoreCount:          values.integer("Core Count"),
+			ThreadCount:        values.integer("Thread Count"),
+			CoreClock:          values.quantity("Performance Core Clock", "Core Clock"),
+			BoostClock:         values.quantity("Performance Core Boost Clock", "Boost Clock"),
+			TDP:                values.quantity("TDP"),
+			IntegratedGraphics: values.first("Integrated Graphics"),
+			ECCSupport:         values.boolean("ECC Support"),
+			IncludesCooler:     values.boolean("Includes Cooler"),
+		}
+	case CategoryMotherboard:
+		typed.Motherboard = &MotherboardSpecs{
+			Manufacturer: values.first("Manufacturer"),
+			Socket:       ParseSocket(values.first("Socket / CPU", "Socket")),
+			FormFactor:   ParseFormFactor(values.first("Form Factor")),
+			Chipset:      values.first("Chipset"),
+			MemoryType:   ParseMemoryType(values.first("Memory Type")),
+			MemorySlots:  values.integer("Memory Slots"),
+			MemoryMax:    values.quantity("Memory Max"),
+			M2Slots:      countSlots(values["M.2 Slots"]),
+		}
+	case CategoryMemory:
+		modules, moduleSize := parseModules(values.first("Modules"))
+		memoryType, speed := parseMemorySpeed(values.first("Speed"))
+		typed.Memory = &MemorySpecs{
+			Manufacturer: values.first("Manufacturer"),
+			MemoryType:   memoryType,
+			Speed:        speed,
+			Modules:      modules,
+			ModuleSize:   moduleSize,
+			CASLatency:   values.quantity("CAS Latency").Value,
+			Voltage:      values.quantity("Voltage"),
+		}
+	case CategoryVideoCard:
+		typed.GPU = &GPUSpecs{
+			Manufacturer: values.first("Manufacturer"),
+			Chipset:      values.first("Chipset"),
+			Memory:       values.quantity("Memory"),
+			MemoryType:   values.first("Memory Type"),
+			CoreClock:    values.quantity("Core Clock"),
+			BoostClock:   values.quantity("Boost Clock"),
+			Length:       values.quantity("Length"),
+			TDP:          values.quantity("TDP"),
+			SlotWidth:    values.integer("Total Slot Width", "Case Expansion Slot Width"),
+		}
+	case CategoryPowerSupply:
+		typed.PSU = &PSUSpecs{
+			Manufacturer:     values.first("Manufacturer"),
+			FormFactor:       ParseFormFactor(values.first("Type")),
+			Wattage:          values.quantity("Wattage"),
+			EfficiencyRating: values.first("Efficiency Rating"),
+			Modular:          values.first("Modular"),
+			Length:           values.quantity("Length"),
+		}
+	case CategoryCase:
+		var formFactors []FormFactor
+		for _, value := range values["Motherboard Form Factor"] {
+			formFactors = append(formFactors, ParseFormFactor(value))
+		}
+		powerSupply := values.first("Power Supply")
+		typed.Case = &CaseSpecs{
+			Manufacturer:           values.first("Manufacturer"),
+			Type:                   values.first("Type"),
+			MotherboardFormFactors: formFactors,
+			MaxGPULength:           values.quantity("Maximum Video Card Length"),
+			MaxCoolerHeight:        values.quantity("Maximum CPU Cooler Height"),
+			IncludesPowerSupply:    powerSupply != "" && powerSupply != "None",
+			ExpansionSlots:         countSlots(values["Expansion Slots"]),
+		}
+	case CategoryInternalHardDrive:
+		storageType := values.first("Type")
+		typed.Storage = &StorageSpecs{
+			Manufacturer: values.first("Manufacturer"),
+			Capacity:     values.quantity("Capacity"),
+			Type:         storageType,
+			SSD:          storageType == "SSD",
+			FormFactor:   values.first("Form Factor"),
+			Interface:    values.first("Interface"),
+			NVMe:         values.boolean("NVME"),
+		}
+	case CategoryCPUCooler:
+		var sockets []Socket
+		for _, value := range values["CPU Socket"] {
+			sockets = append(sockets, ParseSocket(value))
+		}
+		waterCooled := values.first("Water Cooled")
+		radiator := ParseQuantity(waterCooled)
+		typed.Cooler = &CoolerSpecs{
+			Manufacturer: values.first("Manufacturer"),
+			Sockets:      sockets,
+			Height:       values.quantity("Height"),
+			WaterCooled:  radiator.Valid() || ParseBool(waterCooled),
+			RadiatorSize: radiator,
+			Fanless:      values.boolean("Fanless"),
+		}
+	}
+
+	return typed
+}
+
+// PartTypedSpecs returns the typed specs of the part, parsing its raw specs when
+// they weren't parsed with the part.
+func PartTypedSpecs(part Part) TypedSpecs {
+	if part.TypedSpecs != (TypedSpecs{}) {
+		return part.TypedSpecs
+	}
+	return ParseTypedSpecs(CategoryFromType(part.Type), part.Specs)
+}
+
+// ParseQuantity parses the first number of a spec value and the unit following it.
+// "400 mm / 15.748\"" is read as 400 mm and "1,000 W" as 1000 W.
+func ParseQuantity(value string) Quantity {
+	quantity := Quantity{Raw: value}
+	value = strings.TrimSpace(value)
+
+	start := strings.IndexFunc(value, unicode.IsDigit)
+	if start < 0 {
+		return quantity
+	}
+
+	end := start
+	for end < len(value) && (unicode.IsDigit(rune(value[end])) || value[end] == '.' || value[end] == ',') {
+		end++
+	}
+
+	number, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimRight(value[start:end], ".,"), ",", ""), 64)
+	if err != nil {
+		return quantity
+	}
+
+	unit := strings.TrimSpace(value[end:])
+	if i := strings.IndexAny(unit, " /("); i >= 0 {
+		unit = unit[:i]
+	}
+
+	quantity.Value = number
+	quantity.Unit = unit
+	return quantity
+}
+
+// ParseBool parses the "Yes"/"No" values used by PCPartPicker specs.
+func ParseBool(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "true":
+		return true
+	}
+	return false
+}
+
+// ParseSocket normalizes a socket name, "LGA 1700" and "lga1700" both become "LGA1700".
+func ParseSocket(value string) Socket {
+	return Socket(strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(value), " ", "")))
+}
+
+// ParseFormFactor normalizes a motherboard, case or power supply form factor.
+// Unknown form factors are returned as is.
+func ParseFormFactor(value string) FormFactor {
+	value = strings.TrimSpace(value)
+	if formFactor, ok := formFactors[strings.ToLower(strings.ReplaceAll(value, " ", ""))]; ok {
+		return formFactor
+	}
+	return FormFactor(value)
+}
+
+// ParseMemoryType returns the memory generation at the start of a value such as "DDR5" or "DDR5-6000".
+func ParseMemoryType(value string) MemoryType {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	for _, memoryType := range []MemoryType{MemoryTypeDDR5, MemoryTypeDDR4, MemoryTypeDDR3} {
+		if strings.HasPrefix(value, string(memoryType)) {
+			return memoryType
+		}
+	}
+	return MemoryType(value)
+}
+
+// parseMemorySpeed parses a memory speed such as "DDR5-6000" into its type and transfer rate.
+func parseMemorySpeed(value string) (MemoryType, Quantity) {
+	speed := Quantity{Raw: value}
+	parts := strings.SplitN(strings.TrimSpace(value), "-", 2)
+	if len(parts) == 2 {
+		if rate := ParseQuantity(parts[1]); rate.Valid() {
+			speed.Value = rate.Value
+			speed.Unit = "MT/s"
+		}
+	}
+	return ParseMemoryType(parts[0]), speed
+}
+
+// countSlots counts the slots listed by a spec, one per value unless the value
+// starts with a count such as "7 x Full-Height".
+func countSlots(values []string) int {
+	count := 0
+	for _, value := range values {
+		slots, _, found := strings.Cut(value, "x")
+		if n, err := strconv.Atoi(strings.TrimSpace(slots)); found && err == nil {
+			count += n
+		} else if strings.TrimSpace(value) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// parseModules parses a memory kit description such as "2 x 16GB".
+func parseModules(value string) (int, Quantity) {
+	parts := strings.SplitN(value, "x", 2)
+	if len(parts) != 2 {
+		return 0, Quantity{Raw: value}
+	}
+	size := ParseQuantity(parts[1])
+	size.Raw = value
+	return int(ParseQuantity(parts[0]).Value), size
+}
@@ -0,0 +1,257 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Quantity
+	}{
+		{"3.6 GHz", Quantity{Value: 3.6, Unit: "GHz", Raw: "3.6 GHz"}},
+		{"1,000 W", Quantity{Value: 1000, Unit: "W", Raw: "1,000 W"}},
+		{`400 mm / 15.748"`, Quantity{Value: 400, Unit: "mm", Raw: `400 mm / 15.748"`}},
+		{"16GB", Quantity{Value: 16, Unit: "GB", Raw: "16GB"}},
+		{" 8 ", Quantity{Value: 8, Raw: " 8 "}},
+		{"1.35 V (XMP)", Quantity{Value: 1.35, Unit: "V", Raw: "1.35 V (XMP)"}},
+		{"240 mm", Quantity{Value: 240, Unit: "mm", Raw: "240 mm"}},
+		{"Version 2.", Quantity{Value: 2, Raw: "Version 2."}},
+		{"None", Quantity{Raw: "None"}},
+		{"", Quantity{}},
+	}
+
+	for _, test := range tests {
+		if got := ParseQuantity(test.value); got != test.want {
+			t.Errorf("ParseQuantity(%q) = %+v, want %+v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseFormFactor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  FormFactor
+	}{
+		{"ATX", FormFactorATX},
+		{"EATX", FormFactorEATX},
+		{"E-ATX", FormFactorEATX},
+		{"Micro ATX", FormFactorMicroATX},
+		{"mATX", FormFactorMicroATX},
+		{" Mini ITX ", FormFactorMiniITX},
+		{"Mini-ITX", FormFactorMiniITX},
+		{"Mini DTX", FormFactorMiniDTX},
+		{"SFX", FormFactorSFX},
+		{"SFX-L", FormFactorSFXL},
+		{"TFX", FormFactorTFX},
+		{"Thin Mini ITX", FormFactor("Thin Mini ITX")},
+	}
+
+	for _, test := range tests {
+		if got := ParseFormFactor(test.value); got != test.want {
+			t.Errorf("ParseFormFactor(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseModules(t *testing.T) {
+	tests := []struct {
+		value   string
+		modules int
+		size    Quantity
+	}{
+		{"2 x 16GB", 2, Quantity{Value: 16, Unit: "GB", Raw: "2 x 16GB"}},
+		{"4 x 8 GB", 4, Quantity{Value: 8, Unit: "GB", Raw: "4 x 8 GB"}},
+		{"1 x 32GB", 1, Quantity{Value: 32, Unit: "GB", Raw: "1 x 32GB"}},
+		{"32GB", 0, Quantity{Raw: "32GB"}},
+		{"", 0, Quantity{}},
+	}
+
+	for _, test := range tests {
+		modules, size := parseModules(test.value)
+		if modules != test.modules || size != test.size {
+			t.Errorf("parseModules(%q) = %d, %+v, want %d, %+v", test.value, modules, size, test.modules, test.size)
+		}
+	}
+}
+
+func TestCountSlots(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   int
+	}{
+		{[]string{"7 x Full-Height"}, 7},
+		{[]string{"7 x Full-Height", "2 x Full-Height via Riser"}, 9},
+		{[]string{"2242/2260/2280/22110 M-key", "2242/2260/2280 M-key (PCIe 4.0 x4)"}, 2},
+		{[]string{"2230/2242/2260/2280 M-key", ""}, 1},
+		{nil, 0},
+	}
+
+	for _, test := range tests {
+		if got := countSlots(test.values); got != test.want {
+			t.Errorf("countSlots(%q) = %d, want %d", test.values, got, test.want)
+		}
+	}
+}
+
+func specs(values map[string][]string) []PartSpec {
+	var specs []PartSpec
+	for name, value := range values {
+		specs = append(specs, PartSpec{Name: name, Values: value})
+	}
+	return specs
+}
+
+func TestParseTypedSpecs(t *testing.T) {
+	tests := []struct {
+		name     string
+		category Category
+		specs    map[string][]string
+		want     TypedSpecs
+	}{
+		{"motherboard", CategoryMotherboard, map[string][]string{
+			"Manufacturer": {"MSI"},
+			"Socket / CPU": {"AM5"},
+			"Form Factor":  {"Micro ATX"},
+			"Chipset":      {"AMD B650"},
+			"Memory Type":  {"DDR5"},
+			"Memory Slots": {"4"},
+			"Memory Max":   {"192 GB"},
+			"M.2 Slots":    {"2242/2260/2280/22110 M-key", "2242/2260/2280 M-key"},
+		}, TypedSpecs{Motherboard: &MotherboardSpecs{
+			Manufacturer: "MSI",
+			Socket:       "AM5",
+			FormFactor:   FormFactorMicroATX,
+			Chipset:      "AMD B650",
+			MemoryType:   MemoryTypeDDR5,
+			MemorySlots:  4,
+			MemoryMax:    Quantity{Value: 192, Unit: "GB", Raw: "192 GB"},
+			M2Slots:      2,
+		}}},
+		{"memory", CategoryMemory, map[string][]string{
+			"Manufacturer": {"Corsair"},
+			"Speed":        {"DDR5-6000"},
+			"Modules":      {"2 x 16GB"},
+			"CAS Latency":  {"30"},
+			"Voltage":      {"1.35 V"},
+		}, TypedSpecs{Memory: &MemorySpecs{
+			Manufacturer: "Corsair",
+			MemoryType:   MemoryTypeDDR5,
+			Speed:        Quantity{Value: 6000, Unit: "MT/s", Raw: "DDR5-6000"},
+			Modules:      2,
+			ModuleSize:   Quantity{Value: 16, Unit: "GB", Raw: "2 x 16GB"},
+			CASLatency:   30,
+			Voltage:      Quantity{Value: 1.35, Unit: "V", Raw: "1.35 V"},
+		}}},
+		{"video card", CategoryVideoCard, map[string][]string{
+			"Manufacturer":     {"Sapphire"},
+			"Chipset":          {"Radeon RX 7800 XT"},
+			"Memory":           {"16 GB"},
+			"Memory Type":      {"GDDR6"},
+			"Core Clock":       {"1800 MHz"},
+			"Boost Clock":      {"2430 MHz"},
+			"Length":           {"320 mm"},
+			"TDP":              {"263 W"},
+			"Total Slot Width": {"3"},
+		}, TypedSpecs{GPU: &GPUSpecs{
+			Manufacturer: "Sapphire",
+			Chipset:      "Radeon RX 7800 XT",
+			Memory:       Quantity{Value: 16, Unit: "GB", Raw: "16 GB"},
+			MemoryType:   "GDDR6",
+			CoreClock:    Quantity{Value: 1800, Unit: "MHz", Raw: "1800 MHz"},
+			BoostClock:   Quantity{Value: 2430, Unit: "MHz", Raw: "2430 MHz"},
+			Length:       Quantity{Value: 320, Unit: "mm", Raw: "320 mm"},
+			TDP:          Quantity{Value: 263, Unit: "W", Raw: "263 W"},
+			SlotWidth:    3,
+		}}},
+		{"power supply", CategoryPowerSupply, map[string][]string{
+			"Manufacturer":      {"Corsair"},
+			"Type":              {"SFX"},
+			"Wattage":           {"750 W"},
+			"Efficiency Rating": {"80+ Platinum"},
+			"Modular":           {"Full"},
+			"Length":            {"100 mm"},
+		}, TypedSpecs{PSU: &PSUSpecs{
+			Manufacturer:     "Corsair",
+			FormFactor:       FormFactorSFX,
+			Wattage:          Quantity{Value: 750, Unit: "W", Raw: "750 W"},
+			EfficiencyRating: "80+ Platinum",
+			Modular:          "Full",
+			Length:           Quantity{Value: 100, Unit: "mm", Raw: "100 mm"},
+		}}},
+		{"case", CategoryCase, map[string][]string{
+			"Manufacturer":              {"Fractal Design"},
+			"Type":                      {"ATX Mid Tower"},
+			"Motherboard Form Factor":   {"ATX", "EATX", "Micro ATX", "Mini ITX"},
+			"Maximum Video Card Length": {"491 mm / 19.331\""},
+			"Maximum CPU Cooler Height": {"185 mm"},
+			"Power Supply":              {"None"},
+			"Expansion Slots":           {"7 x Full-Height", "2 x Full-Height via Riser"},
+		}, TypedSpecs{Case: &CaseSpecs{
+			Manufacturer:           "Fractal Design",
+			Type:                   "ATX Mid Tower",
+			MotherboardFormFactors: []FormFactor{FormFactorATX, FormFactorEATX, FormFactorMicroATX, FormFactorMiniITX},
+			MaxGPULength:           Quantity{Value: 491, Unit: "mm", Raw: "491 mm / 19.331\""},
+			MaxCoolerHeight:        Quantity{Value: 185, Unit: "mm", Raw: "185 mm"},
+			ExpansionSlots:         9,
+		}}},
+		{"case with power supply", CategoryCase, map[string][]string{
+			"Power Supply": {"450 W"},
+		}, TypedSpecs{Case: &CaseSpecs{IncludesPowerSupply: true}}},
+		{"storage", CategoryInternalHardDrive, map[string][]string{
+			"Manufacturer": {"Samsung"},
+			"Capacity":     {"2 TB"},
+			"Type":         {"SSD"},
+			"Form Factor":  {"M.2-2280"},
+			"Interface":    {"M.2 PCIe 4.0 X4"},
+			"NVME":         {"Yes"},
+		}, TypedSpecs{Storage: &StorageSpecs{
+			Manufacturer: "Samsung",
+			Capacity:     Quantity{Value: 2, Unit: "TB", Raw: "2 TB"},
+			Type:         "SSD",
+			SSD:          true,
+			FormFactor:   "M.2-2280",
+			Interface:    "M.2 PCIe 4.0 X4",
+			NVMe:         true,
+		}}},
+		{"air cooler", CategoryCPUCooler, map[string][]string{
+			"Manufacturer": {"Noctua"},
+			"CPU Socket":   {"AM5", "LGA 1700"},
+			"Height":       {"165 mm"},
+			"Water Cooled": {"No"},
+			"Fanless":      {"No"},
+		}, TypedSpecs{Cooler: &CoolerSpecs{
+			Manufacturer: "Noctua",
+			Sockets:      []Socket{"AM5", "LGA1700"},
+			Height:       Quantity{Value: 165, Unit: "mm", Raw: "165 mm"},
+			RadiatorSize: Quantity{Raw: "No"},
+		}}},
+		{"liquid cooler", CategoryCPUCooler, map[string][]string{
+			"Water Cooled": {"240 mm"},
+		}, TypedSpecs{Cooler: &CoolerSpecs{
+			WaterCooled:  true,
+			RadiatorSize: Quantity{Value: 240, Unit: "mm", Raw: "240 mm"},
+		}}},
+		{"untyped category", CategoryMonitor, map[string][]string{"Manufacturer": {"Dell"}}, TypedSpecs{}},
+	}
+
+	for _, test := range tests {
+		got := ParseTypedSpecs(test.category, specs(test.specs))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %s, want %s", test.name, typedString(got), typedString(test.want))
+		}
+	}
+}
+
+// typedString prints the set field of typed specs rather than its pointer.
+func typedString(typed TypedSpecs) string {
+	value := reflect.ValueOf(typed)
+	for i := 0; i < value.NumField(); i++ {
+		if field := value.Field(i); !field.IsNil() {
+			return fmt.Sprintf("%+v", field.Elem().Interface())
+		}
+	}
+	return "{}"
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"errors"
+	"github.com/dlclark/regexp2"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Site describes the PCPartPicker instance URLs are built for and matched against.
+// An empty Regions list accepts any two letter region subdomain.
+type Site struct {
+	Scheme  string
+	Host    string
+	Regions []string
+
+	pcppURLMatcher          *regexp2.Regexp
+	productURLMatcher       *regexp2.Regexp
+	productPathMatcher      *regexp2.Regexp
+	partListURLMatcher      *regexp2.Regexp
+	vendorNameMatcher       *regexp2.Regexp
+	pcppUserSavedURLMatcher *regexp2.Regexp
+}
+
+// KnownRegions are the regions of the public PCPartPicker site, "us" being the bare host.
+var KnownRegions = []string{
+	"us", "ar", "at", "au", "be", "ca", "cz", "de", "dk", "es", "fi", "fr", "hu",
+	"ie", "in", "it", "nl", "no", "nz", "pt", "ro", "sa", "se", "sk", "uk",
+}
+
+// DefaultSite is the public PCPartPicker site.
+var DefaultSite = MustNewSite("https", "pcpartpicker.com", nil)
+
+// NewSite creates a Site for the given scheme, host and allowed region subdomains
+// and compiles its URL matchers.
+func NewSite(scheme string, host string, regions []string) (*Site, error) {
+	if scheme != "http" && scheme != "https" {
+		return nil, errors.New("invalid site scheme")
+	}
+	if host == "" || strings.ContainsAny(host, "/?#") {
+		return nil, errors.New("invalid site host")
+	}
+	for _, region := range regions {
+		if region == "" || strings.ContainsFunc(region, func(r rune) bool { return r < 'a' || r > 'z' }) {
+			return nil, errors.New("invalid site region " + strconv.Quote(region))
+		}
+	}
+
+	regionPattern := `[a-z]{2}`
+	if len(regions) > 0 {
+		escaped := make([]string, len(regions))
+		for i, region := range regions {
+			escaped[i] = regexp2.Escape(region)
+		}
+		regionPattern = "(" + strings.Join(escaped, "|") + ")"
+	}
+
+	prefix := `^(https?://)?((?<region>` + regionPattern + `)\.)?` + regexp2.Escape(host)
+
+	var err error
+	site := &Site{
+		Scheme:  scheme,
+		Host:    host,
+		Regions: regions,
+	}
+	compile := func(expr string) *regexp2.Regexp {
+		re, compileErr := regexp2.Compile(expr, 0)
+		if compileErr != nil && err == nil {
+			err = compileErr
+		}
+		return re
+	}
+
+	site.pcppURLMatcher = compile(prefix + `(/.*)?$`)
+	site.productURLMatcher = compile(prefix + `/product/[a-zA-Z0-9]{4,8}/[\S]*`)
+	site.productPathMatcher = compile(prefix + `/(?<path>product/(?<id>[a-zA-Z0-9]{4,8})/[\S]*)`)
+	site.partListURLMatcher = compile(prefix + `/((list/[a-zA-Z0-9]{4,8})|((user/\w*/saved/(#view=)?[a-zA-Z0-9]{4,8})))`)
+	site.vendorNameMatcher = compile(`(?<=` + regexp2.Escape(host) + `/mr/).*(?=\/)`)
+	site.pcppUserSavedURLMatcher = compile(prefix + `/user/[a-zA-Z0-9]*/saved/#view=[a-zA-Z0-9]{4,8}`)
+
+	if err != nil {
+		return nil, err
+	}
+	return site, nil
+}
+
+// MustNewSite is like NewSite but panics if the site is invalid.
+func MustNewSite(scheme string, host string, regions []string) *Site {
+	site, err := NewSite(scheme, host, regions)
+	if err != nil {
+		panic(err)
+	}
+	return site
+}
+
+func (s *Site) ExtractVendorName(URL string) string {
+	if URL == "" {
+		return ""
+	}
+	m, err := s.vendorNameMatcher.FindStringMatch(URL)
+	if err != nil || m == nil {
+		return ""
+	}
+	return m.String()
+}
+
+func (s *Site) ConvertListURL(URL string) string {
+	match, _ := s.pcppUserSavedURLMatcher.MatchString(URL)
+
+	if !match {
+		return URL
+	}
+
+	return strings.Replace(URL, "#view=", "", 1)
+}
+
+func (s *Site) MatchPCPPURL(URL string) bool {
+	match, _ := s.pcppURLMatcher.MatchString(URL)
+
+	return match
+}
+
+func (s *Site) MatchProductURL(URL string) bool {
+	match, _ := s.productURLMatcher.MatchString(URL)
+
+	return match
+}
+
+// ExtractProductID returns the product ID of a product URL, "Yg3mP6" for
+// https://pcpartpicker.com/product/Yg3mP6/amd-ryzen-7-7800x3d, or an empty string.
+func (s *Site) ExtractProductID(URL string) string {
+	return s.productGroup(URL, "id")
+}
+
+// RegionalProductURL returns the URL of the same product on the given region.
+// It returns an empty string if URL is not a product URL.
+func (s *Site) RegionalProductURL(URL string, region string) string {
+	path := s.productGroup(URL, "path")
+	if path == "" {
+		return ""
+	}
+	return s.BuildPrefixURL(region) + path
+}
+
+// ProductKey identifies a product whatever the scheme, slug or query of its URL:
+// "uk/Yg3mP6" for uk.pcpartpicker.com/product/Yg3mP6/any-slug, the bare host
+// being the "us" region. URLs that aren't product URLs are their own key.
+func (s *Site) ProductKey(URL string) string {
+	id := s.productGroup(URL, "id")
+	if id == "" {
+		return URL
+	}
+	region := s.productGroup(URL, "region")
+	if region == "" {
+		region = "us"
+	}
+	return region + "/" + id
+}
+
+func (s *Site) productGroup(URL string, name string) string {
+	m, err := s.productPathMatcher.FindStringMatch(URL)
+	if err != nil || m == nil {
+		return ""
+	}
+	return m.GroupByName(name).String()
+}
+
+func (s *Site) MatchPartListURL(URL string) bool {
+	match, _ := s.partListURLMatcher.MatchString(URL)
+
+	return match
+}
+
+func (s *Site) ExtractPartListURLs(text string) []string {
+	return Regexp2SearchAllText(s.partListURLMatcher, text)
+}
+
+// SupportedRegions returns the regions accepted by the site: its Regions, plus
+// "us" for the bare host, or KnownRegions when it accepts any region.
+func (s *Site) SupportedRegions() []string {
+	if len(s.Regions) == 0 {
+		return KnownRegions
+	}
+	if slices.Contains(s.Regions, "us") {
+		return s.Regions
+	}
+	return append([]string{"us"}, s.Regions...)
+}
+
+func (s *Site) BuildPrefixURL(region string) string {
+	if region != "" && region != "us" {
+		region += "."
+	} else {
+		region = ""
+	}
+	prefixURL := s.Scheme + "://" + region + s.Host + "/"
+	return prefixURL
+}
+
+// Region returns the region a host of the site belongs to, "uk" for
+// uk.pcpartpicker.com and "us" for the bare host. It returns an empty string
+// for hosts outside of the site.
+func (s *Site) Region(host string) string {
+	if host == s.Host {
+		return "us"
+	}
+	region, ok := strings.CutSuffix(host, "."+s.Host)
+	if !ok || strings.Contains(region, ".") {
+		return ""
+	}
+	return region
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNewSiteRejectsInvalidSites(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		scheme  string
+		host    string
+		regions []string
+	}{
+		{"scheme", "ftp", "pcpartpicker.com", nil},
+		{"empty host", "https", "", nil},
+		{"host with a path", "https", "pcpartpicker.com/list", nil},
+		{"empty region", "https", "pcpartpicker.com", []string{"uk", ""}},
+		{"upper case region", "https", "pcpartpicker.com", []string{"UK"}},
+		{"region with a space", "https", "pcpartpicker.com", []string{" de"}},
+	} {
+		if _, err := NewSite(tc.scheme, tc.host, tc.regions); err == nil {
+			t.Errorf("%s: expected the site to be rejected", tc.name)
+		}
+	}
+}
+
+func TestSiteMatchesURLs(t *testing.T) {
+	regional := MustNewSite("http", "pcpartpicker.test", []string{"uk", "de"})
+
+	for _, tc := range []struct {
+		site     *Site
+		url      string
+		pcpp     bool
+		product  bool
+		partList bool
+	}{
+		{DefaultSite, "https://pcpartpicker.com/product/Yg3mP6/amd-ryzen-7-7800x3d", true, true, false},
+		{DefaultSite, "uk.pcpartpicker.com/list/abcd12", true, false, true},
+		{DefaultSite, "https://pcpartpicker.com/user/builder/saved/#view=abcd12", true, false, true},
+		{DefaultSite, "https://pcpartpicker.com.example.com/list/abcd12", false, false, false},
+		{DefaultSite, "https://example.com/product/Yg3mP6/cpu", false, false, false},
+		{regional, "http://de.pcpartpicker.test/product/Yg3mP6/cpu", true, true, false},
+		{regional, "http://fr.pcpartpicker.test/product/Yg3mP6/cpu", false, false, false},
+		{regional, "http://pcpartpicker.test/list/abcd12", true, false, true},
+	} {
+		if got := tc.site.MatchPCPPURL(tc.url); got != tc.pcpp {
+			t.Errorf("MatchPCPPURL(%q) = %v, want %v", tc.url, got, tc.pcpp)
+		}
+		if got := tc.site.MatchProductURL(tc.url); got != tc.product {
+			t.Errorf("MatchProductURL(%q) = %v, want %v", tc.url, got, tc.product)
+		}
+		if got := tc.site.MatchPartListURL(tc.url); got != tc.partList {
+			t.Errorf("MatchPartListURL(%q) = %v, want %v", tc.url, got, tc.partList)
+		}
+	}
+}
+
+func TestSiteProductURLs(t *testing.T) {
+	const product = "https://pcpartpicker.com/product/Yg3mP6/amd-ryzen-7-7800x3d"
+
+	if id := DefaultSite.ExtractProductID(product); id != "Yg3mP6" {
+		t.Errorf("expected the product ID Yg3mP6, got %q", id)
+	}
+	if id := DefaultSite.ExtractProductID("https://pcpartpicker.com/list/abcd12"); id != "" {
+		t.Errorf("expected no product ID for a list, got %q", id)
+	}
+
+	for region, want := range map[string]string{
+		"uk": "https://uk.pcpartpicker.com/product/Yg3mP6/amd-ryzen-7-7800x3d",
+		"us": "https://pcpartpicker.com/product/Yg3mP6/amd-ryzen-7-7800x3d",
+		"":   "https://pcpartpicker.com/product/Yg3mP6/amd-ryzen-7-7800x3d",
+	} {
+		if got := DefaultSite.RegionalProductURL(product, region); got != want {
+			t.Errorf("RegionalProductURL(%q) = %q, want %q", region, got, want)
+		}
+	}
+	if got := DefaultSite.RegionalProductURL("https://pcpartpicker.com/list/abcd12", "uk"); got != "" {
+		t.Errorf("expected no regional URL for a list, got %q", got)
+	}
+}
+
+func TestSiteProductKey(t *testing.T) {
+	regional := MustNewSite("http", "pcpartpicker.test", []string{"uk", "de"})
+
+	for _, tc := range []struct {
+		site *Site
+		url  string
+		key  string
+	}{
+		{DefaultSite, "https://pcpartpicker.com/product/Yg3mP6/amd-ryzen-7-7800x3d", "us/Yg3mP6"},
+		{DefaultSite, "http://pcpartpicker.com/product/Yg3mP6/other-slug?utm_source=feed", "us/Yg3mP6"},
+		{DefaultSite, "uk.pcpartpicker.com/product/Yg3mP6/", "uk/Yg3mP6"},
+		{regional, "http://de.pcpartpicker.test/product/Yg3mP6/cpu", "de/Yg3mP6"},
+		{DefaultSite, "https://pcpartpicker.com/list/abcd12", "https://pcpartpicker.com/list/abcd12"},
+	} {
+		if got := tc.site.ProductKey(tc.url); got != tc.key {
+			t.Errorf("ProductKey(%q) = %q, want %q", tc.url, got, tc.key)
+		}
+	}
+}
+
+func TestSiteListAndVendorURLs(t *testing.T) {
+	if got := DefaultSite.ConvertListURL("https://pcpartpicker.com/user/builder/saved/#view=abcd12"); got != "https://pcpartpicker.com/user/builder/saved/abcd12" {
+		t.Errorf("unexpected saved list URL %q", got)
+	}
+	if got := DefaultSite.ConvertListURL("https://pcpartpicker.com/list/abcd12"); got != "https://pcpartpicker.com/list/abcd12" {
+		t.Errorf("expected a list URL to be left as is, got %q", got)
+	}
+	if got := DefaultSite.ExtractVendorName("https://pcpartpicker.com/mr/amazon/Yg3mP6"); got != "amazon" {
+		t.Errorf("expected the vendor amazon, got %q", got)
+	}
+	if got := DefaultSite.ExtractVendorName(""); got != "" {
+		t.Errorf("expected no vendor, got %q", got)
+	}
+}
+
+func TestSiteRegions(t *testing.T) {
+	regional := MustNewSite("http", "pcpartpicker.test", []string{"uk", "de"})
+
+	for _, tc := range []struct {
+		site   *Site
+		region string
+		prefix string
+	}{
+		{DefaultSite, "", "https://pcpartpicker.com/"},
+		{DefaultSite, "us", "https://pcpartpicker.com/"},
+		{DefaultSite, "uk", "https://uk.pcpartpicker.com/"},
+		{regional, "de", "http://de.pcpartpicker.test/"},
+	} {
+		if got := tc.site.BuildPrefixURL(tc.region); got != tc.prefix {
+			t.Errorf("BuildPrefixURL(%q) = %q, want %q", tc.region, got, tc.prefix)
+		}
+	}
+
+	for host, want := range map[string]string{
+		"pcpartpicker.com":         "us",
+		"uk.pcpartpicker.com":      "uk",
+		"a.uk.pcpartpicker.com":    "",
+		"pcpartpicker.com.example": "",
+		"example.com":              "",
+	} {
+		if got := DefaultSite.Region(host); got != want {
+			t.Errorf("Region(%q) = %q, want %q", host, got, want)
+		}
+	}
+
+	if got := DefaultSite.SupportedRegions(); !slices.Equal(got, KnownRegions) {
+		t.Errorf("expected every known region, got %v", got)
+	}
+	if got := regional.SupportedRegions(); !slices.Equal(got, []string{"us", "uk", "de"}) {
+		t.Errorf("expected the bare host and the site regions, got %v", got)
+	}
+}
@@ -3,59 +3,37 @@ package utils
 import (
 	"github.com/dlclark/regexp2"
 	"github.com/gocolly/colly/v2"
+	"strconv"
 	"strings"
 )
 
 var (
-	pcppURLMatcher          = regexp2.MustCompile(`^(https?://)?([a-z]{2}\.)?pcpartpicker\.com(/.*)?$`, 0)
-	productURLMatcher       = regexp2.MustCompile(`^(https?://)?([a-z]{2}\.)?pcpartpicker\.com/product/[a-zA-Z0-9]{4,8}/[\S]*`, 0)
-	partListURLMatcher      = regexp2.MustCompile(`^(https?://)?([a-z]{2}\.)?pcpartpicker\.com/((list/[a-zA-Z0-9]{4,8})|((user/\w*/saved/(#view=)?[a-zA-Z0-9]{4,8})))`, 0)
-	vendorNameMatcher       = regexp2.MustCompile(`(?<=pcpartpicker\.com/mr/).*(?=\/)`, 0)
-	pcppUserSavedURLMatcher = regexp2.MustCompile(`^(https?://)?([a-z]{2}\.)?pcpartpicker\.com/user/[a-zA-Z0-9]*/saved/#view=[a-zA-Z0-9]{4,8}`, 0)
-	scriptImageCheck        = regexp2.MustCompile(`(?<=src:\s").*(?=")`, 0)
+	scriptImageCheck   = regexp2.MustCompile(`(?<=src:\s").*(?=")`, 0)
+	resultCountMatcher = regexp2.MustCompile(`\d[\d,]*`, 0)
 )
 
 func ExtractVendorName(URL string) string {
-	if URL == "" {
-		return ""
-	}
-	m, err := vendorNameMatcher.FindStringMatch(URL)
-	if err != nil || m == nil {
-		return ""
-	}
-	return m.String()
+	return DefaultSite.ExtractVendorName(URL)
 }
 
 func ConvertListURL(URL string) string {
-	match, _ := pcppUserSavedURLMatcher.MatchString(URL)
-
-	if !match {
-		return URL
-	}
-
-	return strings.Replace(URL, "#view=", "", 1)
+	return DefaultSite.ConvertListURL(URL)
 }
 
 func MatchPCPPURL(URL string) bool {
-	match, _ := pcppURLMatcher.MatchString(URL)
-
-	return match
+	return DefaultSite.MatchPCPPURL(URL)
 }
 
 func MatchProductURL(URL string) bool {
-	match, _ := productURLMatcher.MatchString(URL)
-
-	return match
+	return DefaultSite.MatchProductURL(URL)
 }
 
 func MatchPartListURL(URL string) bool {
-	match, _ := partListURLMatcher.MatchString(URL)
-
-	return match
+	return DefaultSite.MatchPartListURL(URL)
 }
 
 func ExtractPartListURLs(text string) []string {
-	return Regexp2SearchAllText(partListURLMatcher, text)
+	return DefaultSite.ExtractPartListURLs(text)
 }
 
 func FindScriptImages(script *colly.HTMLElement, images []string) []string {
@@ -68,6 +46,21 @@ func FindScriptImages(script *colly.HTMLElement, images []string) []string {
 	return images
 }
 
+// ExtractResultCount returns the last number in a result count text such as
+// "Showing 1 - 20 of 1,234 results", or 0 if there is none.
+func ExtractResultCount(text string) uint {
+	matches := Regexp2SearchAllText(resultCountMatcher, text)
+	if len(matches) == 0 {
+		return 0
+	}
+
+	count, err := strconv.ParseUint(strings.ReplaceAll(matches[len(matches)-1], ",", ""), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(count)
+}
+
 func Regexp2SearchAllText(re *regexp2.Regexp, s string) []string {
 	var matches []string
 	m, _ := re.FindStringMatch(s)
@@ -79,11 +72,5 @@ func Regexp2SearchAllText(re *regexp2.Regexp, s string) []string {
 }
 
 func BuildPrefixURL(region string) string {
-	if region != "" && region != "us" {
-		region += "."
-	} else {
-		region = ""
-	}
-	prefixURL := "https://" + region + "pcpartpicker.com/"
-	return prefixURL
+	return DefaultSite.BuildPrefixURL(region)
 }
@@ -1,34 +1,569 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"github.com/Aquilabot/KreaPC-API/pkg/alerts"
+	"github.com/Aquilabot/KreaPC-API/pkg/cache"
+	"github.com/Aquilabot/KreaPC-API/pkg/compat"
+	"github.com/Aquilabot/KreaPC-API/pkg/currency"
 	"github.com/Aquilabot/KreaPC-API/pkg/pcpartpicker_automation"
+	"github.com/Aquilabot/KreaPC-API/pkg/proxy"
 	"github.com/Aquilabot/KreaPC-API/pkg/scraper"
+	"github.com/Aquilabot/KreaPC-API/pkg/tracker"
+	"github.com/Aquilabot/KreaPC-API/pkg/wattage"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/helmet"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type SearchRequest struct {
-	Query  string `json:"query"`
-	Region string `json:"region"`
+	Query    string `json:"query"`
+	Region   string `json:"region"`
+	Page     uint   `json:"page"`
+	Limit    uint   `json:"limit"`
+	Currency string `json:"currency"`
+	NoCache  bool   `json:"noCache"`
+}
+
+type CategoryRequest struct {
+	Category models.Category       `json:"category"`
+	Region   string                `json:"region"`
+	Filter   models.CategoryFilter `json:"filter"`
+	Page     uint                  `json:"page"`
+	Limit    uint                  `json:"limit"`
+}
+
+type PartsRequest struct {
+	Parts []models.Part `json:"parts"`
+	URLs  []string      `json:"urls"`
+}
+
+type WattageRequest struct {
+	PartsRequest
+	Headroom *float64 `json:"headroom"`
 }
 
 type URLRequest struct {
 	URL string `json:"url"`
 }
 
+type PartRequest struct {
+	URL      string `json:"url"`
+	Currency string `json:"currency"`
+	NoCache  bool   `json:"noCache"`
+}
+
+type CompareRequest struct {
+	URL      string   `json:"url"`
+	Regions  []string `json:"regions"`
+	Currency string   `json:"currency"`
+}
+
+type WatchRequest struct {
+	URL      string `json:"url"`
+	Interval string `json:"interval"`
+}
+
+type AlertRequest struct {
+	URL        string  `json:"url"`
+	Region     string  `json:"region"`
+	Vendor     string  `json:"vendor"`
+	Event      string  `json:"event"`
+	Threshold  float64 `json:"threshold"`
+	WebhookURL string  `json:"webhookUrl"`
+	Secret     string  `json:"secret"`
+}
+
+type IDRequest struct {
+	ID string `json:"id"`
+}
+
 type URLsRequest struct {
 	Region string   `json:"region"`
 	URLs   []string `json:"urls"`
 }
 
+// EditListRequest describes the edits of an existing part list.
+type EditListRequest struct {
+	URL   string                             `json:"url"`
+	Edits []pcpartpicker_automation.ListEdit `json:"edits"`
+}
+
+// ListRequest describes a part list to build: every URL added once, followed by
+// the items carrying a quantity, a price override or a custom part.
+type ListRequest struct {
+	URLsRequest
+	Items []models.ListItem `json:"items"`
+}
+
+// SavedListRequest describes a part list to save to the account: the parts of
+// an existing list at URL, or the URLs and items of a list to build.
+type SavedListRequest struct {
+	ListRequest
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type RenameRequest struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// siteFromEnv builds the PCPartPicker site configuration from the PCPP_SCHEME,
+// PCPP_HOST and PCPP_REGIONS (comma separated) environment variables,
+// falling back to the public site for unset values.
+func siteFromEnv() (*utils.Site, error) {
+	scheme := os.Getenv("PCPP_SCHEME")
+	host := os.Getenv("PCPP_HOST")
+	regions := os.Getenv("PCPP_REGIONS")
+
+	if scheme == "" && host == "" && regions == "" {
+		return utils.DefaultSite, nil
+	}
+	if scheme == "" {
+		scheme = utils.DefaultSite.Scheme
+	}
+	if host == "" {
+		host = utils.DefaultSite.Host
+	}
+
+	var regionList []string
+	for _, region := range strings.Split(regions, ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			regionList = append(regionList, strings.ToLower(region))
+		}
+	}
+
+	return utils.NewSite(scheme, host, regionList)
+}
+
+// envOrDefault returns the value of the environment variable key, or def if it is unset.
+func envOrDefault(key string, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// converterFromEnv builds the currency converter from the KREAPC_RATES_URL or
+// KREAPC_RATES_FILE environment variables. It returns nil when neither is set.
+func converterFromEnv() (*currency.Converter, error) {
+	if URL := os.Getenv("KREAPC_RATES_URL"); URL != "" {
+		return currency.NewConverter(currency.NewHTTPProvider(URL)), nil
+	}
+	if path := os.Getenv("KREAPC_RATES_FILE"); path != "" {
+		provider, err := currency.NewFileProvider(path)
+		if err != nil {
+			return nil, err
+		}
+		return currency.NewConverter(provider), nil
+	}
+	return nil, nil
+}
+
+// cachedScraperFromEnv puts a cache in front of scrap, configured by the
+// KREAPC_CACHE ("memory", "disk" or "off"), KREAPC_CACHE_SIZE and
+// KREAPC_CACHE_*_TTL environment variables. The disk cache lives in dataDir.
+func cachedScraperFromEnv(scrap *scraper.Scraper, dataDir string) (*cache.CachedScraper, error) {
+	var backend cache.Backend
+	var diskBackend *cache.DiskBackend
+	switch mode := envOrDefault("KREAPC_CACHE", "memory"); mode {
+	case "memory":
+		size, err := strconv.Atoi(envOrDefault("KREAPC_CACHE_SIZE", "1000"))
+		if err != nil {
+			return nil, err
+		}
+		backend = cache.NewMemoryBackend(size)
+	case "disk":
+		var err error
+		if diskBackend, err = cache.NewDiskBackend(filepath.Join(dataDir, "cache")); err != nil {
+			return nil, err
+		}
+		backend = diskBackend
+	case "off":
+		backend = cache.NopBackend{}
+	default:
+		return nil, errors.New("invalid cache mode " + mode)
+	}
+
+	cached := cache.NewCachedScraper(scrap, cache.New(backend))
+	if _, off := backend.(cache.NopBackend); off {
+		cached.Search, cached.Product, cached.List = cache.Policy{}, cache.Policy{}, cache.Policy{}
+		return cached, nil
+	}
+	for key, policy := range map[string]*cache.Policy{
+		"KREAPC_CACHE_SEARCH_TTL":  &cached.Search,
+		"KREAPC_CACHE_PRODUCT_TTL": &cached.Product,
+		"KREAPC_CACHE_LIST_TTL":    &cached.List,
+	} {
+		if value := os.Getenv(key); value != "" {
+			ttl, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, err
+			}
+			policy.TTL = ttl
+		}
+	}
+	if value := os.Getenv("KREAPC_CACHE_STALE"); value != "" {
+		stale, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		cached.Search.Stale, cached.Product.Stale, cached.List.Stale = stale, stale, stale
+	}
+	// Remove the disk entries no policy would serve anymore
+	if diskBackend != nil {
+		for _, policy := range []cache.Policy{cached.Search, cached.Product, cached.List} {
+			diskBackend.MaxAge = max(diskBackend.MaxAge, policy.TTL+policy.Stale)
+		}
+	}
+	return cached, nil
+}
+
+// setCacheHeaders describes how a cached response was served.
+func setCacheHeaders(c *fiber.Ctx, info cache.Info) {
+	c.Set("Cache-Control", fmt.Sprintf("public, max-age=%d, stale-while-revalidate=%d",
+		int(info.Policy.TTL.Seconds()), int(info.Policy.Stale.Seconds())))
+	c.Set("Age", strconv.Itoa(int(info.Age.Seconds())))
+	c.Set("X-Cache", string(info.Status))
+}
+
+// bypassCache reports whether a request asks for fresh data, through its noCache
+// field or a "Cache-Control: no-cache" header.
+func bypassCache(c *fiber.Ctx, noCache bool) bool {
+	return noCache || strings.Contains(c.Get("Cache-Control"), "no-cache")
+}
+
+// scrapeErrors maps each category of scrape failure to its response status and message.
+var scrapeErrors = map[scraper.ErrorKind]struct {
+	status  int
+	message string
+}{
+	scraper.KindNotFound:     {404, "Page not found on PCPartPicker"},
+	scraper.KindInvalidInput: {400, "Invalid PCPartPicker URL or parameters"},
+	scraper.KindRateLimited:  {429, "Too many requests"},
+	scraper.KindBlocked:      {503, "Blocked by PCPartPicker"},
+	scraper.KindUpstreamDown: {502, "PCPartPicker is unavailable"},
+	scraper.KindParse:        {502, "Could not parse the PCPartPicker page"},
+}
+
+// scrapeError responds to a failed scrape with the status and message of its
+// category and its machine-readable code, falling back to a 500 with message.
+// Retry-After is set when the failure tells when to try again.
+func scrapeError(c *fiber.Ctx, err error, message string) error {
+	kind := scraper.KindOf(err)
+	status := 500
+	if mapped, ok := scrapeErrors[kind]; ok {
+		status, message = mapped.status, mapped.message
+	}
+
+	var scrapeErr *scraper.Error
+	if errors.As(err, &scrapeErr) && scrapeErr.RetryAfter > 0 {
+		c.Set("Retry-After", strconv.Itoa(int(math.Ceil(scrapeErr.RetryAfter.Seconds()))))
+	}
+	return c.Status(status).JSON(fiber.Map{"error": message, "code": kind})
+}
+
+// listBuildError responds to a part list that couldn't be built.
+func listBuildError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, pcpartpicker_automation.ErrInvalidRegion):
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid region"})
+	case errors.Is(err, pcpartpicker_automation.ErrInvalidItem), errors.Is(err, pcpartpicker_automation.ErrInvalidEdit):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, context.DeadlineExceeded):
+		return c.Status(503).JSON(fiber.Map{"error": "No browser available"})
+	default:
+		return c.Status(502).JSON(fiber.Map{"error": "Error generating part list"})
+	}
+}
+
+// rateLimitFromEnv reads the outbound rate limit from the KREAPC_HOST_CONCURRENCY,
+// KREAPC_REQUEST_DELAY, KREAPC_REQUEST_JITTER, KREAPC_REQUESTS_PER_SECOND,
+// KREAPC_MAX_WAIT, KREAPC_BLOCK_COOLDOWN and KREAPC_ROBOTS_TXT environment variables.
+func rateLimitFromEnv() (scraper.RateLimit, error) {
+	var limit scraper.RateLimit
+	var err error
+
+	if limit.HostConcurrency, err = strconv.Atoi(envOrDefault("KREAPC_HOST_CONCURRENCY", "2")); err != nil {
+		return limit, err
+	}
+	if limit.Delay, err = time.ParseDuration(envOrDefault("KREAPC_REQUEST_DELAY", "500ms")); err != nil {
+		return limit, err
+	}
+	if limit.Jitter, err = time.ParseDuration(envOrDefault("KREAPC_REQUEST_JITTER", "500ms")); err != nil {
+		return limit, err
+	}
+	if limit.RequestsPerSecond, err = strconv.ParseFloat(envOrDefault("KREAPC_REQUESTS_PER_SECOND", "2"), 64); err != nil {
+		return limit, err
+	}
+	limit.Burst = int(math.Ceil(limit.RequestsPerSecond))
+	if limit.MaxWait, err = time.ParseDuration(envOrDefault("KREAPC_MAX_WAIT", "10s")); err != nil {
+		return limit, err
+	}
+	if limit.BlockCooldown, err = time.ParseDuration(envOrDefault("KREAPC_BLOCK_COOLDOWN", "1m")); err != nil {
+		return limit, err
+	}
+	if limit.RespectRobotsTxt, err = strconv.ParseBool(envOrDefault("KREAPC_ROBOTS_TXT", "false")); err != nil {
+		return limit, err
+	}
+	return limit, nil
+}
+
+// browserPoolFromEnv configures the pool of browsers building part lists.
+func browserPoolFromEnv() (*pcpartpicker_automation.BrowserPool, error) {
+	size, err := strconv.Atoi(envOrDefault("KREAPC_BROWSER_POOL_SIZE", "4"))
+	if err != nil {
+		return nil, err
+	}
+	pool := pcpartpicker_automation.NewBrowserPool(size)
+
+	if pool.ContextsPerBrowser, err = strconv.Atoi(envOrDefault("KREAPC_BROWSER_CONTEXTS", "4")); err != nil {
+		return nil, err
+	}
+	if pool.Warm, err = strconv.Atoi(envOrDefault("KREAPC_BROWSER_WARM", "1")); err != nil {
+		return nil, err
+	}
+	if pool.IdleTimeout, err = time.ParseDuration(envOrDefault("KREAPC_BROWSER_IDLE_TIMEOUT", "5m")); err != nil {
+		return nil, err
+	}
+	if pool.MaxUses, err = strconv.Atoi(envOrDefault("KREAPC_BROWSER_MAX_USES", "50")); err != nil {
+		return nil, err
+	}
+	if pool.CheckInterval, err = time.ParseDuration(envOrDefault("KREAPC_BROWSER_CHECK_INTERVAL", "1m")); err != nil {
+		return nil, err
+	}
+	if pool.MaxWait, err = time.ParseDuration(envOrDefault("KREAPC_BROWSER_MAX_WAIT", "30s")); err != nil {
+		return nil, err
+	}
+	return pool, pool.Validate()
+}
+
+// listBuilderFromEnv returns the builder of part lists selected by KREAPC_LIST_BUILDER: "playwright"
+// (the default) builds lists with a pool of browsers started right away, "http" without a browser, and
+// "auto" over HTTP falling back to browsers started on the first fallback. The HTTP builder is
+// experimental. The returned browser pool is nil when unused.
+func listBuilderFromEnv(site *utils.Site, proxies *proxy.Pool) (pcpartpicker_automation.ListBuilder, *pcpartpicker_automation.BrowserPool, error) {
+	httpBuilder := pcpartpicker_automation.NewHTTPBuilder(site)
+	httpBuilder.Proxies = proxies
+	httpBuilder.AddPath = envOrDefault("KREAPC_ADD_PART_PATH", pcpartpicker_automation.DefaultAddPath)
+
+	mode := envOrDefault("KREAPC_LIST_BUILDER", "playwright")
+	switch mode {
+	case "http":
+		log.Println("The HTTP list builder is experimental")
+		return httpBuilder, nil, nil
+	case "auto":
+		log.Println("The HTTP list builder is experimental, falling back to Chromium")
+	case "playwright":
+	default:
+		return nil, nil, fmt.Errorf("unknown list builder %q", mode)
+	}
+
+	browsers, err := browserPoolFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+	browsers.Proxies = proxies
+	if mode == "playwright" {
+		if err := browsers.Start(); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		browsers.Lazy = true
+	}
+
+	playwrightBuilder := pcpartpicker_automation.NewPlaywrightBuilder(site)
+	playwrightBuilder.Browsers = browsers
+	if mode == "playwright" {
+		return playwrightBuilder, browsers, nil
+	}
+	return &pcpartpicker_automation.FallbackBuilder{Primary: httpBuilder, Fallback: playwrightBuilder}, browsers, nil
+}
+
+// accountFromEnv returns the PCPartPicker account logging in with KREAPC_PCPP_USERNAME and
+// KREAPC_PCPP_PASSWORD on the KREAPC_PCPP_REGION region, or nil when no account is configured.
+// Its session is kept in dataDir, encrypted with KREAPC_COOKIE_KEY, and isn't persisted without a key.
+// The account keeps to a single proxy of proxies, if any.
+func accountFromEnv(site *utils.Site, dataDir string, proxies *proxy.Pool) (*pcpartpicker_automation.Account, error) {
+	username := os.Getenv("KREAPC_PCPP_USERNAME")
+	if username == "" {
+		return nil, nil
+	}
+
+	var store *pcpartpicker_automation.CookieStore
+	if key := os.Getenv("KREAPC_COOKIE_KEY"); key != "" {
+		var err error
+		if store, err = pcpartpicker_automation.NewCookieStore(filepath.Join(dataDir, "session.enc"), key); err != nil {
+			return nil, fmt.Errorf("KREAPC_COOKIE_KEY: %w", err)
+		}
+	} else {
+		log.Println("KREAPC_COOKIE_KEY is not set, the PCPartPicker session won't be persisted")
+	}
+
+	account, err := pcpartpicker_automation.NewAccount(site, username, os.Getenv("KREAPC_PCPP_PASSWORD"), store)
+	if err != nil {
+		return nil, err
+	}
+	account.Region = os.Getenv("KREAPC_PCPP_REGION")
+	account.Proxies = proxies
+	if !site.MatchPCPPURL(site.BuildPrefixURL(account.Region)) {
+		return nil, fmt.Errorf("invalid PCPartPicker account region %q", account.Region)
+	}
+	return account, nil
+}
+
+// accountError responds to an action of the PCPartPicker account that failed.
+func accountError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, pcpartpicker_automation.ErrNoSavedList):
+		return c.Status(404).JSON(fiber.Map{"error": "Saved list not found"})
+	case errors.Is(err, pcpartpicker_automation.ErrInvalidItem), errors.Is(err, pcpartpicker_automation.ErrInvalidEdit):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, pcpartpicker_automation.ErrLoginFailed):
+		return c.Status(502).JSON(fiber.Map{"error": "Could not log in to PCPartPicker"})
+	default:
+		return c.Status(502).JSON(fiber.Map{"error": "Error managing saved lists"})
+	}
+}
+
+// validCurrency reports whether prices can be converted into the requested currency.
+// An empty currency keeps the scraped prices.
+func validCurrency(converter *currency.Converter, code string) bool {
+	return code == "" || converter != nil && converter.Supports(code)
+}
+
+// collectParts returns the parts given in the request followed by the parts fetched from its URLs.
+func collectParts(scrap *scraper.Scraper, req PartsRequest) ([]models.Part, error) {
+	parts := req.Parts
+	for _, URL := range req.URLs {
+		part, err := scrap.GetPart(URL)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, *part)
+	}
+	return parts, nil
+}
+
 func main() {
+	site, err := siteFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize the scraper
-	scrap := scraper.NewScraper()
+	scrap := scraper.NewSiteScraper(site)
 	scrap.RandomizeUserAgent()
 
+	rateLimit, err := rateLimitFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := scrap.SetRateLimit(rateLimit); err != nil {
+		log.Fatal(err)
+	}
+
+	retry := scraper.DefaultRetryPolicy
+	if retry.MaxAttempts, err = strconv.Atoi(envOrDefault("KREAPC_MAX_ATTEMPTS", "3")); err != nil {
+		log.Fatal(err)
+	}
+	scrap.SetRetryPolicy(retry)
+
+	// Record the prices of the watched parts and re-scrape them
+	dataDir := envOrDefault("KREAPC_DATA_DIR", "data")
+	store, err := tracker.NewFileStore(dataDir, site.ProductKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+	scrap.OnPartScraped(tracker.Recorder(store))
+
+	// Check the alert rules against every scraped part
+	notifier := alerts.NewNotifier(os.Getenv("KREAPC_WEBHOOK_SECRET"))
+	alertManager, err := alerts.NewManager(site, notifier, filepath.Join(dataDir, "alerts.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	scrap.OnPartScraped(alertManager.Check)
+
+	// Convert prices into the currency requested by clients
+	converter, err := converterFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	scrap.OnBlocked(func(blocked *scraper.BlockedError) {
+		log.Println("Blocked by PCPartPicker:", blocked.Error(), "user agent:", blocked.UserAgent, "proxy:", blocked.Proxy)
+	})
+
+	// Spread scraping and list building across the configured egress proxies
+	var proxies *proxy.Pool
+	if list := os.Getenv("KREAPC_PROXIES"); list != "" {
+		entries, err := proxy.ParseEntries(list)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if proxies, err = proxy.NewPool(entries); err != nil {
+			log.Fatal(err)
+		}
+		checkInterval, err := time.ParseDuration(envOrDefault("KREAPC_PROXY_CHECK_INTERVAL", "1m"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if checkInterval <= 0 {
+			log.Fatalf("KREAPC_PROXY_CHECK_INTERVAL must be positive, got %s", checkInterval)
+		}
+
+		proxies.CheckURL = site.BuildPrefixURL("") + "robots.txt"
+		proxies.Start(checkInterval)
+		defer proxies.Stop()
+		scrap.SetProxyPool(proxies)
+	}
+
+	// Build part lists over HTTP, keeping browsers running for the pages it can't handle
+	builder, browsers, err := listBuilderFromEnv(site, proxies)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if browsers != nil {
+		defer browsers.Stop()
+	}
+
+	// Manage the saved lists of the configured PCPartPicker account
+	account, err := accountFromEnv(site, dataDir, proxies)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Cache search, product and part list responses
+	cached, err := cachedScraperFromEnv(scrap, dataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	watchInterval, err := time.ParseDuration(envOrDefault("KREAPC_WATCH_INTERVAL", "6h"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	scheduler, err := tracker.NewScheduler(scrap, store, watchInterval)
+	if err != nil {
+		log.Fatal(err)
+	}
+	scheduler.Start()
+	defer scheduler.Stop()
+
 	// Create a Fiber app
 	app := fiber.New()
 	app.Use(helmet.New())
@@ -42,66 +577,448 @@ func main() {
 		if err := c.BodyParser(&req); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
 		}
+		if !validCurrency(converter, req.Currency) {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid currency"})
+		}
 
-		searchResults, err := scrap.SearchPCParts(req.Query, req.Region)
+		searchResults, info, err := cached.SearchPCParts(req.Query, req.Region, req.Page, req.Limit, bypassCache(c, req.NoCache))
 		if err != nil {
 			var redirectError *scraper.RedirectError
 			if errors.As(err, &redirectError) {
 				// Handle redirect to a single product page
-				part, err := scrap.GetPart(redirectError.Error())
+				part, info, err := cached.GetPart(redirectError.Error(), bypassCache(c, req.NoCache))
 				if err != nil {
-					return c.Status(500).JSON(fiber.Map{"error": "Error fetching product details"})
+					return scrapeError(c, err, "Error fetching product details")
+				}
+				setCacheHeaders(c, info)
+				if req.Currency != "" {
+					if err := converter.ConvertPart(part, req.Currency); err != nil {
+						return c.Status(500).JSON(fiber.Map{"error": "Error converting prices"})
+					}
 				}
 				return c.JSON(part)
 			}
-			return c.Status(500).JSON(fiber.Map{"error": "Error searching parts"})
+			return scrapeError(c, err, "Error searching parts")
 		}
 
+		setCacheHeaders(c, info)
+		if req.Currency != "" {
+			if err := converter.ConvertSearchResults(searchResults, req.Currency); err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": "Error converting prices"})
+			}
+		}
 		return c.JSON(searchResults)
 	})
 
+	// Endpoint for browsing the product listing of a category
+	app.Post("/browseCategory", func(c *fiber.Ctx) error {
+		var req CategoryRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+		if !req.Category.Valid() {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid category"})
+		}
+
+		results, err := scrap.BrowseCategory(req.Category, req.Region, req.Filter, req.Page, req.Limit)
+		if err != nil {
+			return scrapeError(c, err, "Error browsing category")
+		}
+		return c.JSON(results)
+	})
+
 	// Endpoint for getting details of a single part
 	app.Post("/getPart", func(c *fiber.Ctx) error {
-		var req URLRequest
+		var req PartRequest
 		if err := c.BodyParser(&req); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
 		}
+		if !validCurrency(converter, req.Currency) {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid currency"})
+		}
 
-		part, err := scrap.GetPart(req.URL)
+		part, info, err := cached.GetPart(req.URL, bypassCache(c, req.NoCache))
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Error fetching part"})
+			return scrapeError(c, err, "Error fetching part")
+		}
+		setCacheHeaders(c, info)
+		if req.Currency != "" {
+			if err := converter.ConvertPart(part, req.Currency); err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": "Error converting prices"})
+			}
 		}
 		return c.JSON(part)
 	})
 
+	// Endpoint for comparing the prices of a single part across regions
+	app.Post("/compareRegions", func(c *fiber.Ctx) error {
+		var req CompareRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+		if req.Currency == "" && converter != nil {
+			req.Currency = "USD"
+		}
+		if !validCurrency(converter, req.Currency) {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid currency"})
+		}
+
+		comparison, err := scrap.CompareRegions(req.URL, req.Regions)
+		if err != nil {
+			return scrapeError(c, err, "Error comparing regions")
+		}
+		if req.Currency != "" {
+			if err := converter.ConvertComparison(comparison, req.Currency); err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": "Error converting prices"})
+			}
+		}
+		return c.JSON(comparison)
+	})
+
+	// Endpoint for adding a part to the watch list
+	app.Post("/watch", func(c *fiber.Ctx) error {
+		var req WatchRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+		if !site.MatchProductURL(req.URL) {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid part URL"})
+		}
+
+		watch := tracker.Watch{
+			URL:     req.URL,
+			AddedAt: time.Now().UTC(),
+		}
+		if req.Interval != "" {
+			interval, err := time.ParseDuration(req.Interval)
+			if err != nil || interval <= 0 {
+				return c.Status(400).JSON(fiber.Map{"error": "Invalid interval"})
+			}
+			watch.Interval = interval
+		}
+
+		if err := store.AddWatch(watch); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Error saving watched part"})
+		}
+		return c.JSON(watch)
+	})
+
+	// Endpoint for removing a part from the watch list
+	app.Post("/unwatch", func(c *fiber.Ctx) error {
+		var req URLRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+
+		if err := store.RemoveWatch(req.URL); err != nil {
+			if errors.Is(err, tracker.ErrNotWatched) {
+				return c.Status(404).JSON(fiber.Map{"error": "Part is not watched"})
+			}
+			return c.Status(500).JSON(fiber.Map{"error": "Error removing watched part"})
+		}
+		return c.SendStatus(204)
+	})
+
+	// Endpoint for listing the watched parts
+	app.Get("/watches", func(c *fiber.Ctx) error {
+		watches, err := store.Watches()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Error loading watched parts"})
+		}
+		return c.JSON(watches)
+	})
+
+	// Endpoint for getting the prices recorded for a part
+	app.Post("/getTrackedHistory", func(c *fiber.Ctx) error {
+		var req URLRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+
+		records, err := store.History(req.URL)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Error loading recorded prices"})
+		}
+		return c.JSON(records)
+	})
+
+	// Endpoint for adding a price drop or back in stock alert
+	app.Post("/alerts", func(c *fiber.Ctx) error {
+		var req AlertRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+
+		rule, err := alertManager.AddRule(alerts.Rule{
+			URL:        req.URL,
+			Region:     req.Region,
+			Vendor:     req.Vendor,
+			Event:      req.Event,
+			Threshold:  req.Threshold,
+			WebhookURL: req.WebhookURL,
+			Secret:     req.Secret,
+		})
+		if err != nil {
+			if errors.Is(err, alerts.ErrInvalidRule) {
+				return c.Status(400).JSON(fiber.Map{"error": "Invalid alert rule"})
+			}
+			return c.Status(500).JSON(fiber.Map{"error": "Error saving alert rule"})
+		}
+		// Only a secret generated for the rule is returned, once
+		if req.Secret != "" {
+			rule.Secret = ""
+		}
+		return c.JSON(rule)
+	})
+
+	// Endpoint for listing the alert rules
+	app.Get("/alerts", func(c *fiber.Ctx) error {
+		return c.JSON(alertManager.Rules())
+	})
+
+	// Endpoint for removing an alert rule
+	app.Post("/removeAlert", func(c *fiber.Ctx) error {
+		var req IDRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+
+		if err := alertManager.RemoveRule(req.ID); err != nil {
+			if errors.Is(err, alerts.ErrRuleNotFound) {
+				return c.Status(404).JSON(fiber.Map{"error": "Alert rule not found"})
+			}
+			return c.Status(500).JSON(fiber.Map{"error": "Error removing alert rule"})
+		}
+		return c.SendStatus(204)
+	})
+
+	// Endpoint for reading the webhook delivery log
+	app.Get("/alertDeliveries", func(c *fiber.Ctx) error {
+		return c.JSON(notifier.Deliveries())
+	})
+
+	// Endpoint for reading scraping metrics
+	app.Get("/metrics", func(c *fiber.Ctx) error {
+		metrics := fiber.Map{
+			"coalescing": scrap.CoalesceStats(),
+			"blocks":     scrap.BlockStats(),
+		}
+		if proxies != nil {
+			metrics["proxies"] = proxies.Stats()
+		}
+		if browsers != nil {
+			metrics["browsers"] = browsers.Stats()
+		}
+		return c.JSON(metrics)
+	})
+
 	// Endpoint for getting details of a list of parts
 	app.Post("/getPartList", func(c *fiber.Ctx) error {
-		var req URLRequest
+		var req PartRequest
 		if err := c.BodyParser(&req); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
 		}
+		if !validCurrency(converter, req.Currency) {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid currency"})
+		}
 
-		part, err := scrap.GetPartList(req.URL)
+		part, info, err := cached.GetPartList(req.URL, bypassCache(c, req.NoCache))
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Error fetching part"})
+			return scrapeError(c, err, "Error fetching part")
+		}
+		setCacheHeaders(c, info)
+		if req.Currency != "" {
+			if err := converter.ConvertPartList(part, req.Currency); err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": "Error converting prices"})
+			}
 		}
 		return c.JSON(part)
 	})
 
+	// Endpoint for checking the compatibility of a set of parts, given directly or by URL
+	app.Post("/compatibility", func(c *fiber.Ctx) error {
+		var req PartsRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+
+		parts, err := collectParts(scrap, req)
+		if err != nil {
+			return scrapeError(c, err, "Error fetching part")
+		}
+		return c.JSON(compat.Check(parts))
+	})
+
+	// Endpoint for estimating the power draw of a set of parts, given directly or by URL
+	app.Post("/wattage", func(c *fiber.Ctx) error {
+		var req WattageRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+
+		estimator := wattage.NewEstimator()
+		if req.Headroom != nil {
+			if *req.Headroom < 0 {
+				return c.Status(400).JSON(fiber.Map{"error": "Invalid headroom"})
+			}
+			estimator.Headroom = *req.Headroom
+		}
+
+		parts, err := collectParts(scrap, req.PartsRequest)
+		if err != nil {
+			return scrapeError(c, err, "Error fetching part")
+		}
+		return c.JSON(estimator.Estimate(parts))
+	})
+
 	// Endpoint for getting details of a list of parts
 	app.Post("/generatePCPPList", func(c *fiber.Ctx) error {
-		var req URLsRequest
+		var req ListRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+		items := make([]models.ListItem, 0, len(req.URLs)+len(req.Items))
+		for _, URL := range req.URLs {
+			items = append(items, models.ListItem{URL: URL, Quantity: 1})
+		}
+		items = append(items, req.Items...)
+
+		list, err := builder.ProcessListItems(req.Region, items)
+		if err != nil {
+			return listBuildError(c, err)
+		}
+
+		part, err := scrap.GetPartList(list.URL)
+		if err != nil {
+			return scrapeError(c, err, "Error fetching part")
+		}
+		return c.JSON(part)
+	})
+
+	// Endpoint for adding, removing and swapping parts of a list, returning the new list
+	app.Post("/editPCPPList", func(c *fiber.Ctx) error {
+		var req EditListRequest
 		if err := c.BodyParser(&req); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
 		}
-		list, err := pcpartpicker_automation.ProcessPartLinks(req.Region, req.URLs)
+
+		original, err := scrap.GetPartList(req.URL)
+		if err != nil {
+			return scrapeError(c, err, "Error fetching part list")
+		}
+		list, err := pcpartpicker_automation.EditList(builder, site, original, req.Edits)
+		if err != nil {
+			return listBuildError(c, err)
+		}
+
 		part, err := scrap.GetPartList(list.URL)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Error fetching part"})
+			return scrapeError(c, err, "Error fetching part list")
 		}
 		return c.JSON(part)
 	})
 
+	// The saved list endpoints need the PCPartPicker account
+	requireAccount := func(c *fiber.Ctx) error {
+		if account == nil {
+			return c.Status(503).JSON(fiber.Map{"error": "No PCPartPicker account configured"})
+		}
+		return c.Next()
+	}
+
+	// Endpoint for listing the part lists saved to the PCPartPicker account
+	app.Get("/savedLists", requireAccount, func(c *fiber.Ctx) error {
+		lists, err := account.SavedLists()
+		if err != nil {
+			return accountError(c, err)
+		}
+		return c.JSON(lists)
+	})
+
+	// Endpoint for saving a part list to the PCPartPicker account
+	app.Post("/saveList", requireAccount, func(c *fiber.Ctx) error {
+		var req SavedListRequest
+		if err := c.BodyParser(&req); err != nil || req.Name == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+
+		var items []models.ListItem
+		if req.URL != "" {
+			list, err := scrap.GetPartList(req.URL)
+			if err != nil {
+				return scrapeError(c, err, "Error fetching part list")
+			}
+			if items, err = pcpartpicker_automation.ApplyEdits(site, list, nil); err != nil {
+				return accountError(c, err)
+			}
+		}
+		for _, URL := range req.URLs {
+			items = append(items, models.ListItem{URL: URL, Quantity: 1})
+		}
+		items = append(items, req.Items...)
+
+		saved, err := account.SaveList(req.Name, items)
+		if err != nil {
+			return accountError(c, err)
+		}
+		return c.Status(201).JSON(saved)
+	})
+
+	// Endpoint for getting the parts of a saved list, including private ones
+	app.Post("/getSavedList", requireAccount, func(c *fiber.Ctx) error {
+		var req IDRequest
+		if err := c.BodyParser(&req); err != nil || req.ID == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+
+		lists, err := account.SavedLists()
+		if err != nil {
+			return accountError(c, err)
+		}
+		for _, saved := range lists {
+			if saved.ID != req.ID {
+				continue
+			}
+			// The list is fetched with the session and proxy of the account
+			jar, proxyURL, err := account.Session()
+			if err != nil {
+				return accountError(c, err)
+			}
+			part, err := scrap.GetSavedPartList(saved.URL, scraper.Session{Jar: jar, Proxy: proxyURL, UserAgent: account.UserAgent})
+			if err != nil {
+				return scrapeError(c, err, "Error fetching part list")
+			}
+			return c.JSON(part)
+		}
+		return accountError(c, pcpartpicker_automation.ErrNoSavedList)
+	})
+
+	// Endpoint for renaming a saved list
+	app.Post("/renameSavedList", requireAccount, func(c *fiber.Ctx) error {
+		var req RenameRequest
+		if err := c.BodyParser(&req); err != nil || req.ID == "" || req.Name == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+		if err := account.RenameList(req.ID, req.Name); err != nil {
+			return accountError(c, err)
+		}
+		return c.SendStatus(204)
+	})
+
+	// Endpoint for deleting a saved list
+	app.Post("/deleteSavedList", requireAccount, func(c *fiber.Ctx) error {
+		var req IDRequest
+		if err := c.BodyParser(&req); err != nil || req.ID == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request payload"})
+		}
+		if err := account.DeleteList(req.ID); err != nil {
+			return accountError(c, err)
+		}
+		return c.SendStatus(204)
+	})
+
 	// Start the server
-	log.Fatal(app.Listen(":4321"))
+	if err := app.Listen(":4321"); err != nil {
+		log.Println(err)
+	}
 }
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/Aquilabot/KreaPC-API/pkg/scraper"
+	"github.com/gofiber/fiber/v2"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestScrapeErrorSetsRetryAfter(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		return scrapeError(c, &scraper.Error{Kind: scraper.KindRateLimited, RetryAfter: 1500 * time.Millisecond}, "Error fetching part")
+	})
+
+	res, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 429 || res.Header.Get("Retry-After") != "2" {
+		t.Errorf("expected a 429 retrying after 2s, got %d and %q", res.StatusCode, res.Header.Get("Retry-After"))
+	}
+}
+
+func TestSiteFromEnvTrimsRegions(t *testing.T) {
+	t.Setenv("PCPP_SCHEME", "")
+	t.Setenv("PCPP_HOST", "pcpartpicker.test")
+	t.Setenv("PCPP_REGIONS", "uk, DE ,")
+
+	site, err := siteFromEnv()
+	if err != nil {
+		t.Fatalf("siteFromEnv: %v", err)
+	}
+	if site.Scheme != "https" || !slices.Equal(site.Regions, []string{"uk", "de"}) {
+		t.Errorf("unexpected site %s with regions %q", site.Scheme, site.Regions)
+	}
+	if !site.MatchPCPPURL("https://de.pcpartpicker.test/list/abcd12") {
+		t.Error("expected the de region to be accepted")
+	}
+}
@@ -0,0 +1,318 @@
+package alerts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"github.com/gofiber/fiber/v2/log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EventPriceDrop   = "price_drop"
+	EventBackInStock = "back_in_stock"
+)
+
+var (
+	ErrRuleNotFound = errors.New("alert rule not found")
+	ErrInvalidRule  = errors.New("invalid alert rule")
+)
+
+// Rule describes when an alert is sent for a product. An empty Region keeps the
+// region of the product URL and an empty Vendor matches every vendor.
+// Threshold is only used by price drop rules, in the currency of the region.
+type Rule struct {
+	ID         string
+	URL        string
+	Region     string
+	Vendor     string
+	Event      string
+	Threshold  float64
+	WebhookURL string
+	Secret     string `json:",omitempty"`
+	CreatedAt  time.Time
+}
+
+// Alert is the payload posted to the webhook of a rule.
+type Alert struct {
+	Event     string
+	RuleID    string
+	Name      string
+	URL       string
+	Vendor    models.Vendor
+	Threshold float64
+	Time      time.Time
+}
+
+// vendorState is the last seen state of a vendor for a rule.
+type vendorState struct {
+	InStock    bool
+	BelowPrice bool
+}
+
+// Manager keeps the alert rules and checks them against scraped parts.
+// Rules are saved to a JSON file when a path is given, and the last seen
+// state of the vendors next to it, so alerts aren't sent again on restart.
+type Manager struct {
+	site      *utils.Site
+	notifier  *Notifier
+	path      string
+	statePath string
+
+	mu     sync.Mutex
+	rules  map[string]Rule
+	states map[string]vendorState
+}
+
+// NewManager creates a Manager delivering alerts through notifier.
+// When path is not empty the rules are loaded from and saved to that file.
+func NewManager(site *utils.Site, notifier *Notifier, path string) (*Manager, error) {
+	m := &Manager{
+		site:     site,
+		notifier: notifier,
+		path:     path,
+		rules:    map[string]Rule{},
+		states:   map[string]vendorState{},
+	}
+
+	if path == "" {
+		return m, nil
+	}
+	m.statePath = strings.TrimSuffix(path, filepath.Ext(path)) + ".state.json"
+
+	var rules []Rule
+	if err := readJSON(path, &rules); err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		m.rules[rule.ID] = rule
+	}
+	if err := readJSON(m.statePath, &m.states); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// readJSON decodes the JSON file at path into v, leaving v unchanged when the
+// file doesn't exist.
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSON writes v to a temporary file and renames it over the file at path.
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// AddRule validates the rule, gives it an ID and saves it. A rule without a
+// secret gets a random one when the notifier has no default secret, so every
+// webhook is signed; the caller must hand it to the webhook receiver.
+func (m *Manager) AddRule(rule Rule) (Rule, error) {
+	if !m.site.MatchProductURL(rule.URL) || rule.WebhookURL == "" {
+		return Rule{}, ErrInvalidRule
+	}
+	if rule.Event != EventPriceDrop && rule.Event != EventBackInStock {
+		return Rule{}, ErrInvalidRule
+	}
+	if rule.Event == EventPriceDrop && rule.Threshold <= 0 {
+		return Rule{}, ErrInvalidRule
+	}
+	if webhook, err := url.Parse(rule.WebhookURL); err != nil || (webhook.Scheme != "http" && webhook.Scheme != "https") {
+		return Rule{}, ErrInvalidRule
+	}
+	if rule.Region != "" {
+		rule.URL = m.site.RegionalProductURL(rule.URL, rule.Region)
+		if !m.site.MatchProductURL(rule.URL) {
+			return Rule{}, ErrInvalidRule
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Rule{}, err
+	}
+	rule.ID = hex.EncodeToString(id)
+	rule.CreatedAt = time.Now().UTC()
+
+	if rule.Secret == "" && (m.notifier == nil || m.notifier.Secret == "") {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return Rule{}, err
+		}
+		rule.Secret = hex.EncodeToString(secret)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rules[rule.ID] = rule
+	return rule, m.save()
+}
+
+func (m *Manager) RemoveRule(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rules[id]; !ok {
+		return ErrRuleNotFound
+	}
+	delete(m.rules, id)
+	for key := range m.states {
+		if strings.HasPrefix(key, id+"|") {
+			delete(m.states, key)
+		}
+	}
+	if err := m.save(); err != nil {
+		return err
+	}
+	return m.saveStates()
+}
+
+// Rules returns the rules sorted by creation time, without their secrets.
+func (m *Manager) Rules() []Rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rules := make([]Rule, 0, len(m.rules))
+	for _, rule := range m.rules {
+		rule.Secret = ""
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+	return rules
+}
+
+// save writes the rules to the file of the Manager. The caller must hold m.mu.
+func (m *Manager) save() error {
+	if m.path == "" {
+		return nil
+	}
+
+	rules := make([]Rule, 0, len(m.rules))
+	for _, rule := range m.rules {
+		rules = append(rules, rule)
+	}
+	return writeJSON(m.path, rules)
+}
+
+// saveStates writes the vendor states to the state file of the Manager. The
+// caller must hold m.mu.
+func (m *Manager) saveStates() error {
+	if m.statePath == "" {
+		return nil
+	}
+	return writeJSON(m.statePath, m.states)
+}
+
+// productKey identifies a product on a region, regardless of the URL scheme and slug.
+func (m *Manager) productKey(URL string) string {
+	id := m.site.ExtractProductID(URL)
+	if id == "" {
+		return ""
+	}
+	if !strings.Contains(URL, "://") {
+		URL = "https://" + URL
+	}
+	parsed, err := url.Parse(URL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname() + "/" + id
+}
+
+// Check compares the vendors of a freshly scraped part with the rules of that
+// product and sends an alert when a price falls below the threshold or a vendor
+// gets back in stock. A vendor seen for the first time counts as out of stock
+// and above the threshold. An alert is only sent again once the condition was
+// false in between. It can be registered with scraper.Scraper.OnPartScraped.
+func (m *Manager) Check(part *models.Part) {
+	key := m.productKey(part.URL)
+	if key == "" {
+		return
+	}
+
+	var alerts []Alert
+	var rules []Rule
+	changed := false
+
+	m.mu.Lock()
+	for _, rule := range m.rules {
+		if m.productKey(rule.URL) != key {
+			continue
+		}
+
+		for _, vendor := range part.Vendors {
+			if rule.Vendor != "" && !strings.EqualFold(rule.Vendor, vendor.Name) {
+				continue
+			}
+
+			stateKey := rule.ID + "|" + vendor.Name
+			previous, seen := m.states[stateKey]
+			current := vendorState{
+				InStock:    vendor.InStock,
+				BelowPrice: vendor.InStock && vendor.Price.Total > 0 && vendor.Price.Total <= rule.Threshold,
+			}
+			if !seen || current != previous {
+				m.states[stateKey] = current
+				changed = true
+			}
+
+			triggered := false
+			switch rule.Event {
+			case EventPriceDrop:
+				triggered = current.BelowPrice && !previous.BelowPrice
+			case EventBackInStock:
+				triggered = current.InStock && !previous.InStock
+			}
+			if !triggered {
+				continue
+			}
+
+			alerts = append(alerts, Alert{
+				Event:     rule.Event,
+				RuleID:    rule.ID,
+				Name:      part.Name,
+				URL:       part.URL,
+				Vendor:    vendor,
+				Threshold: rule.Threshold,
+				Time:      time.Now().UTC(),
+			})
+			rules = append(rules, rule)
+		}
+	}
+	if changed {
+		if err := m.saveStates(); err != nil {
+			log.Errorf("Could not save the state of alerts: %v", err)
+		}
+	}
+	m.mu.Unlock()
+
+	for i, alert := range alerts {
+		go m.notifier.Send(rules[i], alert)
+	}
+}
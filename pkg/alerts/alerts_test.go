@@ -0,0 +1,256 @@
+package alerts
+
+import (
+	"encoding/json"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testSite = utils.MustNewSite("https", "pcpartpicker.com", nil)
+
+type webhookCall struct {
+	header http.Header
+	body   []byte
+}
+
+// newWebhook returns a webhook answering with the given statuses in turn, then
+// 200, and the channel its calls are sent to.
+func newWebhook(t *testing.T, statuses ...int) (*httptest.Server, chan webhookCall) {
+	calls := make(chan webhookCall, 10)
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls <- webhookCall{header: r.Header, body: body}
+		if i := int(count.Add(1)) - 1; i < len(statuses) {
+			w.WriteHeader(statuses[i])
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, calls
+}
+
+func testNotifier() *Notifier {
+	notifier := NewNotifier("default-secret")
+	notifier.Backoff = time.Millisecond
+	notifier.MaxAttempts = 3
+	return notifier
+}
+
+func TestSendSignsTheBody(t *testing.T) {
+	server, calls := newWebhook(t)
+	notifier := testNotifier()
+
+	for _, test := range []struct {
+		rule   Rule
+		secret string
+	}{
+		{Rule{ID: "a", WebhookURL: server.URL, Secret: "rule-secret"}, "rule-secret"},
+		{Rule{ID: "b", WebhookURL: server.URL}, "default-secret"},
+	} {
+		delivery := notifier.Send(test.rule, Alert{Event: EventPriceDrop, RuleID: test.rule.ID})
+		if !delivery.Delivered || delivery.Attempts != 1 {
+			t.Fatalf("unexpected delivery %+v", delivery)
+		}
+
+		call := <-calls
+		timestamp := call.header.Get(timestampHeader)
+		if signature := call.header.Get(signatureHeader); signature != Sign(test.secret, timestamp, call.body) {
+			t.Errorf("rule %s: signature %q doesn't match the body signed with %q", test.rule.ID, signature, test.secret)
+		}
+		if call.header.Get(eventHeader) != EventPriceDrop {
+			t.Errorf("unexpected event header %q", call.header.Get(eventHeader))
+		}
+		var alert Alert
+		if err := json.Unmarshal(call.body, &alert); err != nil || alert.RuleID != test.rule.ID {
+			t.Errorf("unexpected body %s", call.body)
+		}
+	}
+}
+
+func TestSignIsHMACOfTimestampAndBody(t *testing.T) {
+	// echo -n '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+	if got := Sign("secret", "1700000000", []byte("{}")); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestSendRetriesFailedDeliveries(t *testing.T) {
+	server, calls := newWebhook(t, http.StatusInternalServerError, http.StatusBadGateway)
+	notifier := testNotifier()
+	notifier.Backoff = 20 * time.Millisecond
+
+	start := time.Now()
+	delivery := notifier.Send(Rule{ID: "a", WebhookURL: server.URL}, Alert{Event: EventBackInStock})
+	if !delivery.Delivered || delivery.Attempts != 3 || delivery.StatusCode != http.StatusOK || delivery.Error != "" {
+		t.Errorf("unexpected delivery %+v", delivery)
+	}
+	if len(calls) != 3 {
+		t.Errorf("expected 3 calls, got %d", len(calls))
+	}
+	// 20ms then 40ms
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("expected an exponential backoff, retried within %s", elapsed)
+	}
+}
+
+func TestSendGivesUpAfterMaxAttempts(t *testing.T) {
+	server, calls := newWebhook(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusNotFound, http.StatusOK)
+	notifier := testNotifier()
+
+	delivery := notifier.Send(Rule{ID: "a", WebhookURL: server.URL}, Alert{Event: EventBackInStock})
+	if delivery.Delivered || delivery.Attempts != 3 || delivery.StatusCode != http.StatusNotFound || delivery.Error == "" {
+		t.Errorf("unexpected delivery %+v", delivery)
+	}
+	if len(calls) != 3 {
+		t.Errorf("expected 3 calls, got %d", len(calls))
+	}
+}
+
+func TestDeliveryLog(t *testing.T) {
+	server, _ := newWebhook(t, http.StatusOK, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	notifier := testNotifier()
+	notifier.LogSize = 2
+
+	notifier.Send(Rule{ID: "a", WebhookURL: server.URL}, Alert{Event: EventPriceDrop})
+	notifier.Send(Rule{ID: "b", WebhookURL: server.URL}, Alert{Event: EventBackInStock})
+	notifier.Send(Rule{ID: "c", WebhookURL: server.URL}, Alert{Event: EventPriceDrop})
+
+	deliveries := notifier.Deliveries()
+	if len(deliveries) != 2 {
+		t.Fatalf("expected the log to keep 2 deliveries, got %d", len(deliveries))
+	}
+	failed, delivered := deliveries[0], deliveries[1]
+	if failed.RuleID != "b" || failed.Event != EventBackInStock || failed.Delivered || failed.Attempts != 3 || failed.StatusCode != 500 || failed.WebhookURL != server.URL {
+		t.Errorf("unexpected failed delivery %+v", failed)
+	}
+	if delivered.RuleID != "c" || !delivered.Delivered || delivered.Attempts != 1 || delivered.Time.IsZero() {
+		t.Errorf("unexpected delivery %+v", delivered)
+	}
+}
+
+// receive returns the events of the alerts sent to the webhook within a short wait.
+func receive(calls chan webhookCall) []string {
+	var events []string
+	for {
+		select {
+		case call := <-calls:
+			var alert Alert
+			json.Unmarshal(call.body, &alert)
+			events = append(events, alert.Event+" "+alert.Vendor.Name)
+		case <-time.After(50 * time.Millisecond):
+			return events
+		}
+	}
+}
+
+func part(vendors ...models.Vendor) *models.Part {
+	return &models.Part{Name: "CPU", URL: "https://pcpartpicker.com/product/Yg3mP6/cpu", Vendors: vendors}
+}
+
+func vendor(name string, price float64, inStock bool) models.Vendor {
+	return models.Vendor{Name: name, InStock: inStock, Price: models.Price{Total: price}}
+}
+
+func TestCheckTriggersOnEdges(t *testing.T) {
+	server, calls := newWebhook(t)
+	dir := t.TempDir()
+	manager, err := NewManager(testSite, testNotifier(), filepath.Join(dir, "alerts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.AddRule(Rule{URL: "https://pcpartpicker.com/product/Yg3mP6/cpu", Event: EventPriceDrop, Threshold: 300, WebhookURL: server.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.AddRule(Rule{URL: "https://pcpartpicker.com/product/Yg3mP6/cpu", Vendor: "amazon", Event: EventBackInStock, WebhookURL: server.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct {
+		name string
+		part *models.Part
+		want []string
+	}{
+		{"first seen", part(vendor("Amazon", 320, true), vendor("Newegg", 290, true)), []string{"back_in_stock Amazon", "price_drop Newegg"}},
+		{"unchanged", part(vendor("Amazon", 320, true), vendor("Newegg", 280, true)), nil},
+		{"out of stock", part(vendor("Amazon", 290, false), vendor("Newegg", 280, false)), nil},
+		{"back in stock", part(vendor("Amazon", 290, true), vendor("Newegg", 310, true)), []string{"back_in_stock Amazon", "price_drop Amazon"}},
+		{"price back up", part(vendor("Amazon", 310, true), vendor("Newegg", 310, true)), nil},
+		{"price drop", part(vendor("Amazon", 310, true), vendor("Newegg", 300, true)), []string{"price_drop Newegg"}},
+	} {
+		manager.Check(step.part)
+		if events := receive(calls); !sameEvents(events, step.want) {
+			t.Errorf("%s: expected %v, got %v", step.name, step.want, events)
+		}
+	}
+
+	// The states are kept across restarts
+	restarted, err := NewManager(testSite, testNotifier(), filepath.Join(dir, "alerts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.Check(part(vendor("Amazon", 310, true), vendor("Newegg", 300, true)))
+	if events := receive(calls); len(events) != 0 {
+		t.Errorf("expected no alert after a restart, got %v", events)
+	}
+	restarted.Check(part(vendor("Amazon", 250, true)))
+	if events := receive(calls); !sameEvents(events, []string{"price_drop Amazon"}) {
+		t.Errorf("expected a price drop after a restart, got %v", events)
+	}
+}
+
+func sameEvents(got []string, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	counts := map[string]int{}
+	for _, event := range got {
+		counts[event]++
+	}
+	for _, event := range want {
+		counts[event]--
+	}
+	for _, count := range counts {
+		if count != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAddRuleGeneratesASecretWithoutDefault(t *testing.T) {
+	rule := Rule{URL: "https://pcpartpicker.com/product/Yg3mP6/cpu", Event: EventPriceDrop, Threshold: 300, WebhookURL: "http://hooks.test/"}
+	for _, test := range []struct {
+		name      string
+		notifier  *Notifier
+		secret    string
+		generated bool
+	}{
+		{"no secret", NewNotifier(""), "", true},
+		{"rule secret", NewNotifier(""), "rule-secret", false},
+		{"default secret", NewNotifier("default-secret"), "", false},
+	} {
+		manager, err := NewManager(testSite, test.notifier, filepath.Join(t.TempDir(), "alerts.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rule.Secret = test.secret
+		added, err := manager.AddRule(rule)
+		if err != nil {
+			t.Fatalf("%s: AddRule: %v", test.name, err)
+		}
+		if generated := added.Secret != test.secret; generated != test.generated {
+			t.Errorf("%s: expected a generated secret %v, got %q", test.name, test.generated, added.Secret)
+		}
+		if test.generated && len(added.Secret) != 64 {
+			t.Errorf("%s: expected a 32 bytes secret, got %q", test.name, added.Secret)
+		}
+	}
+}
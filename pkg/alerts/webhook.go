@@ -0,0 +1,158 @@
+package alerts
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2/log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	signatureHeader = "X-KreaPC-Signature"
+	timestampHeader = "X-KreaPC-Timestamp"
+	eventHeader     = "X-KreaPC-Event"
+)
+
+// Delivery is an entry of the delivery log of a Notifier.
+type Delivery struct {
+	RuleID     string
+	Event      string
+	WebhookURL string
+	Attempts   int
+	StatusCode int
+	Error      string `json:",omitempty"`
+	Delivered  bool
+	Time       time.Time
+}
+
+// Notifier posts alerts to webhooks. The body is signed with HMAC-SHA256 using
+// the rule secret, or Secret when the rule has none: the X-KreaPC-Signature
+// header holds "sha256=" followed by the hex digest of the X-KreaPC-Timestamp
+// header, a dot and the body. Failed deliveries are retried with an exponential backoff.
+type Notifier struct {
+	Client      *http.Client
+	Secret      string
+	MaxAttempts int
+	Backoff     time.Duration
+	LogSize     int
+
+	mu  sync.Mutex
+	log []Delivery
+}
+
+// NewNotifier returns a Notifier signing with secret, trying each delivery 5 times
+// starting with a 1 second backoff and keeping the last 500 deliveries.
+func NewNotifier(secret string) *Notifier {
+	return &Notifier{
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		Secret:      secret,
+		MaxAttempts: 5,
+		Backoff:     time.Second,
+		LogSize:     500,
+	}
+}
+
+// Sign returns the signature of a webhook body sent at the given unix timestamp.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send delivers the alert to the webhook of the rule, retrying until it gets a
+// 2xx response or runs out of attempts, and adds the outcome to the delivery log.
+func (n *Notifier) Send(rule Rule, alert Alert) Delivery {
+	delivery := Delivery{
+		RuleID:     rule.ID,
+		Event:      alert.Event,
+		WebhookURL: rule.WebhookURL,
+	}
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		delivery.Error = err.Error()
+		return n.record(delivery)
+	}
+
+	secret := rule.Secret
+	if secret == "" {
+		secret = n.Secret
+	}
+
+	backoff := n.Backoff
+	for delivery.Attempts < n.MaxAttempts {
+		if delivery.Attempts > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		delivery.Attempts++
+
+		statusCode, err := n.post(rule.WebhookURL, alert.Event, secret, body)
+		delivery.StatusCode = statusCode
+		if err == nil {
+			delivery.Error = ""
+			delivery.Delivered = true
+			break
+		}
+		delivery.Error = err.Error()
+		log.Warnf("Webhook delivery to %s failed (attempt %d): %v", rule.WebhookURL, delivery.Attempts, err)
+	}
+
+	return n.record(delivery)
+}
+
+func (n *Notifier) post(webhookURL string, event string, secret string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(eventHeader, event)
+	req.Header.Set(timestampHeader, timestamp)
+	if secret != "" {
+		req.Header.Set(signatureHeader, Sign(secret, timestamp, body))
+	}
+
+	res, err := n.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+func (n *Notifier) record(delivery Delivery) Delivery {
+	delivery.Time = time.Now().UTC()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.log = append(n.log, delivery)
+	if n.LogSize > 0 && len(n.log) > n.LogSize {
+		n.log = n.log[len(n.log)-n.LogSize:]
+	}
+	return delivery
+}
+
+// Deliveries returns the delivery log, oldest first.
+func (n *Notifier) Deliveries() []Delivery {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([]Delivery{}, n.log...)
+}
@@ -0,0 +1,263 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2/log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a cached response, stored as JSON so every read gets its own copy.
+type Entry struct {
+	Value    json.RawMessage
+	StoredAt time.Time
+}
+
+// Backend stores cache entries.
+type Backend interface {
+	Get(key string) (Entry, bool, error)
+	Set(key string, entry Entry) error
+	Delete(key string) error
+}
+
+// MemoryBackend is a Backend keeping the most recently used entries in memory.
+type MemoryBackend struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	entries    map[string]*list.Element
+}
+
+type memoryItem struct {
+	key   string
+	entry Entry
+}
+
+// NewMemoryBackend returns a MemoryBackend evicting the least recently used
+// entry once it holds maxEntries entries.
+func NewMemoryBackend(maxEntries int) *MemoryBackend {
+	return &MemoryBackend{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+func (b *MemoryBackend) Get(key string) (Entry, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	elem, ok := b.entries[key]
+	if !ok {
+		return Entry{}, false, nil
+	}
+	b.order.MoveToFront(elem)
+	return elem.Value.(*memoryItem).entry, true, nil
+}
+
+func (b *MemoryBackend) Set(key string, entry Entry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elem, ok := b.entries[key]; ok {
+		elem.Value.(*memoryItem).entry = entry
+		b.order.MoveToFront(elem)
+		return nil
+	}
+
+	b.entries[key] = b.order.PushFront(&memoryItem{key: key, entry: entry})
+	for b.maxEntries > 0 && b.order.Len() > b.maxEntries {
+		oldest := b.order.Back()
+		b.order.Remove(oldest)
+		delete(b.entries, oldest.Value.(*memoryItem).key)
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Delete(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elem, ok := b.entries[key]; ok {
+		b.order.Remove(elem)
+		delete(b.entries, key)
+	}
+	return nil
+}
+
+// DiskBackend is a Backend storing each entry in its own file of a directory,
+// so cached responses survive restarts. Entries older than MaxAge are expired:
+// they are removed when read, and every expired entry is removed by Prune, which
+// Set runs in the background at most once every PruneInterval.
+type DiskBackend struct {
+	MaxAge        time.Duration
+	PruneInterval time.Duration
+
+	dir       string
+	mu        sync.Mutex
+	pruning   bool
+	lastPrune time.Time
+}
+
+// NewDiskBackend returns a DiskBackend storing entries in dir, creating it if
+// needed. Its entries never expire until MaxAge is set.
+func NewDiskBackend(dir string) (*DiskBackend, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DiskBackend{dir: dir, PruneInterval: 10 * time.Minute}, nil
+}
+
+func (b *DiskBackend) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(b.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (b *DiskBackend) Get(key string) (Entry, bool, error) {
+	data, err := os.ReadFile(b.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Entry{}, false, err
+	}
+	if b.expired(entry.StoredAt) {
+		return Entry{}, false, b.Delete(key)
+	}
+	return entry, true, nil
+}
+
+// Set writes the entry to a temporary file renamed over the previous one, so
+// concurrent readers never see a partial entry.
+func (b *DiskBackend) Set(key string, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(b.dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), b.path(key)); err != nil {
+		return err
+	}
+
+	b.prunePeriodically()
+	return nil
+}
+
+func (b *DiskBackend) expired(storedAt time.Time) bool {
+	return b.MaxAge > 0 && time.Since(storedAt) >= b.MaxAge
+}
+
+// prunePeriodically runs Prune in the background unless it ran less than
+// PruneInterval ago or is still running.
+func (b *DiskBackend) prunePeriodically() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.MaxAge <= 0 || b.pruning || time.Since(b.lastPrune) < b.PruneInterval {
+		return
+	}
+	b.pruning = true
+	b.lastPrune = time.Now()
+
+	go func() {
+		if _, err := b.Prune(); err != nil {
+			log.Warn("Could not prune the disk cache: ", err)
+		}
+		b.mu.Lock()
+		b.pruning = false
+		b.mu.Unlock()
+	}()
+}
+
+// Prune removes the expired entries, using the time their file was written, and
+// the temporary files left by interrupted writes. It returns the number of
+// removed entries.
+func (b *DiskBackend) Prune() (int, error) {
+	if b.MaxAge <= 0 {
+		return 0, nil
+	}
+
+	files, err := os.ReadDir(b.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, file := range files {
+		name := file.Name()
+		isEntry := strings.HasSuffix(name, ".json")
+		if !isEntry && !strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		info, err := file.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		if !b.expired(info.ModTime()) {
+			continue
+		}
+
+		err = os.Remove(filepath.Join(b.dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		if isEntry {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (b *DiskBackend) Delete(key string) error {
+	err := os.Remove(b.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// NopBackend is a Backend that stores nothing, disabling the cache.
+type NopBackend struct{}
+
+func (NopBackend) Get(key string) (Entry, bool, error) {
+	return Entry{}, false, nil
+}
+
+func (NopBackend) Set(key string, entry Entry) error {
+	return nil
+}
+
+func (NopBackend) Delete(key string) error {
+	return nil
+}
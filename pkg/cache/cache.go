@@ -0,0 +1,131 @@
+// Package cache caches scraped responses with a TTL per kind of data, serving
+// stale entries while they are refreshed in the background.
+package cache
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2/log"
+	"sync"
+	"time"
+)
+
+// Status tells how a response was served.
+type Status string
+
+const (
+	StatusHit    Status = "HIT"
+	StatusStale  Status = "STALE"
+	StatusMiss   Status = "MISS"
+	StatusBypass Status = "BYPASS"
+)
+
+// Policy is the freshness policy of a kind of data. Entries are fresh for TTL,
+// then served for Stale more while they are refreshed in the background.
+type Policy struct {
+	TTL   time.Duration
+	Stale time.Duration
+}
+
+// Info describes how a cached response was served.
+type Info struct {
+	Status Status
+	Age    time.Duration
+	Policy Policy
+}
+
+// Cache stores responses in a Backend.
+type Cache struct {
+	Backend Backend
+
+	mu           sync.Mutex
+	revalidating map[string]bool
+}
+
+// New returns a Cache storing responses in backend.
+func New(backend Backend) *Cache {
+	return &Cache{
+		Backend:      backend,
+		revalidating: map[string]bool{},
+	}
+}
+
+// load returns the cached value of key when it is fresh or stale, refreshing
+// stale values in the background, and calls fetch otherwise. bypass skips the
+// cached value but still stores the fetched one. Failed fetches are never cached.
+func load[T any](c *Cache, key string, policy Policy, bypass bool, fetch func() (*T, error)) (*T, Info, error) {
+	info := Info{Status: StatusMiss, Policy: policy}
+	if bypass {
+		info.Status = StatusBypass
+	} else if entry, ok := c.get(key); ok {
+		age := time.Since(entry.StoredAt)
+		if age < policy.TTL+policy.Stale {
+			var value T
+			if err := json.Unmarshal(entry.Value, &value); err == nil {
+				info.Age = age
+				info.Status = StatusHit
+				if age >= policy.TTL {
+					info.Status = StatusStale
+					c.revalidate(key, func() error {
+						_, err := fetchAndStore(c, key, fetch)
+						return err
+					})
+				}
+				return &value, info, nil
+			}
+		}
+	}
+
+	value, err := fetchAndStore(c, key, fetch)
+	if err != nil {
+		return nil, info, err
+	}
+	return value, info, nil
+}
+
+func fetchAndStore[T any](c *Cache, key string, fetch func() (*T, error)) (*T, error) {
+	value, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Backend.Set(key, Entry{Value: data, StoredAt: time.Now().UTC()}); err != nil {
+		log.Warn("Could not store cache entry ", key, ": ", err)
+	}
+	return value, nil
+}
+
+func (c *Cache) get(key string) (Entry, bool) {
+	entry, ok, err := c.Backend.Get(key)
+	if err != nil {
+		log.Warn("Could not read cache entry ", key, ": ", err)
+		return Entry{}, false
+	}
+	return entry, ok
+}
+
+// revalidate runs refresh in the background unless key is already being refreshed.
+func (c *Cache) revalidate(key string, refresh func() error) {
+	c.mu.Lock()
+	if c.revalidating[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.revalidating[key] = true
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.revalidating, key)
+			c.mu.Unlock()
+		}()
+
+		if err := refresh(); err != nil {
+			log.Warn("Could not revalidate cache entry ", key, ": ", err)
+		}
+	}()
+}
@@ -0,0 +1,321 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"github.com/Aquilabot/KreaPC-API/pkg/scraper"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type value struct {
+	N int32
+}
+
+func storedEntry(t *testing.T, n int32, age time.Duration) Entry {
+	t.Helper()
+	data, err := json.Marshal(value{N: n})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Entry{Value: data, StoredAt: time.Now().UTC().Add(-age)}
+}
+
+func TestMemoryBackendEvictsLeastRecentlyUsed(t *testing.T) {
+	backend := NewMemoryBackend(2)
+	backend.Set("a", storedEntry(t, 1, 0))
+	backend.Set("b", storedEntry(t, 2, 0))
+	if _, ok, _ := backend.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	backend.Set("c", storedEntry(t, 3, 0))
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok, _ := backend.Get(key); ok != want {
+			t.Errorf("expected %s cached to be %v", key, want)
+		}
+	}
+
+	// Updating an entry doesn't evict another one
+	backend.Set("c", storedEntry(t, 4, 0))
+	if _, ok, _ := backend.Get("a"); !ok {
+		t.Error("expected a to stay cached")
+	}
+	backend.Delete("a")
+	if _, ok, _ := backend.Get("a"); ok {
+		t.Error("expected a to be deleted")
+	}
+}
+
+// counter fetches the number of times it was called.
+type counter struct {
+	calls atomic.Int32
+	fail  atomic.Bool
+}
+
+func (c *counter) fetch() (*value, error) {
+	n := c.calls.Add(1)
+	if c.fail.Load() {
+		return nil, errors.New("fetch failed")
+	}
+	return &value{N: n}, nil
+}
+
+func TestLoadServesFreshEntries(t *testing.T) {
+	c := New(NewMemoryBackend(10))
+	policy := Policy{TTL: time.Minute, Stale: time.Minute}
+	fetcher := &counter{}
+
+	for i, want := range []Status{StatusMiss, StatusHit, StatusHit} {
+		got, info, err := load(c, "key", policy, false, fetcher.fetch)
+		if err != nil || got.N != 1 || info.Status != want || info.Policy != policy {
+			t.Errorf("load %d: got %+v, %+v, %v, want %s", i, got, info, err, want)
+		}
+	}
+	if fetcher.calls.Load() != 1 {
+		t.Errorf("expected a single fetch, got %d", fetcher.calls.Load())
+	}
+}
+
+func TestLoadRevalidatesStaleEntries(t *testing.T) {
+	backend := NewMemoryBackend(10)
+	c := New(backend)
+	policy := Policy{TTL: time.Minute, Stale: time.Hour}
+	backend.Set("key", storedEntry(t, 0, 2*time.Minute))
+	fetcher := &counter{}
+
+	got, info, err := load(c, "key", policy, false, fetcher.fetch)
+	if err != nil || got.N != 0 || info.Status != StatusStale || info.Age < 2*time.Minute {
+		t.Fatalf("expected the stale value, got %+v, %+v, %v", got, info, err)
+	}
+
+	// The entry is refreshed in the background
+	deadline := time.Now().Add(time.Second)
+	for {
+		got, info, _ = load(c, "key", policy, false, fetcher.fetch)
+		if info.Status == StatusHit || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got.N != 1 || info.Status != StatusHit || fetcher.calls.Load() != 1 {
+		t.Errorf("expected the refreshed value, got %+v, %+v after %d fetches", got, info, fetcher.calls.Load())
+	}
+}
+
+func TestLoadFetchesExpiredEntries(t *testing.T) {
+	backend := NewMemoryBackend(10)
+	c := New(backend)
+	backend.Set("key", storedEntry(t, 0, 2*time.Hour))
+	fetcher := &counter{}
+
+	got, info, err := load(c, "key", Policy{TTL: time.Minute, Stale: time.Hour}, false, fetcher.fetch)
+	if err != nil || got.N != 1 || info.Status != StatusMiss {
+		t.Errorf("expected a fetched value, got %+v, %+v, %v", got, info, err)
+	}
+}
+
+func TestLoadBypass(t *testing.T) {
+	c := New(NewMemoryBackend(10))
+	policy := Policy{TTL: time.Minute}
+	fetcher := &counter{}
+
+	load(c, "key", policy, false, fetcher.fetch)
+	got, info, err := load(c, "key", policy, true, fetcher.fetch)
+	if err != nil || got.N != 2 || info.Status != StatusBypass {
+		t.Errorf("expected a fresh value, got %+v, %+v, %v", got, info, err)
+	}
+	// The bypassing response is stored
+	if got, info, _ := load(c, "key", policy, false, fetcher.fetch); got.N != 2 || info.Status != StatusHit {
+		t.Errorf("expected the value fetched by the bypass, got %+v, %+v", got, info)
+	}
+}
+
+func TestLoadDoesNotCacheFailures(t *testing.T) {
+	c := New(NewMemoryBackend(10))
+	fetcher := &counter{}
+	fetcher.fail.Store(true)
+
+	if _, _, err := load(c, "key", Policy{TTL: time.Minute}, false, fetcher.fetch); err == nil {
+		t.Fatal("expected the fetch error")
+	}
+	fetcher.fail.Store(false)
+	if got, info, err := load(c, "key", Policy{TTL: time.Minute}, false, fetcher.fetch); err != nil || got.N != 2 || info.Status != StatusMiss {
+		t.Errorf("expected a new fetch, got %+v, %+v, %v", got, info, err)
+	}
+}
+
+func TestDiskBackendExpiresEntries(t *testing.T) {
+	backend, err := NewDiskBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Set("fresh", storedEntry(t, 1, 0))
+	backend.Set("old", storedEntry(t, 2, 2*time.Hour))
+
+	// Entries never expire without MaxAge
+	if _, ok, err := backend.Get("old"); !ok || err != nil {
+		t.Fatalf("expected the old entry, got %v, %v", ok, err)
+	}
+
+	backend.MaxAge = time.Hour
+	if entry, ok, err := backend.Get("fresh"); !ok || err != nil || string(entry.Value) != `{"N":1}` {
+		t.Errorf("expected the fresh entry, got %s, %v, %v", entry.Value, ok, err)
+	}
+	if _, ok, err := backend.Get("old"); ok || err != nil {
+		t.Errorf("expected the old entry to be expired, got %v, %v", ok, err)
+	}
+	if _, err := os.Stat(backend.path("old")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the expired entry to be removed, got %v", err)
+	}
+}
+
+func TestDiskBackendPrune(t *testing.T) {
+	dir := t.TempDir()
+	backend, err := NewDiskBackend(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.MaxAge = time.Hour
+	backend.PruneInterval = time.Hour
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := backend.Set(key, storedEntry(t, 1, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"a", "b"} {
+		os.Chtimes(backend.path(key), old, old)
+	}
+	leftover := filepath.Join(dir, "entry-123.tmp")
+	os.WriteFile(leftover, []byte("{"), 0o644)
+	os.Chtimes(leftover, old, old)
+	other := filepath.Join(dir, "README")
+	os.WriteFile(other, nil, 0o644)
+	os.Chtimes(other, old, old)
+
+	removed, err := backend.Prune()
+	if err != nil || removed != 2 {
+		t.Fatalf("expected 2 removed entries, got %d, %v", removed, err)
+	}
+	files, _ := os.ReadDir(dir)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	if len(names) != 2 || !slices.Contains(names, filepath.Base(backend.path("c"))) || !slices.Contains(names, "README") {
+		t.Errorf("unexpected files left %v", names)
+	}
+}
+
+func TestDiskBackendPrunesOnSet(t *testing.T) {
+	backend, err := NewDiskBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Set("old", storedEntry(t, 1, 0))
+
+	backend.MaxAge = time.Hour
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(backend.path("old"), old, old)
+	backend.Set("new", storedEntry(t, 2, 0))
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := os.Stat(backend.path("old")); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the expired entry to be pruned")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, ok, _ := backend.Get("new"); !ok {
+		t.Error("expected the new entry to be kept")
+	}
+}
+
+// newStandInScraper returns a CachedScraper of a site served by handler.
+func newStandInScraper(t *testing.T, handler http.HandlerFunc) (*CachedScraper, string) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scrap := scraper.NewSiteScraper(utils.MustNewSite("http", u.Host, nil))
+	return NewCachedScraper(scrap, New(NewMemoryBackend(10))), u.Host
+}
+
+func TestCachedScraperSharesProductURLVariants(t *testing.T) {
+	var hits atomic.Int32
+	cached, host := newStandInScraper(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprint(w, `<html><body>
+<div class="wrapper__pageTitle"><section class="xs-col-11"><h1 class="pageTitle">Cached Part</h1></section></div>
+</body></html>`)
+	})
+
+	for i, URL := range []string{
+		"http://" + host + "/product/abcd/cached-part",
+		"http://" + host + "/product/abcd/other-slug?utm_source=feed",
+		host + "/product/abcd/",
+	} {
+		part, info, err := cached.GetPart(URL, false)
+		if err != nil {
+			t.Fatalf("GetPart(%q): %v", URL, err)
+		}
+		want := StatusHit
+		if i == 0 {
+			want = StatusMiss
+		}
+		if info.Status != want || part.Name != "Cached Part" {
+			t.Errorf("GetPart(%q): expected a %s of the part, got %s of %q", URL, want, info.Status, part.Name)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected a single scrape, got %d", hits.Load())
+	}
+}
+
+func TestCachedScraperSharesTheFirstSearchPage(t *testing.T) {
+	var hits atomic.Int32
+	cached, _ := newStandInScraper(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprint(w, `<html><body><h1 class="pageTitle">Search</h1>
+<div class="search-results__pageContent"><div class="block"><ul class="list-unstyled">
+<li><p class="search_results--link"><a href="/product/abcd/result">Result</a></p></li>
+</ul></div></div></body></html>`)
+	})
+
+	for i, search := range []struct {
+		region string
+		page   uint
+	}{{"", 0}, {"us", 1}, {"", 1}, {"us", 0}} {
+		results, info, err := cached.SearchPCParts("ssd", search.region, search.page, 10, false)
+		if err != nil {
+			t.Fatalf("SearchPCParts(%+v): %v", search, err)
+		}
+		want := StatusHit
+		if i == 0 {
+			want = StatusMiss
+		}
+		if info.Status != want || len(results.Parts) != 1 {
+			t.Errorf("SearchPCParts(%+v): expected a %s of the results, got %s of %+v", search, want, info.Status, results)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected a single scrape, got %d", hits.Load())
+	}
+}
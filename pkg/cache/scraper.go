@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/pkg/scraper"
+	"strconv"
+	"time"
+)
+
+// CachedScraper caches the search, product and part list responses of a Scraper,
+// each kind of data following its own Policy.
+type CachedScraper struct {
+	Scraper *scraper.Scraper
+	Cache   *Cache
+	Search  Policy
+	Product Policy
+	List    Policy
+}
+
+// NewCachedScraper returns a CachedScraper keeping search results for 10 minutes,
+// products for an hour and part lists for 15 minutes, and serving each of them
+// stale for an hour while they are refreshed.
+func NewCachedScraper(scrap *scraper.Scraper, cache *Cache) *CachedScraper {
+	return &CachedScraper{
+		Scraper: scrap,
+		Cache:   cache,
+		Search:  Policy{TTL: 10 * time.Minute, Stale: time.Hour},
+		Product: Policy{TTL: time.Hour, Stale: time.Hour},
+		List:    Policy{TTL: 15 * time.Minute, Stale: time.Hour},
+	}
+}
+
+// SearchPCParts is Scraper.SearchPCParts behind the cache. Searches redirecting
+// to a product are not cached. Page 0 shares the entry of the first page and no
+// region the entry of "us", as the scraper fetches the same page for them. The
+// limit is kept as given: 0 returns the starting page whole, which no other
+// limit does.
+func (s *CachedScraper) SearchPCParts(searchTerm string, region string, page uint, limit uint, bypass bool) (*models.SearchResults, Info, error) {
+	if page < 1 {
+		page = 1
+	}
+	if region == "" {
+		region = "us"
+	}
+	key := "search|" + region + "|" + strconv.FormatUint(uint64(page), 10) + "|" + strconv.FormatUint(uint64(limit), 10) + "|" + searchTerm
+	return load(s.Cache, key, s.Search, bypass, func() (*models.SearchResults, error) {
+		return s.Scraper.SearchPCParts(searchTerm, region, page, limit)
+	})
+}
+
+// GetPart is Scraper.GetPart behind the cache. The variants of a product URL
+// share an entry.
+func (s *CachedScraper) GetPart(URL string, bypass bool) (*models.Part, Info, error) {
+	key := "part|" + s.Scraper.Site.ProductKey(URL)
+	return load(s.Cache, key, s.Product, bypass, func() (*models.Part, error) {
+		return s.Scraper.GetPart(URL)
+	})
+}
+
+// GetPartList is Scraper.GetPartList behind the cache.
+func (s *CachedScraper) GetPartList(URL string, bypass bool) (*models.PartList, Info, error) {
+	key := "list|" + s.Scraper.Site.ConvertListURL(URL)
+	return load(s.Cache, key, s.List, bypass, func() (*models.PartList, error) {
+		return s.Scraper.GetPartList(URL)
+	})
+}
@@ -0,0 +1,202 @@
+package compat
+
+import (
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/pkg/wattage"
+	"slices"
+	"strings"
+)
+
+const (
+	LevelNote    = "Note"
+	LevelWarning = "Warning"
+	LevelProblem = "Problem"
+)
+
+// build holds the typed specs of the parts of a build, grouped by category.
+type build struct {
+	draw         wattage.Estimate
+	cpus         []*models.CPUSpecs
+	motherboards []*models.MotherboardSpecs
+	memory       []*models.MemorySpecs
+	gpus         []*models.GPUSpecs
+	psus         []*models.PSUSpecs
+	cases        []*models.CaseSpecs
+	coolers      []*models.CoolerSpecs
+}
+
+func newBuild(parts []models.Part) build {
+	b := build{
+		draw: wattage.NewEstimator().Estimate(parts),
+	}
+	for _, part := range parts {
+		typed := models.PartTypedSpecs(part)
+		switch {
+		case typed.CPU != nil:
+			b.cpus = append(b.cpus, typed.CPU)
+		case typed.Motherboard != nil:
+			b.motherboards = append(b.motherboards, typed.Motherboard)
+		case typed.Memory != nil:
+			b.memory = append(b.memory, typed.Memory)
+		case typed.GPU != nil:
+			b.gpus = append(b.gpus, typed.GPU)
+		case typed.PSU != nil:
+			b.psus = append(b.psus, typed.PSU)
+		case typed.Case != nil:
+			b.cases = append(b.cases, typed.Case)
+		case typed.Cooler != nil:
+			b.coolers = append(b.coolers, typed.Cooler)
+		}
+	}
+	return b
+}
+
+// Check runs the compatibility rules against the given parts and returns the
+// issues it found. A part appearing several times counts once per occurrence.
+func Check(parts []models.Part) []models.CompatibilityInfo {
+	b := newBuild(parts)
+
+	notes := []models.CompatibilityInfo{}
+	notes = append(notes, checkSockets(b)...)
+	notes = append(notes, checkMemory(b)...)
+	notes = append(notes, checkFormFactor(b)...)
+	notes = append(notes, checkGPULength(b)...)
+	notes = append(notes, checkCooler(b)...)
+	notes = append(notes, checkPowerSupply(b)...)
+
+	return notes
+}
+
+func info(level string, format string, args ...any) models.CompatibilityInfo {
+	return models.CompatibilityInfo{
+		Message: fmt.Sprintf(format, args...),
+		Level:   level,
+	}
+}
+
+func checkSockets(b build) []models.CompatibilityInfo {
+	var notes []models.CompatibilityInfo
+	for _, cpu := range b.cpus {
+		for _, motherboard := range b.motherboards {
+			if cpu.Socket == "" || motherboard.Socket == "" {
+				notes = append(notes, info(LevelNote, "The CPU and motherboard sockets could not be checked."))
+				continue
+			}
+			if cpu.Socket != motherboard.Socket {
+				notes = append(notes, info(LevelProblem, "The CPU uses the %s socket but the motherboard has a %s socket.", cpu.Socket, motherboard.Socket))
+			}
+		}
+	}
+	return notes
+}
+
+func checkMemory(b build) []models.CompatibilityInfo {
+	var notes []models.CompatibilityInfo
+	for _, motherboard := range b.motherboards {
+		modules := 0
+		for _, memory := range b.memory {
+			modules += memory.Modules
+			if memory.MemoryType == "" || motherboard.MemoryType == "" {
+				notes = append(notes, info(LevelNote, "The memory type could not be checked against the motherboard."))
+				continue
+			}
+			if memory.MemoryType != motherboard.MemoryType {
+				notes = append(notes, info(LevelProblem, "The memory is %s but the motherboard only supports %s.", memory.MemoryType, motherboard.MemoryType))
+			}
+		}
+		if motherboard.MemorySlots > 0 && modules > motherboard.MemorySlots {
+			notes = append(notes, info(LevelProblem, "The build has %d memory modules but the motherboard only has %d slots.", modules, motherboard.MemorySlots))
+		}
+	}
+	return notes
+}
+
+func checkFormFactor(b build) []models.CompatibilityInfo {
+	var notes []models.CompatibilityInfo
+	for _, pcCase := range b.cases {
+		for _, motherboard := range b.motherboards {
+			if motherboard.FormFactor == "" || len(pcCase.MotherboardFormFactors) == 0 {
+				notes = append(notes, info(LevelNote, "The motherboard form factor could not be checked against the case."))
+				continue
+			}
+			if !slices.Contains(pcCase.MotherboardFormFactors, motherboard.FormFactor) {
+				notes = append(notes, info(LevelProblem, "The %s motherboard does not fit in the case, which supports %s.", motherboard.FormFactor, joinFormFactors(pcCase.MotherboardFormFactors)))
+			}
+		}
+	}
+	return notes
+}
+
+func checkGPULength(b build) []models.CompatibilityInfo {
+	var notes []models.CompatibilityInfo
+	for _, pcCase := range b.cases {
+		for _, gpu := range b.gpus {
+			if !sameUnit(gpu.Length, pcCase.MaxGPULength) {
+				notes = append(notes, info(LevelNote, "The video card length could not be checked against the case clearance."))
+				continue
+			}
+			if gpu.Length.Value > pcCase.MaxGPULength.Value {
+				notes = append(notes, info(LevelProblem, "The video card is %s long but the case only fits video cards up to %s.", formatQuantity(gpu.Length), formatQuantity(pcCase.MaxGPULength)))
+			}
+		}
+	}
+	return notes
+}
+
+func checkCooler(b build) []models.CompatibilityInfo {
+	var notes []models.CompatibilityInfo
+	for _, cooler := range b.coolers {
+		for _, cpu := range b.cpus {
+			if cpu.Socket != "" && len(cooler.Sockets) > 0 && !slices.Contains(cooler.Sockets, cpu.Socket) {
+				notes = append(notes, info(LevelProblem, "The CPU cooler does not support the %s socket.", cpu.Socket))
+			}
+		}
+		if cooler.WaterCooled {
+			continue
+		}
+		for _, pcCase := range b.cases {
+			if !sameUnit(cooler.Height, pcCase.MaxCoolerHeight) {
+				notes = append(notes, info(LevelNote, "The CPU cooler height could not be checked against the case clearance."))
+				continue
+			}
+			if cooler.Height.Value > pcCase.MaxCoolerHeight.Value {
+				notes = append(notes, info(LevelProblem, "The CPU cooler is %s tall but the case only fits coolers up to %s.", formatQuantity(cooler.Height), formatQuantity(pcCase.MaxCoolerHeight)))
+			}
+		}
+	}
+	return notes
+}
+
+func checkPowerSupply(b build) []models.CompatibilityInfo {
+	var notes []models.CompatibilityInfo
+	for _, psu := range b.psus {
+		if !psu.Wattage.Valid() {
+			notes = append(notes, info(LevelNote, "The power supply wattage could not be checked."))
+			continue
+		}
+		if psu.Wattage.Value < b.draw.Total {
+			notes = append(notes, info(LevelProblem, "The power supply provides %.0fW but the build draws an estimated %.0fW.", psu.Wattage.Value, b.draw.Total))
+		} else if psu.Wattage.Value < b.draw.RecommendedPSU {
+			notes = append(notes, info(LevelWarning, "The estimated draw of %.0fW leaves little headroom on the %.0fW power supply, %.0fW is recommended.", b.draw.Total, psu.Wattage.Value, b.draw.RecommendedPSU))
+		}
+	}
+	return notes
+}
+
+// sameUnit reports whether both quantities were parsed and use the same unit.
+func sameUnit(a, b models.Quantity) bool {
+	return a.Valid() && b.Valid() && strings.EqualFold(a.Unit, b.Unit)
+}
+
+func formatQuantity(q models.Quantity) string {
+	return fmt.Sprintf("%g %s", q.Value, q.Unit)
+}
+
+func joinFormFactors(formFactors []models.FormFactor) string {
+	names := make([]string, len(formFactors))
+	for i, f := range formFactors {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
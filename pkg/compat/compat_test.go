@@ -0,0 +1,148 @@
+package compat
+
+import (
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"reflect"
+	"testing"
+)
+
+func quantity(value float64, unit string) models.Quantity {
+	return models.Quantity{Value: value, Unit: unit}
+}
+
+// compatibleBuild returns the parts of a build without any issue, drawing an
+// estimated 446W: CPU 120W, video card 263W, motherboard 50W, two memory modules
+// and an air cooler.
+func compatibleBuild() []models.Part {
+	return []models.Part{
+		{Type: "CPU", TypedSpecs: models.TypedSpecs{CPU: &models.CPUSpecs{Socket: "AM5", TDP: quantity(120, "W")}}},
+		{Type: "Motherboard", TypedSpecs: models.TypedSpecs{Motherboard: &models.MotherboardSpecs{
+			Socket: "AM5", FormFactor: models.FormFactorATX, MemoryType: models.MemoryTypeDDR5, MemorySlots: 4,
+		}}},
+		{Type: "Memory", TypedSpecs: models.TypedSpecs{Memory: &models.MemorySpecs{MemoryType: models.MemoryTypeDDR5, Modules: 2}}},
+		{Type: "Video Card", TypedSpecs: models.TypedSpecs{GPU: &models.GPUSpecs{Length: quantity(320, "mm"), TDP: quantity(263, "W")}}},
+		{Type: "Power Supply", TypedSpecs: models.TypedSpecs{PSU: &models.PSUSpecs{Wattage: quantity(750, "W")}}},
+		{Type: "Case", TypedSpecs: models.TypedSpecs{Case: &models.CaseSpecs{
+			MotherboardFormFactors: []models.FormFactor{models.FormFactorATX, models.FormFactorMicroATX},
+			MaxGPULength:           quantity(360, "mm"),
+			MaxCoolerHeight:        quantity(170, "mm"),
+		}}},
+		{Type: "CPU Cooler", TypedSpecs: models.TypedSpecs{Cooler: &models.CoolerSpecs{Sockets: []models.Socket{"AM4", "AM5"}, Height: quantity(165, "mm")}}},
+	}
+}
+
+const (
+	cpu = iota
+	motherboard
+	memory
+	gpu
+	psu
+	pcCase
+	cooler
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(parts []models.Part)
+		want   []models.CompatibilityInfo
+	}{
+		{"compatible", func(parts []models.Part) {}, []models.CompatibilityInfo{}},
+		{"socket mismatch", func(parts []models.Part) {
+			parts[cpu].TypedSpecs.CPU.Socket = "LGA1700"
+		}, []models.CompatibilityInfo{
+			{Level: LevelProblem, Message: "The CPU uses the LGA1700 socket but the motherboard has a AM5 socket."},
+			{Level: LevelProblem, Message: "The CPU cooler does not support the LGA1700 socket."},
+		}},
+		{"unknown socket", func(parts []models.Part) {
+			parts[motherboard].TypedSpecs.Motherboard.Socket = ""
+		}, []models.CompatibilityInfo{
+			{Level: LevelNote, Message: "The CPU and motherboard sockets could not be checked."},
+		}},
+		{"memory type", func(parts []models.Part) {
+			parts[memory].TypedSpecs.Memory.MemoryType = models.MemoryTypeDDR4
+		}, []models.CompatibilityInfo{
+			{Level: LevelProblem, Message: "The memory is DDR4 but the motherboard only supports DDR5."},
+		}},
+		{"form factor", func(parts []models.Part) {
+			parts[motherboard].TypedSpecs.Motherboard.FormFactor = models.FormFactorEATX
+		}, []models.CompatibilityInfo{
+			{Level: LevelProblem, Message: "The EATX motherboard does not fit in the case, which supports ATX, Micro ATX."},
+		}},
+		{"GPU length", func(parts []models.Part) {
+			parts[gpu].TypedSpecs.GPU.Length = quantity(380, "mm")
+		}, []models.CompatibilityInfo{
+			{Level: LevelProblem, Message: "The video card is 380 mm long but the case only fits video cards up to 360 mm."},
+		}},
+		{"GPU length in other units", func(parts []models.Part) {
+			parts[gpu].TypedSpecs.GPU.Length = quantity(12.6, "in")
+		}, []models.CompatibilityInfo{
+			{Level: LevelNote, Message: "The video card length could not be checked against the case clearance."},
+		}},
+		{"cooler height", func(parts []models.Part) {
+			parts[cooler].TypedSpecs.Cooler.Height = quantity(175.5, "mm")
+		}, []models.CompatibilityInfo{
+			{Level: LevelProblem, Message: "The CPU cooler is 175.5 mm tall but the case only fits coolers up to 170 mm."},
+		}},
+		{"water cooler height", func(parts []models.Part) {
+			parts[cooler].TypedSpecs.Cooler.Height = quantity(52, "mm")
+			parts[cooler].TypedSpecs.Cooler.WaterCooled = true
+			parts[pcCase].TypedSpecs.Case.MaxCoolerHeight = quantity(40, "mm")
+		}, []models.CompatibilityInfo{}},
+		{"cooler socket", func(parts []models.Part) {
+			parts[cooler].TypedSpecs.Cooler.Sockets = []models.Socket{"LGA1700"}
+		}, []models.CompatibilityInfo{
+			{Level: LevelProblem, Message: "The CPU cooler does not support the AM5 socket."},
+		}},
+		{"power supply headroom", func(parts []models.Part) {
+			parts[psu].TypedSpecs.PSU.Wattage = quantity(500, "W")
+		}, []models.CompatibilityInfo{
+			{Level: LevelWarning, Message: "The estimated draw of 446W leaves little headroom on the 500W power supply, 600W is recommended."},
+		}},
+		{"power supply too small", func(parts []models.Part) {
+			parts[psu].TypedSpecs.PSU.Wattage = quantity(400, "W")
+		}, []models.CompatibilityInfo{
+			{Level: LevelProblem, Message: "The power supply provides 400W but the build draws an estimated 446W."},
+		}},
+		{"unknown power supply wattage", func(parts []models.Part) {
+			parts[psu].TypedSpecs.PSU.Wattage = models.Quantity{Raw: "Unknown"}
+		}, []models.CompatibilityInfo{
+			{Level: LevelNote, Message: "The power supply wattage could not be checked."},
+		}},
+	}
+
+	for _, test := range tests {
+		parts := compatibleBuild()
+		test.modify(parts)
+		if got := Check(parts); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCheckMemorySlots(t *testing.T) {
+	parts := compatibleBuild()
+	parts[motherboard].TypedSpecs.Motherboard.MemorySlots = 2
+	parts = append(parts, parts[memory])
+
+	want := []models.CompatibilityInfo{
+		{Level: LevelProblem, Message: "The build has 4 memory modules but the motherboard only has 2 slots."},
+	}
+	if got := Check(parts); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCheckParsesRawSpecs(t *testing.T) {
+	parts := []models.Part{
+		{Type: "CPU", Specs: []models.PartSpec{{Name: "Socket", Values: []string{"AM5"}}}},
+		{Type: "Motherboard", Specs: []models.PartSpec{{Name: "Socket / CPU", Values: []string{"LGA 1700"}}}},
+	}
+
+	want := []models.CompatibilityInfo{
+		{Level: LevelProblem, Message: "The CPU uses the AM5 socket but the motherboard has a LGA1700 socket."},
+	}
+	if got := Check(parts); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+// Package currency converts scraped prices between currencies using exchange
+// rates from a pluggable Provider.
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"math"
+	"strings"
+)
+
+// ErrUnknownCurrency is returned when a currency has no exchange rate.
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// symbols are the symbols converted prices are written with.
+var symbols = map[string]string{
+	"USD": "$",
+	"CAD": "$",
+	"AUD": "$",
+	"NZD": "$",
+	"ARS": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"INR": "₹",
+	"SEK": "kr",
+	"NOK": "kr",
+	"DKK": "kr",
+	"CZK": "Kč",
+	"HUF": "Ft",
+	"RON": "lei",
+	"PLN": "zł",
+	"SAR": "SR",
+}
+
+// Symbol returns the symbol of an ISO 4217 currency, or the code itself when it has none.
+func Symbol(code string) string {
+	if symbol, ok := symbols[code]; ok {
+		return symbol
+	}
+	return code
+}
+
+// Converter normalizes prices into a target currency.
+type Converter struct {
+	Provider Provider
+}
+
+// NewConverter returns a Converter using the rates of provider.
+func NewConverter(provider Provider) *Converter {
+	return &Converter{Provider: provider}
+}
+
+// Supports reports whether prices can be converted into the given currency.
+func (c *Converter) Supports(code string) bool {
+	rates, err := c.Provider.Rates()
+	if err != nil {
+		return false
+	}
+	_, ok := rates.rate(strings.ToUpper(code))
+	return ok
+}
+
+// Convert converts an amount from one ISO 4217 currency to another.
+func (c *Converter) Convert(amount float64, from string, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return amount, nil
+	}
+
+	rates, err := c.Provider.Rates()
+	if err != nil {
+		return 0, err
+	}
+
+	fromRate, ok := rates.rate(from)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, from)
+	}
+	toRate, ok := rates.rate(to)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, to)
+	}
+	return amount / fromRate * toRate, nil
+}
+
+// ConvertPrice returns the price converted into the target currency, rounded to
+// its minor unit (cents for USD, forints for HUF). Prices without a currency
+// code, such as missing prices, are returned unchanged.
+func (c *Converter) ConvertPrice(price models.Price, to string) (models.Price, error) {
+	to = strings.ToUpper(to)
+	if price.CurrencyCode == "" || price.CurrencyCode == to {
+		return price, nil
+	}
+
+	converted := models.Price{
+		Currency:     Symbol(to),
+		CurrencyCode: to,
+	}
+	exponent := models.CurrencyExponent(to)
+	scale := math.Pow10(exponent)
+	for _, field := range []struct {
+		from  float64
+		to    *float64
+		minor *int64
+	}{
+		{price.Base, &converted.Base, &converted.Minor.Base},
+		{price.Shipping, &converted.Shipping, &converted.Minor.Shipping},
+		{price.Tax, &converted.Tax, &converted.Minor.Tax},
+		{price.Discounts, &converted.Discounts, &converted.Minor.Discounts},
+		{price.Total, &converted.Total, &converted.Minor.Total},
+	} {
+		amount, err := c.Convert(field.from, price.CurrencyCode, to)
+		if err != nil {
+			return price, err
+		}
+		*field.minor = int64(math.Round(amount * scale))
+		*field.to = float64(*field.minor) / scale
+	}
+	if price.TotalString != "" {
+		converted.TotalString = fmt.Sprintf("%s%.*f", converted.Currency, exponent, converted.Total)
+	}
+	return converted, nil
+}
+
+// ConvertSearchResults converts the prices of search results in place.
+func (c *Converter) ConvertSearchResults(results *models.SearchResults, to string) error {
+	for i := range results.Parts {
+		if err := c.convertVendor(&results.Parts[i].Vendor, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConvertPart converts the prices of every vendor of a part in place.
+func (c *Converter) ConvertPart(part *models.Part, to string) error {
+	for i := range part.Vendors {
+		if err := c.convertVendor(&part.Vendors[i], to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConvertPartList converts the prices of a part list and its parts in place.
+func (c *Converter) ConvertPartList(partList *models.PartList, to string) error {
+	for i := range partList.Parts {
+		if err := c.convertVendor(&partList.Parts[i].Vendor, to); err != nil {
+			return err
+		}
+	}
+
+	price, err := c.ConvertPrice(partList.Price, to)
+	if err != nil {
+		return err
+	}
+	partList.Price = price
+	return nil
+}
+
+func (c *Converter) convertVendor(vendor *models.Vendor, to string) error {
+	price, err := c.ConvertPrice(vendor.Price, to)
+	if err != nil {
+		return err
+	}
+	vendor.Price = price
+	return nil
+}
+
+// ConvertComparison converts the prices of a region comparison in place and
+// picks the cheapest region now that every price is in the same currency.
+// Regions whose currency has no exchange rate are left in their currency,
+// listed in Unconverted and never picked as the cheapest.
+func (c *Converter) ConvertComparison(comparison *models.RegionComparison, to string) error {
+	comparison.Currency = strings.ToUpper(to)
+	comparison.Cheapest = ""
+	comparison.Unconverted = []string{}
+
+	cheapest := 0.0
+	for i := range comparison.Regions {
+		regionPrice := &comparison.Regions[i]
+		err := c.convertVendor(&regionPrice.Vendor, to)
+		if errors.Is(err, ErrUnknownCurrency) {
+			comparison.Unconverted = append(comparison.Unconverted, regionPrice.Region)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if regionPrice.InStock && (comparison.Cheapest == "" || regionPrice.Vendor.Price.Total < cheapest) {
+			comparison.Cheapest = regionPrice.Region
+			cheapest = regionPrice.Vendor.Price.Total
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testConverter(t *testing.T) *Converter {
+	t.Helper()
+	provider, err := NewStaticProvider(Rates{Base: "usd", Rates: map[string]float64{"eur": 0.9, "GBP": 0.8, "SEK": 10, "HUF": 360, "ARS": 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewConverter(provider)
+}
+
+func TestConvert(t *testing.T) {
+	converter := testConverter(t)
+
+	tests := []struct {
+		amount float64
+		from   string
+		to     string
+		want   float64
+	}{
+		{100, "USD", "EUR", 90},
+		{90, "EUR", "USD", 100},
+		{90, "eur", "gbp", 80},
+		{10, "SEK", "GBP", 0.8},
+		{42, "CHF", "chf", 42},
+	}
+	for _, test := range tests {
+		got, err := converter.Convert(test.amount, test.from, test.to)
+		if err != nil || math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("Convert(%g, %s, %s) = %g, %v, want %g", test.amount, test.from, test.to, got, err, test.want)
+		}
+	}
+
+	for _, pair := range [][2]string{{"USD", "CHF"}, {"CHF", "USD"}, {"USD", "ARS"}} {
+		if _, err := converter.Convert(1, pair[0], pair[1]); !errors.Is(err, ErrUnknownCurrency) {
+			t.Errorf("Convert(1, %s, %s): expected an unknown currency, got %v", pair[0], pair[1], err)
+		}
+	}
+	if !converter.Supports("gbp") || converter.Supports("ARS") {
+		t.Error("expected GBP to be supported but not ARS, whose rate is 0")
+	}
+}
+
+func TestConvertPrice(t *testing.T) {
+	converter := testConverter(t)
+
+	price := models.Price{Base: 99.99, Shipping: 5, Tax: 1.11, Discounts: 10, Total: 96.1, Currency: "$", CurrencyCode: "USD", TotalString: "$96.10"}
+	got, err := converter.ConvertPrice(price, "eur")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.Price{
+		Base: 89.99, Shipping: 4.5, Tax: 1, Discounts: 9, Total: 86.49, Currency: "€", CurrencyCode: "EUR", TotalString: "€86.49",
+		Minor: models.MinorPrice{Base: 8999, Shipping: 450, Tax: 100, Discounts: 900, Total: 8649},
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Currencies without decimals are rounded to whole units
+	huf, err := converter.ConvertPrice(models.Price{Total: 96.1, Currency: "$", CurrencyCode: "USD", TotalString: "$96.10"}, "HUF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if huf.Minor.Total != 34596 || huf.Total != 34596 || huf.TotalString != "Ft34596" {
+		t.Errorf("unexpected HUF price %+v", huf)
+	}
+
+	for _, unchanged := range []models.Price{
+		{},
+		{Total: 10, Currency: "£", CurrencyCode: "GBP", TotalString: "£10.00"},
+	} {
+		if got, err := converter.ConvertPrice(unchanged, "GBP"); err != nil || got != unchanged {
+			t.Errorf("expected %+v to be unchanged, got %+v, %v", unchanged, got, err)
+		}
+	}
+
+	unknown := models.Price{Total: 10, CurrencyCode: "CHF"}
+	if got, err := converter.ConvertPrice(unknown, "USD"); !errors.Is(err, ErrUnknownCurrency) || got != unknown {
+		t.Errorf("expected an unknown currency and the price unchanged, got %+v, %v", got, err)
+	}
+}
+
+// ratesServer serves rates whose EUR rate is the number of the request, or fails while failing is set.
+func ratesServer(t *testing.T, failing *atomic.Bool) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprintf(w, `{"base": "USD", "rates": {"EUR": %d}}`, n)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestHTTPProviderCachesRates(t *testing.T) {
+	var failing atomic.Bool
+	server, requests := ratesServer(t, &failing)
+	provider := NewHTTPProvider(server.URL)
+
+	for i := 0; i < 3; i++ {
+		rates, err := provider.Rates()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rates.Rates["EUR"] != 1 || rates.Updated.IsZero() {
+			t.Errorf("unexpected rates %+v", rates)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("expected the rates to be fetched once within the TTL, got %d requests", requests.Load())
+	}
+
+	// Expire the rates
+	provider.fetched = time.Now().Add(-provider.TTL)
+	if rates, err := provider.Rates(); err != nil || rates.Rates["EUR"] != 2 {
+		t.Errorf("expected the rates to be refreshed, got %+v, %v", rates, err)
+	}
+}
+
+func TestHTTPProviderKeepsRatesOnFailure(t *testing.T) {
+	var failing atomic.Bool
+	server, requests := ratesServer(t, &failing)
+	provider := NewHTTPProvider(server.URL)
+
+	if _, err := provider.Rates(); err != nil {
+		t.Fatal(err)
+	}
+
+	failing.Store(true)
+	provider.fetched = time.Now().Add(-provider.TTL)
+	rates, err := provider.Rates()
+	if err != nil || rates.Rates["EUR"] != 1 {
+		t.Errorf("expected the previous rates, got %+v, %v", rates, err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("expected a refresh attempt, got %d requests", requests.Load())
+	}
+
+	failing.Store(false)
+	if rates, err := provider.Rates(); err != nil || rates.Rates["EUR"] != 3 {
+		t.Errorf("expected the rates to be refreshed once the endpoint recovers, got %+v, %v", rates, err)
+	}
+}
+
+func TestHTTPProviderFailsWithoutRates(t *testing.T) {
+	failing := atomic.Bool{}
+	failing.Store(true)
+	server, _ := ratesServer(t, &failing)
+
+	if _, err := NewHTTPProvider(server.URL).Rates(); err == nil {
+		t.Error("expected an error without previous rates")
+	}
+}
+
+func TestConvertComparison(t *testing.T) {
+	converter := testConverter(t)
+	comparison := &models.RegionComparison{
+		Cheapest: "uk",
+		Regions: []models.RegionPrice{
+			{Region: "us", InStock: true, Vendor: models.Vendor{Price: models.Price{Total: 100, CurrencyCode: "USD"}}},
+			{Region: "de", InStock: true, Vendor: models.Vendor{Price: models.Price{Total: 99, CurrencyCode: "EUR"}}},
+			{Region: "uk", InStock: false, Vendor: models.Vendor{}},
+			{Region: "ch", InStock: true, Vendor: models.Vendor{Price: models.Price{Total: 50, CurrencyCode: "CHF"}}},
+		},
+	}
+
+	if err := converter.ConvertComparison(comparison, "gbp"); err != nil {
+		t.Fatal(err)
+	}
+	if comparison.Currency != "GBP" || comparison.Cheapest != "us" {
+		t.Errorf("expected us to be the cheapest in GBP, got %s in %s", comparison.Cheapest, comparison.Currency)
+	}
+	var totals []float64
+	for _, region := range comparison.Regions {
+		totals = append(totals, region.Vendor.Price.Total)
+	}
+	if want := []float64{80, 88, 0, 50}; !reflect.DeepEqual(totals, want) {
+		t.Errorf("expected the totals %v, got %v", want, totals)
+	}
+	if !reflect.DeepEqual(comparison.Unconverted, []string{"ch"}) || comparison.Regions[3].Vendor.Price.CurrencyCode != "CHF" {
+		t.Errorf("expected ch to be left in CHF, got %v and %+v", comparison.Unconverted, comparison.Regions[3].Vendor.Price)
+	}
+}
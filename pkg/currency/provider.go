@@ -0,0 +1,155 @@
+package currency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2/log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rates holds exchange rates relative to Base: one unit of Base is worth
+// Rates[code] units of code.
+type Rates struct {
+	Base    string             `json:"base"`
+	Rates   map[string]float64 `json:"rates"`
+	Updated time.Time          `json:"updated"`
+}
+
+// rate returns the value of one unit of Base in the given currency.
+func (r *Rates) rate(code string) (float64, bool) {
+	if code == r.Base {
+		return 1, true
+	}
+	rate, ok := r.Rates[code]
+	return rate, ok && rate > 0
+}
+
+// Provider supplies the exchange rates used by a Converter.
+type Provider interface {
+	Rates() (*Rates, error)
+}
+
+// normalize upper cases the currency codes of freshly loaded rates and checks they are usable.
+func normalize(rates *Rates) (*Rates, error) {
+	if rates.Base == "" || len(rates.Rates) == 0 {
+		return nil, errors.New("exchange rates have no base currency or rates")
+	}
+
+	normalized := &Rates{
+		Base:    strings.ToUpper(rates.Base),
+		Rates:   make(map[string]float64, len(rates.Rates)),
+		Updated: rates.Updated,
+	}
+	for code, rate := range rates.Rates {
+		normalized.Rates[strings.ToUpper(code)] = rate
+	}
+	return normalized, nil
+}
+
+// StaticProvider always returns the same rates.
+type StaticProvider struct {
+	rates *Rates
+}
+
+// NewStaticProvider returns a Provider for fixed rates.
+func NewStaticProvider(rates Rates) (*StaticProvider, error) {
+	normalized, err := normalize(&rates)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticProvider{rates: normalized}, nil
+}
+
+// NewFileProvider returns a Provider for the rates of a JSON file such as
+// {"base": "USD", "rates": {"EUR": 0.92, "GBP": 0.79}}.
+func NewFileProvider(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates Rates
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("could not parse exchange rates file: %v", err)
+	}
+	if rates.Updated.IsZero() {
+		if info, err := os.Stat(path); err == nil {
+			rates.Updated = info.ModTime().UTC()
+		}
+	}
+	return NewStaticProvider(rates)
+}
+
+func (p *StaticProvider) Rates() (*Rates, error) {
+	return p.rates, nil
+}
+
+// HTTPProvider fetches rates from an HTTP endpoint answering with the same JSON
+// document as the rates file, which most public exchange rate APIs follow.
+// Rates are cached for TTL, and the last rates are kept when a refresh fails.
+type HTTPProvider struct {
+	URL    string
+	Client *http.Client
+	TTL    time.Duration
+
+	mu      sync.Mutex
+	rates   *Rates
+	fetched time.Time
+}
+
+// NewHTTPProvider returns a Provider fetching rates from URL at most once an hour.
+func NewHTTPProvider(URL string) *HTTPProvider {
+	return &HTTPProvider{
+		URL:    URL,
+		Client: &http.Client{Timeout: 10 * time.Second},
+		TTL:    time.Hour,
+	}
+}
+
+func (p *HTTPProvider) Rates() (*Rates, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.rates != nil && time.Since(p.fetched) < p.TTL {
+		return p.rates, nil
+	}
+
+	rates, err := p.fetch()
+	if err != nil {
+		if p.rates != nil {
+			log.Warn("Could not refresh exchange rates, keeping the previous rates: ", err)
+			return p.rates, nil
+		}
+		return nil, err
+	}
+
+	p.rates = rates
+	p.fetched = time.Now()
+	return rates, nil
+}
+
+func (p *HTTPProvider) fetch() (*Rates, error) {
+	res, err := p.Client.Get(p.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchange rates request failed with status %d", res.StatusCode)
+	}
+
+	var rates Rates
+	if err := json.NewDecoder(res.Body).Decode(&rates); err != nil {
+		return nil, fmt.Errorf("could not parse exchange rates: %v", err)
+	}
+	if rates.Updated.IsZero() {
+		rates.Updated = time.Now().UTC()
+	}
+	return normalize(&rates)
+}
@@ -0,0 +1,416 @@
+package pcpartpicker_automation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"github.com/Aquilabot/KreaPC-API/pkg/proxy"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gofiber/fiber/v2/log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	loginPath     = "accounts/login/"
+	sessionCookie = "sessionid"
+	savedListView = "#view="
+)
+
+var (
+	// ErrLoginFailed is returned when PCPartPicker rejects the credentials of an account.
+	ErrLoginFailed = errors.New("PCPartPicker login failed")
+	// ErrSessionExpired is returned when PCPartPicker asks to log in again.
+	ErrSessionExpired = errors.New("PCPartPicker session expired")
+	// ErrNoSavedList is returned when the account has no saved list with the given ID.
+	ErrNoSavedList = errors.New("no such saved list")
+	// ErrNoAccountForm is returned when a page of the account has no form for an action.
+	ErrNoAccountForm = errors.New("no form for the account action")
+)
+
+// Account is a PCPartPicker account whose session cookies are kept in a
+// CookieStore, logging in again whenever the session expires. Its actions are
+// serialized, the account having a single part list being edited. When Proxies
+// is set, the account keeps to a single proxy of the pool, moving to another
+// one only when its proxy gets ejected.
+type Account struct {
+	Site      *utils.Site
+	Region    string
+	Username  string
+	Password  string
+	UserAgent string
+	Timeout   time.Duration
+	Transport http.RoundTripper
+	Proxies   *proxy.Pool
+
+	mu  sync.Mutex
+	jar *persistentJar
+}
+
+// NewAccount returns the account of username on site, restoring the session
+// saved in store. The session isn't persisted when store is nil.
+func NewAccount(site *utils.Site, username string, password string, store *CookieStore) (*Account, error) {
+	jar, err := newPersistentJar(store)
+	if err != nil {
+		return nil, err
+	}
+	return &Account{
+		Site:      site,
+		Username:  username,
+		Password:  password,
+		UserAgent: defaultUserAgent,
+		Timeout:   30 * time.Second,
+		jar:       jar,
+	}, nil
+}
+
+// Session logs in unless the account already has a session, and returns what
+// requests made for the account outside of it go through: the cookie jar
+// holding its session and, when Proxies is set, the proxy it keeps to.
+func (a *Account) Session() (http.CookieJar, *url.URL, error) {
+	if err := a.EnsureSession(); err != nil {
+		return nil, nil, err
+	}
+	if a.Proxies == nil {
+		return a.jar, nil, nil
+	}
+	proxyURL, err := a.Proxies.Session(a.proxySession())
+	if err != nil {
+		return nil, nil, err
+	}
+	return a.jar, proxyURL, nil
+}
+
+// EnsureSession logs in unless the account already has a session.
+func (a *Account) EnsureSession() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.loggedIn() {
+		return nil
+	}
+	return a.login()
+}
+
+// Login logs in with the credentials of the account, replacing its session.
+func (a *Account) Login() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.login()
+}
+
+// SavedLists returns the part lists saved by the account.
+func (a *Account) SavedLists() ([]models.SavedList, error) {
+	var lists []models.SavedList
+	err := a.withSession(func(session *httpSession) error {
+		doc, err := session.get(a.savedURL())
+		if err != nil {
+			return err
+		}
+		lists = a.savedLists(doc)
+		return nil
+	})
+	return lists, err
+}
+
+// SaveList builds a new part list of items and saves it to the account as name.
+func (a *Account) SaveList(name string, items []models.ListItem) (*models.SavedList, error) {
+	links, edits, err := planItems(a.Site, a.Region, items)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved *models.SavedList
+	err = a.withSession(func(session *httpSession) error {
+		// The new list is told apart from the lists saved before, names being reusable
+		doc, err := session.get(a.savedURL())
+		if err != nil {
+			return err
+		}
+		known := map[string]bool{}
+		for _, list := range a.savedLists(doc) {
+			known[list.ID] = true
+		}
+
+		prefixURL := a.prefixURL()
+		if doc, err = session.get(prefixURL + "list/"); err != nil {
+			return err
+		}
+		// Only logged in sessions can save their list
+		if findForm(saveForms(doc), "save") == nil {
+			return ErrSessionExpired
+		}
+		// Start from an empty list rather than the one being edited
+		if form := findForm(doc.Selection, "new list"); form != nil {
+			method, target, body, err := formRequest(doc, form, formValues(form, submitButton(form, "new list")))
+			if err != nil {
+				return err
+			}
+			if _, err := session.submit(prefixURL, method, target, body); err != nil {
+				return err
+			}
+		}
+
+		if doc, err = session.fill(prefixURL, links, edits); err != nil {
+			return err
+		}
+		if _, err := a.submitForm(session, doc, saveForms(doc), "save", name); err != nil {
+			return err
+		}
+
+		if doc, err = session.get(a.savedURL()); err != nil {
+			return err
+		}
+		var added []models.SavedList
+		for _, list := range a.savedLists(doc) {
+			if !known[list.ID] {
+				added = append(added, list)
+			}
+		}
+		// Another client of the account may have saved a list meanwhile
+		if i := slices.IndexFunc(added, func(list models.SavedList) bool { return list.Name == name }); i >= 0 {
+			saved = &added[i]
+		} else if len(added) == 1 {
+			saved = &added[0]
+		}
+		if saved == nil {
+			return fmt.Errorf("saved list %q not found after saving it", name)
+		}
+		return nil
+	})
+	return saved, err
+}
+
+// RenameList renames the saved list of the account with the given ID.
+func (a *Account) RenameList(id string, name string) error {
+	return a.withSession(func(session *httpSession) error {
+		doc, row, err := a.savedRow(session, id)
+		if err != nil {
+			return err
+		}
+		_, err = a.submitForm(session, doc, row.Find("form"), "rename", name)
+		return err
+	})
+}
+
+// DeleteList deletes the saved list of the account with the given ID.
+func (a *Account) DeleteList(id string) error {
+	return a.withSession(func(session *httpSession) error {
+		doc, row, err := a.savedRow(session, id)
+		if err != nil {
+			return err
+		}
+		_, err = a.submitForm(session, doc, row.Find("form"), "delete", "")
+		return err
+	})
+}
+
+// withSession runs action with the session of the account, logging in first
+// when there is none, and again once if the session expires meanwhile.
+func (a *Account) withSession(action func(session *httpSession) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.loggedIn() {
+		if err := a.login(); err != nil {
+			return err
+		}
+	}
+	session, err := a.newSession()
+	if err != nil {
+		return err
+	}
+	err = action(session)
+	if !errors.Is(err, ErrSessionExpired) {
+		return err
+	}
+
+	log.Info("PCPartPicker session expired, logging in again")
+	if err := a.login(); err != nil {
+		return err
+	}
+	session, err = a.newSession()
+	if err != nil {
+		return err
+	}
+	return action(session)
+}
+
+func (a *Account) newSession() (*httpSession, error) {
+	session := &httpSession{
+		client:    &http.Client{Jar: a.jar, Timeout: a.Timeout, Transport: a.Transport},
+		ctx:       context.Background(),
+		userAgent: a.UserAgent,
+		loginPath: a.loginURL().Path,
+		end:       func() {},
+	}
+
+	if a.Proxies != nil {
+		proxyURL, err := a.Proxies.Session(a.proxySession())
+		if err != nil {
+			return nil, err
+		}
+		if session.client.Transport == nil {
+			session.client.Transport = proxy.NewTransport(a.Proxies)
+		}
+		session.ctx = proxy.WithURL(session.ctx, proxyURL)
+	}
+	return session, nil
+}
+
+// proxySession is the key of the proxy session of the account.
+func (a *Account) proxySession() string {
+	return "account " + a.Username
+}
+
+func (a *Account) prefixURL() string {
+	return a.Site.BuildPrefixURL(a.Region)
+}
+
+func (a *Account) loginURL() *url.URL {
+	u, _ := url.Parse(a.prefixURL() + loginPath)
+	return u
+}
+
+func (a *Account) savedURL() string {
+	return a.prefixURL() + "user/" + url.PathEscape(a.Username) + "/saved/"
+}
+
+// loggedIn reports whether the jar holds a session cookie.
+func (a *Account) loggedIn() bool {
+	for _, cookie := range a.jar.Cookies(a.loginURL()) {
+		if cookie.Name == sessionCookie {
+			return true
+		}
+	}
+	return false
+}
+
+// login forgets the previous session and submits the login form.
+func (a *Account) login() error {
+	if a.Username == "" || a.Password == "" {
+		return fmt.Errorf("%w: missing credentials", ErrLoginFailed)
+	}
+	if err := a.jar.clear(); err != nil {
+		return err
+	}
+
+	session, err := a.newSession()
+	if err != nil {
+		return err
+	}
+	session.loginPath = ""
+	doc, err := session.get(a.loginURL().String())
+	if err != nil {
+		return err
+	}
+	form := doc.Find("form").FilterFunction(func(_ int, form *goquery.Selection) bool {
+		return form.Find("input[type=password]").Length() > 0
+	}).First()
+	if form.Length() == 0 {
+		return fmt.Errorf("%w: login", ErrNoAccountForm)
+	}
+
+	values := formValues(form, submitButton(form, ""))
+	values.Set(form.Find("input[type=password]").First().AttrOr("name", ""), a.Password)
+	username := form.Find("input:not([type]), input[type=text], input[type=email]").First()
+	values.Set(username.AttrOr("name", ""), a.Username)
+	method, target, body, err := formRequest(doc, form, values)
+	if err != nil {
+		return err
+	}
+
+	landed, err := session.do(method, target, body)
+	if err != nil {
+		return err
+	}
+	if landed.Url.Path == a.loginURL().Path || !a.loggedIn() {
+		return ErrLoginFailed
+	}
+	log.Info("Logged in to PCPartPicker as ", a.Username)
+	return nil
+}
+
+// savedLists returns the saved lists linked from a page of saved lists, with
+// the URL of their link, which opens private lists too.
+func (a *Account) savedLists(doc *goquery.Document) []models.SavedList {
+	var lists []models.SavedList
+	seen := map[string]bool{}
+	prefixURL, _ := url.Parse(a.prefixURL())
+	doc.Find(`a[href*="/saved/` + savedListView + `"]`).Each(func(_ int, link *goquery.Selection) {
+		href := link.AttrOr("href", "")
+		id := href[strings.Index(href, savedListView)+len(savedListView):]
+		ref, err := url.Parse(href)
+		if id == "" || err != nil || seen[id] {
+			return
+		}
+		seen[id] = true
+		lists = append(lists, models.SavedList{
+			ID:   id,
+			Name: strings.TrimSpace(link.Text()),
+			URL:  prefixURL.ResolveReference(ref).String(),
+		})
+	})
+	return lists
+}
+
+// savedRow returns the page of saved lists and the row of the list with the given ID.
+func (a *Account) savedRow(session *httpSession, id string) (*goquery.Document, *goquery.Selection, error) {
+	doc, err := session.get(a.savedURL())
+	if err != nil {
+		return nil, nil, err
+	}
+	link := doc.Find(`a[href*="/saved/` + savedListView + `"]`).FilterFunction(func(_ int, link *goquery.Selection) bool {
+		return strings.HasSuffix(link.AttrOr("href", ""), savedListView+id)
+	}).First()
+	if id == "" || link.Length() == 0 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNoSavedList, id)
+	}
+	return doc, link.Closest("tr, li"), nil
+}
+
+// submitForm submits the first of forms with a button labelled label, setting
+// its name field to name when name isn't empty.
+func (a *Account) submitForm(session *httpSession, doc *goquery.Document, forms *goquery.Selection, label string, name string) (*goquery.Document, error) {
+	form := findForm(forms, label)
+	if form == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoAccountForm, label)
+	}
+
+	values := formValues(form, submitButton(form, label))
+	if name != "" {
+		field := form.Find(fieldSelector("name")).First()
+		if field.Length() == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNoAccountForm, label)
+		}
+		values.Set(field.AttrOr("name", ""), name)
+	}
+	method, target, body, err := formRequest(doc, form, values)
+	if err != nil {
+		return nil, err
+	}
+	return session.do(method, target, body)
+}
+
+// saveForms returns the forms of a part list page naming the list, rather than a part.
+func saveForms(doc *goquery.Document) *goquery.Selection {
+	return doc.Find("form").FilterFunction(func(_ int, form *goquery.Selection) bool {
+		return form.Closest("tr.tr__product").Length() == 0 && form.Find(fieldSelector("name")).Length() > 0
+	})
+}
+
+// findForm returns the first form of selection, or inside it, with a button labelled label.
+func findForm(selection *goquery.Selection, label string) *goquery.Selection {
+	forms := selection.Filter("form").AddSelection(selection.Find("form")).FilterFunction(func(_ int, form *goquery.Selection) bool {
+		return submitButton(form, label).Length() > 0
+	})
+	if forms.Length() == 0 {
+		return nil
+	}
+	return forms.First()
+}
@@ -0,0 +1,311 @@
+package pcpartpicker_automation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/pkg/proxy"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+// standInSaved is a part list saved to an account of the stand-in.
+type standInSaved struct {
+	ID    string
+	User  string
+	Name  string
+	Parts []standInPart
+}
+
+// newAccountStandIn returns a stand-in with an account logging in as user with password.
+func newAccountStandIn(t *testing.T, user string, password string) (*standIn, *int) {
+	s := newStandIn(t)
+	logins := new(int)
+
+	loginPage := func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "csrftoken", Value: "token", Path: "/"})
+		fmt.Fprint(w, `<html><body><form method="post" action="/accounts/login/">
+<input type="hidden" name="csrfmiddlewaretoken" value="token">
+<input type="text" name="username"><input type="password" name="password">
+<button type="submit">Log In</button></form></body></html>`)
+	}
+	s.mux.HandleFunc("GET /accounts/login/", loginPage)
+	s.mux.HandleFunc("POST /accounts/login/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-CSRFToken") != "token" || r.PostFormValue("csrfmiddlewaretoken") != "token" || r.Referer() == "" {
+			http.Error(w, "CSRF verification failed", http.StatusForbidden)
+			return
+		}
+		if r.PostFormValue("username") != user || r.PostFormValue("password") != password {
+			loginPage(w, r)
+			return
+		}
+
+		s.mu.Lock()
+		*logins++
+		session := fmt.Sprint(len(s.lists))
+		s.lists[session] = nil
+		s.accounts[session] = user
+		s.mu.Unlock()
+		http.SetCookie(w, &http.Cookie{Name: "sessionid", Value: session, Path: "/", MaxAge: 3600})
+		http.Redirect(w, r, "/user/"+user+"/saved/", http.StatusFound)
+	})
+
+	s.mux.HandleFunc("/user/{user}/saved/{action...}", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		session, err := r.Cookie("sessionid")
+		if err != nil || s.accounts[session.Value] != r.PathValue("user") {
+			http.Redirect(w, r, "/accounts/login/?next="+r.URL.Path, http.StatusFound)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			if r.Header.Get("X-CSRFToken") != "token" || r.PostFormValue("csrfmiddlewaretoken") != "token" {
+				http.Error(w, "CSRF verification failed", http.StatusForbidden)
+				return
+			}
+			for i, list := range s.saved {
+				if list.ID != r.PostFormValue("list") {
+					continue
+				}
+				switch r.PathValue("action") {
+				case "rename/":
+					s.saved[i].Name = r.PostFormValue("name")
+				case "delete/":
+					s.saved = slices.Delete(s.saved, i, i+1)
+				}
+				break
+			}
+			http.Redirect(w, r, "/user/"+user+"/saved/", http.StatusFound)
+			return
+		}
+
+		// The newest lists come first
+		fmt.Fprint(w, `<html><body><table>`)
+		for i := len(s.saved) - 1; i >= 0; i-- {
+			list := s.saved[i]
+			fmt.Fprintf(w, `<tr><td><a href="/user/%s/saved/#view=%s">%s</a></td><td>
+<form method="post" action="rename/"><input type="hidden" name="csrfmiddlewaretoken" value="token">
+<input type="hidden" name="list" value="%s"><input name="name"><button type="submit">Rename</button></form>
+<form method="post" action="delete/"><input type="hidden" name="csrfmiddlewaretoken" value="token">
+<input type="hidden" name="list" value="%s"><button type="submit">Delete</button></form></td></tr>`,
+				list.User, list.ID, list.Name, list.ID, list.ID)
+		}
+		fmt.Fprint(w, `</table></body></html>`)
+	})
+	return s, logins
+}
+
+// expire forgets every logged in session.
+func (s *standIn) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.accounts)
+}
+
+func (s *standIn) savedLists() []standInSaved {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.saved)
+}
+
+func TestAccountSavedLists(t *testing.T) {
+	stand, logins := newAccountStandIn(t, "builder", "hunter2")
+	account, err := NewAccount(stand.site(t), "builder", "hunter2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := account.SaveList("Gaming", []models.ListItem{
+		{URL: stand.URL + "/product/form1/cpu", Quantity: 2},
+		{URL: stand.URL + "/product/link1/gpu"},
+	})
+	if err != nil {
+		t.Fatalf("SaveList: %v", err)
+	}
+	// Private lists only open from the saved lists page
+	want := models.SavedList{ID: "Sav0", Name: "Gaming", URL: stand.site(t).BuildPrefixURL("") + "user/builder/saved/#view=Sav0"}
+	if *saved != want || !stand.site(t).MatchPartListURL(saved.URL) {
+		t.Errorf("expected saved list %+v, got %+v", want, *saved)
+	}
+	if parts := stand.savedLists()[0].Parts; !slices.Equal(parts, []standInPart{{Product: "form1", Quantity: 2}, {Product: "link1", Quantity: 1}}) {
+		t.Errorf("unexpected parts in the saved list %+v", parts)
+	}
+
+	// A new list doesn't start from the parts of the previous one
+	if _, err := account.SaveList("Office", []models.ListItem{{URL: stand.URL + "/product/link1/gpu"}}); err != nil {
+		t.Fatalf("SaveList: %v", err)
+	}
+	if parts := stand.savedLists()[1].Parts; len(parts) != 1 {
+		t.Errorf("expected a single part in the second list, got %+v", parts)
+	}
+
+	if err := account.RenameList("Sav1", "Workstation"); err != nil {
+		t.Fatalf("RenameList: %v", err)
+	}
+	if err := account.DeleteList("Sav0"); err != nil {
+		t.Fatalf("DeleteList: %v", err)
+	}
+	lists, err := account.SavedLists()
+	if err != nil {
+		t.Fatalf("SavedLists: %v", err)
+	}
+	if len(lists) != 1 || lists[0].ID != "Sav1" || lists[0].Name != "Workstation" {
+		t.Errorf("unexpected saved lists %+v", lists)
+	}
+
+	if err := account.DeleteList("Sav0"); !errors.Is(err, ErrNoSavedList) {
+		t.Errorf("expected a missing saved list, got %v", err)
+	}
+
+	// A name already taken still identifies the new list
+	again, err := account.SaveList("Workstation", []models.ListItem{{URL: stand.URL + "/product/form1/cpu"}})
+	if err != nil {
+		t.Fatalf("SaveList: %v", err)
+	}
+	if again.ID != "Sav2" {
+		t.Errorf("expected the new list Sav2, got %+v", again)
+	}
+	if *logins != 1 {
+		t.Errorf("expected a single login, got %d", *logins)
+	}
+}
+
+func TestAccountLogsInAgainWhenTheSessionExpires(t *testing.T) {
+	stand, logins := newAccountStandIn(t, "builder", "hunter2")
+	account, err := NewAccount(stand.site(t), "builder", "hunter2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := account.EnsureSession(); err != nil {
+		t.Fatalf("EnsureSession: %v", err)
+	}
+
+	stand.expire()
+	if _, err := account.SavedLists(); err != nil {
+		t.Fatalf("SavedLists: %v", err)
+	}
+	stand.expire()
+	if _, err := account.SaveList("Gaming", []models.ListItem{{URL: stand.URL + "/product/form1/cpu"}}); err != nil {
+		t.Fatalf("SaveList: %v", err)
+	}
+	if *logins != 3 {
+		t.Errorf("expected a login per expired session, got %d", *logins)
+	}
+}
+
+func TestAccountGoesThroughTheProxyPool(t *testing.T) {
+	stand, _ := newAccountStandIn(t, "builder", "hunter2")
+	var proxied atomic.Int32
+	forward := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		stand.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(forward.Close)
+	proxies, err := proxy.NewPool([]proxy.Entry{{URL: forward.URL, Weight: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := NewAccount(stand.site(t), "builder", "hunter2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account.Proxies = proxies
+
+	if _, err := account.SavedLists(); err != nil {
+		t.Fatalf("SavedLists: %v", err)
+	}
+	if _, err := account.SavedLists(); err != nil {
+		t.Fatalf("SavedLists: %v", err)
+	}
+	if proxied.Load() == 0 {
+		t.Error("expected the account to go through the proxy")
+	}
+	if stats := proxies.Stats(); stats[0].Requests != 3 {
+		t.Errorf("expected a proxy session per login and action, got %+v", stats)
+	}
+
+	// Requests made outside of the account keep to its session and proxy
+	jar, proxyURL, err := account.Session()
+	if err != nil {
+		t.Fatalf("Session: %v", err)
+	}
+	if proxyURL == nil || proxyURL.String() != forward.URL || !account.loggedIn() || jar != account.jar {
+		t.Errorf("expected the session of the account through %s, got %v", forward.URL, proxyURL)
+	}
+}
+
+func TestAccountRejectedCredentials(t *testing.T) {
+	stand, _ := newAccountStandIn(t, "builder", "hunter2")
+	account, err := NewAccount(stand.site(t), "builder", "wrong", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := account.SavedLists(); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("expected a failed login, got %v", err)
+	}
+}
+
+func TestNewCookieStoreRejectsInvalidKeys(t *testing.T) {
+	for _, key := range []string{"", "secret", base64.StdEncoding.EncodeToString(make([]byte, 16))} {
+		if _, err := NewCookieStore("session.enc", key); !errors.Is(err, ErrInvalidCookieKey) {
+			t.Errorf("expected %q to be rejected, got %v", key, err)
+		}
+	}
+}
+
+func TestAccountRestoresEncryptedSession(t *testing.T) {
+	stand, logins := newAccountStandIn(t, "builder", "hunter2")
+	path := filepath.Join(t.TempDir(), "session.enc")
+	store, err := NewCookieStore(path, base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account, err := NewAccount(stand.site(t), "builder", "hunter2", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := account.EnsureSession(); err != nil {
+		t.Fatalf("EnsureSession: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("sessionid")) {
+		t.Error("expected the session to be encrypted")
+	}
+
+	// Another process with the same key reuses the session
+	restored, err := NewAccount(stand.site(t), "builder", "hunter2", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.SavedLists(); err != nil || *logins != 1 {
+		t.Errorf("expected the session to be restored, got %d logins, %v", *logins, err)
+	}
+
+	// A store that can't be decrypted is ignored
+	wrongStore, err := NewCookieStore(path, base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrongStore.load(); !errors.Is(err, ErrCorruptCookies) {
+		t.Errorf("expected the store not to decrypt, got %v", err)
+	}
+	other, err := NewAccount(stand.site(t), "builder", "hunter2", wrongStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.SavedLists(); err != nil || *logins != 2 {
+		t.Errorf("expected to log in again, got %d logins, %v", *logins, err)
+	}
+}
@@ -0,0 +1,209 @@
+package pcpartpicker_automation
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2/log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCorruptCookies is returned when the cookie store can't be decrypted, most
+	// likely because its key changed.
+	ErrCorruptCookies = errors.New("could not decrypt the cookie store")
+	// ErrInvalidCookieKey is returned when a cookie store key isn't 32 bytes encoded in base64.
+	ErrInvalidCookieKey = errors.New("the cookie store key must be 32 random bytes encoded in base64")
+)
+
+// storedCookie is a cookie and the URL that set it.
+type storedCookie struct {
+	URL    string
+	Cookie *http.Cookie
+}
+
+// CookieStore keeps cookies in a file encrypted with AES-256-GCM.
+type CookieStore struct {
+	Path string
+
+	aead cipher.AEAD
+}
+
+// NewCookieStore returns a store of the cookies in the file at path, encrypted
+// with key, 32 random bytes encoded in base64 such as the output of
+// "openssl rand -base64 32".
+func NewCookieStore(path string, key string) (*CookieStore, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		return nil, ErrInvalidCookieKey
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &CookieStore{Path: path, aead: aead}, nil
+}
+
+// load returns the stored cookies, or none when the file doesn't exist yet.
+func (s *CookieStore) load() ([]storedCookie, error) {
+	sealed, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	size := s.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, ErrCorruptCookies
+	}
+	data, err := s.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return nil, ErrCorruptCookies
+	}
+
+	var cookies []storedCookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return nil, ErrCorruptCookies
+	}
+	return cookies, nil
+}
+
+// save encrypts and writes cookies, replacing the previous file atomically.
+func (s *CookieStore) save(cookies []storedCookie) error {
+	data, err := json.Marshal(cookies)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := s.aead.Seal(nonce, nonce, data, nil)
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, sealed, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+// persistentJar is a cookie jar saving every cookie it is given to a CookieStore.
+type persistentJar struct {
+	jar   *cookiejar.Jar
+	store *CookieStore
+
+	mu      sync.Mutex
+	cookies map[string]storedCookie
+}
+
+// newPersistentJar returns a jar holding the unexpired cookies of store. A
+// store that can't be decrypted is ignored, and overwritten by the next cookie.
+func newPersistentJar(store *CookieStore) (*persistentJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	j := &persistentJar{jar: jar, store: store, cookies: map[string]storedCookie{}}
+	if store == nil {
+		return j, nil
+	}
+
+	stored, err := store.load()
+	if errors.Is(err, ErrCorruptCookies) {
+		log.Warn("Ignoring the saved PCPartPicker session: ", err)
+	} else if err != nil {
+		return nil, err
+	}
+	for _, cookie := range stored {
+		u, err := url.Parse(cookie.URL)
+		if err != nil || !cookie.Cookie.Expires.IsZero() && cookie.Cookie.Expires.Before(time.Now()) {
+			continue
+		}
+		j.jar.SetCookies(u, []*http.Cookie{cookie.Cookie})
+		j.cookies[cookieKey(u, cookie.Cookie)] = cookie
+	}
+	return j, nil
+}
+
+func cookieKey(u *url.URL, cookie *http.Cookie) string {
+	domain := cookie.Domain
+	if domain == "" {
+		domain = u.Hostname()
+	}
+	return domain + ";" + cookie.Path + ";" + cookie.Name
+}
+
+// SetCookies stores the cookies set by a response to u and saves them.
+func (j *persistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.jar.SetCookies(u, cookies)
+	if j.store == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		stored := *cookie
+		// Max-Age is relative to when the cookie was set
+		if stored.MaxAge > 0 {
+			stored.Expires = now.Add(time.Duration(stored.MaxAge) * time.Second)
+			stored.MaxAge = 0
+		}
+		key := cookieKey(u, &stored)
+		if stored.MaxAge < 0 || !stored.Expires.IsZero() && stored.Expires.Before(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		j.cookies[key] = storedCookie{URL: u.String(), Cookie: &stored}
+	}
+
+	all := make([]storedCookie, 0, len(j.cookies))
+	for _, cookie := range j.cookies {
+		all = append(all, cookie)
+	}
+	if err := j.store.save(all); err != nil {
+		log.Warn("Could not save the PCPartPicker session: ", err)
+	}
+}
+
+// Cookies returns the cookies to send to u.
+func (j *persistentJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jar.Cookies(u)
+}
+
+// clear forgets every cookie, such as the cookies of an expired session.
+func (j *persistentJar) clear() error {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.jar = jar
+	j.cookies = map[string]storedCookie{}
+	if j.store == nil {
+		return nil
+	}
+	return j.store.save(nil)
+}
@@ -0,0 +1,199 @@
+package pcpartpicker_automation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidEdit is returned when an edit of a part list is not valid or targets a missing part.
+var ErrInvalidEdit = errors.New("invalid list edit")
+
+// EditOp is the operation of a ListEdit.
+type EditOp string
+
+const (
+	EditAdd    EditOp = "add"
+	EditRemove EditOp = "remove"
+	EditSwap   EditOp = "swap"
+)
+
+// typeAliases maps the short names of part types to the names PCPartPicker uses.
+var typeAliases = map[string]string{
+	"gpu":  "video card",
+	"ram":  "memory",
+	"psu":  "power supply",
+	"mobo": "motherboard",
+	"fan":  "case fan",
+}
+
+// ListEdit is an operation on the parts of a list. Remove and swap target the
+// part whose product URL is Target, or the Index-th part (from 1, the first one
+// when 0) of Type, such as "Storage" or "GPU". Add and swap use Item, a swapped
+// product keeping the quantity of the part it replaces unless Item sets one.
+type ListEdit struct {
+	Op     EditOp
+	Type   string
+	Index  int
+	Target string
+	Item   models.ListItem
+}
+
+// EditList builds a new part list from the parts of list with the edits
+// applied and returns the URL of the new list. Permalinks can't be changed, so
+// the list is rebuilt on the region it belongs to. Custom parts keep their
+// price, while the price overrides of other parts are lost.
+func EditList(builder ListBuilder, site *utils.Site, list *models.PartList, edits []ListEdit) (*models.SearchPart, error) {
+	region, err := listRegion(site, list)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := ApplyEdits(site, list, edits)
+	if err != nil {
+		return nil, err
+	}
+	return builder.ProcessListItems(region, items)
+}
+
+// listRegion returns the region a part list belongs to.
+func listRegion(site *utils.Site, list *models.PartList) (string, error) {
+	u, err := url.Parse(list.URL)
+	if err != nil {
+		return "", err
+	}
+	region := site.Region(u.Hostname())
+	if region == "" {
+		return "", ErrInvalidRegion
+	}
+	return region, nil
+}
+
+// ApplyEdits returns the items of a part list with the edits applied in order.
+// Products are matched by ID, whatever the region and name in their URL, and
+// added or swapped in from the region of the list.
+func ApplyEdits(site *utils.Site, list *models.PartList, edits []ListEdit) ([]models.ListItem, error) {
+	region, err := listRegion(site, list)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []models.ListItem
+	var types []string
+	for _, part := range list.Parts {
+		item := models.ListItem{URL: part.URL, Quantity: max(part.Quantity, 1)}
+		if part.Custom {
+			item = models.ListItem{Quantity: item.Quantity, Custom: &models.CustomPart{Type: part.Type, Name: part.Name}}
+			if part.Vendor.InStock {
+				item.Override = &models.PriceOverride{Vendor: part.Vendor.Name, Price: part.Vendor.Price.Total}
+			}
+		}
+		items, types = addItem(site, items, types, item, part.Type)
+	}
+
+	for i, edit := range edits {
+		invalid := func(reason string) error {
+			return fmt.Errorf("%w: edit %d: %s", ErrInvalidEdit, i, reason)
+		}
+
+		if edit.Item.URL != "" {
+			if edit.Item.URL = site.RegionalProductURL(edit.Item.URL, region); edit.Item.URL == "" {
+				return nil, invalid("invalid part URL")
+			}
+		}
+
+		switch edit.Op {
+		case EditAdd:
+			if edit.Item.URL == "" && edit.Item.Custom == nil {
+				return nil, invalid("nothing to add")
+			}
+			itemType := ""
+			if edit.Item.Custom != nil {
+				itemType = edit.Item.Custom.Type
+			}
+			items, types = addItem(site, items, types, edit.Item, itemType)
+		case EditRemove, EditSwap:
+			target := findPart(site, items, types, edit)
+			if target < 0 {
+				return nil, invalid("no such part in the list")
+			}
+			if edit.Op == EditRemove {
+				items = append(items[:target], items[target+1:]...)
+				types = append(types[:target], types[target+1:]...)
+				continue
+			}
+
+			if edit.Item.URL == "" && edit.Item.Custom == nil {
+				return nil, invalid("nothing to swap in")
+			}
+			item := edit.Item
+			if item.Quantity == 0 {
+				item.Quantity = items[target].Quantity
+			}
+			itemType := types[target]
+			if item.Custom != nil {
+				itemType = item.Custom.Type
+			}
+			// A product already in the list takes the quantity of the swapped out part
+			items = append(items[:target], items[target+1:]...)
+			types = append(types[:target], types[target+1:]...)
+			items, types = insertItem(site, items, types, target, item, itemType)
+		default:
+			return nil, invalid(fmt.Sprintf("unknown operation %q", edit.Op))
+		}
+	}
+	return items, nil
+}
+
+// addItem appends an item of the given type, adding to the quantity of the
+// same product when the list already holds it.
+func addItem(site *utils.Site, items []models.ListItem, types []string, item models.ListItem, itemType string) ([]models.ListItem, []string) {
+	return insertItem(site, items, types, len(items), item, itemType)
+}
+
+// insertItem is like addItem but inserts a new item at index i.
+func insertItem(site *utils.Site, items []models.ListItem, types []string, i int, item models.ListItem, itemType string) ([]models.ListItem, []string) {
+	if id := site.ExtractProductID(item.URL); id != "" {
+		for j := range items {
+			if site.ExtractProductID(items[j].URL) == id {
+				items[j].Quantity += max(item.Quantity, 1)
+				if item.Override != nil {
+					items[j].Override = item.Override
+				}
+				return items, types
+			}
+		}
+	}
+	return slices.Insert(items, i, item), slices.Insert(types, i, itemType)
+}
+
+// findPart returns the index of the item an edit targets, or -1.
+func findPart(site *utils.Site, items []models.ListItem, types []string, edit ListEdit) int {
+	if edit.Target != "" {
+		id := site.ExtractProductID(edit.Target)
+		for i, item := range items {
+			if id != "" && site.ExtractProductID(item.URL) == id {
+				return i
+			}
+		}
+		return -1
+	}
+
+	wanted := strings.ToLower(edit.Type)
+	if alias, ok := typeAliases[wanted]; ok {
+		wanted = alias
+	}
+	n := max(edit.Index, 1)
+	for i, itemType := range types {
+		if wanted != "" && strings.ToLower(itemType) == wanted {
+			if n--; n == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,136 @@
+package pcpartpicker_automation
+
+import (
+	"errors"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+var editSite = utils.MustNewSite("https", "pcpartpicker.com", nil)
+
+func editedList() *models.PartList {
+	return &models.PartList{
+		URL: "https://uk.pcpartpicker.com/list/abcd12",
+		Parts: []models.ListPart{
+			{Type: "CPU", URL: "https://uk.pcpartpicker.com/product/Yg3mP6/cpu", Quantity: 1},
+			{Type: "Storage", URL: "https://uk.pcpartpicker.com/product/aaaa11/ssd", Quantity: 1},
+			{Type: "Storage", URL: "https://uk.pcpartpicker.com/product/bbbb22/hdd", Quantity: 1},
+			{Type: "Video Card", URL: "https://uk.pcpartpicker.com/product/cccc33/gpu", Quantity: 1},
+			{Type: "Case Fan", URL: "https://uk.pcpartpicker.com/product/DsyH99/fan", Quantity: 3},
+			{Type: "Case", Name: "Wooden Case", Custom: true, Quantity: 1, Vendor: models.Vendor{InStock: true, Price: models.Price{Total: 89}}},
+		},
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	items, err := ApplyEdits(editSite, editedList(), []ListEdit{
+		{Op: EditSwap, Type: "GPU", Item: models.ListItem{URL: "https://pcpartpicker.com/product/dddd44/other-gpu"}},
+		{Op: EditRemove, Type: "storage", Index: 2},
+		{Op: EditAdd, Item: models.ListItem{URL: "https://pcpartpicker.com/product/DsyH99/fan", Quantity: 2}},
+		{Op: EditRemove, Target: "https://pcpartpicker.com/product/Yg3mP6/any-name"},
+		{Op: EditAdd, Item: models.ListItem{Custom: &models.CustomPart{Type: "Other", Name: "LED Strip"}}},
+	})
+	if err != nil {
+		t.Fatalf("ApplyEdits: %v", err)
+	}
+
+	want := []models.ListItem{
+		{URL: "https://uk.pcpartpicker.com/product/aaaa11/ssd", Quantity: 1},
+		{URL: "https://uk.pcpartpicker.com/product/dddd44/other-gpu", Quantity: 1},
+		{URL: "https://uk.pcpartpicker.com/product/DsyH99/fan", Quantity: 5},
+		{Quantity: 1, Custom: &models.CustomPart{Type: "Case", Name: "Wooden Case"}, Override: &models.PriceOverride{Price: 89}},
+		{Custom: &models.CustomPart{Type: "Other", Name: "LED Strip"}},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("unexpected items\n got %+v\nwant %+v", items, want)
+	}
+}
+
+func TestApplyEditsMergesSwappedInParts(t *testing.T) {
+	items, err := ApplyEdits(editSite, editedList(), []ListEdit{
+		{Op: EditSwap, Type: "Storage", Index: 2, Item: models.ListItem{URL: "https://pcpartpicker.com/product/aaaa11/ssd"}},
+		{Op: EditSwap, Type: "GPU", Item: models.ListItem{URL: "https://uk.pcpartpicker.com/product/DsyH99/fan", Quantity: 2}},
+	})
+	if err != nil {
+		t.Fatalf("ApplyEdits: %v", err)
+	}
+
+	want := []models.ListItem{
+		{URL: "https://uk.pcpartpicker.com/product/Yg3mP6/cpu", Quantity: 1},
+		{URL: "https://uk.pcpartpicker.com/product/aaaa11/ssd", Quantity: 2},
+		{URL: "https://uk.pcpartpicker.com/product/DsyH99/fan", Quantity: 5},
+		{Quantity: 1, Custom: &models.CustomPart{Type: "Case", Name: "Wooden Case"}, Override: &models.PriceOverride{Price: 89}},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("unexpected items\n got %+v\nwant %+v", items, want)
+	}
+	if _, _, err := planItems(editSite, "uk", items); err != nil {
+		t.Errorf("expected the merged items to be buildable, got %v", err)
+	}
+}
+
+func TestApplyEditsRejectsInvalidEdits(t *testing.T) {
+	for name, edit := range map[string]ListEdit{
+		"missing part":      {Op: EditRemove, Type: "Storage", Index: 3},
+		"missing target":    {Op: EditSwap, Target: "https://pcpartpicker.com/product/zzzz99/none", Item: models.ListItem{URL: "https://pcpartpicker.com/product/dddd44/gpu"}},
+		"empty swap":        {Op: EditSwap, Type: "CPU"},
+		"empty add":         {Op: EditAdd},
+		"unknown operation": {Op: "move", Type: "CPU"},
+		"not a product":     {Op: EditAdd, Item: models.ListItem{URL: "https://pcpartpicker.com/list/abcd12"}},
+	} {
+		if _, err := ApplyEdits(editSite, editedList(), []ListEdit{edit}); !errors.Is(err, ErrInvalidEdit) {
+			t.Errorf("%s: expected an invalid edit, got %v", name, err)
+		}
+	}
+}
+
+type recordingBuilder struct {
+	region string
+	items  []models.ListItem
+}
+
+func (b *recordingBuilder) ProcessListItems(region string, items []models.ListItem) (*models.SearchPart, error) {
+	b.region, b.items = region, items
+	return &models.SearchPart{URL: "https://uk.pcpartpicker.com/list/new123"}, nil
+}
+
+func TestEditListRebuildsOnTheListRegion(t *testing.T) {
+	builder := &recordingBuilder{}
+	list, err := EditList(builder, editSite, editedList(), []ListEdit{{Op: EditRemove, Type: "Case"}})
+	if err != nil {
+		t.Fatalf("EditList: %v", err)
+	}
+	if list.URL != "https://uk.pcpartpicker.com/list/new123" || builder.region != "uk" || len(builder.items) != 5 {
+		t.Errorf("unexpected rebuild of %s on %q with %d items", list.URL, builder.region, len(builder.items))
+	}
+}
+
+func TestEditListSwapsInPartsOfOtherRegions(t *testing.T) {
+	stand := newStandIn(t)
+	builder, site := stand.regionalBuilder()
+
+	list := &models.PartList{
+		URL: "http://uk.pcpartpicker.test/list/abcd12",
+		Parts: []models.ListPart{
+			{Type: "CPU", URL: "http://uk.pcpartpicker.test/product/form1/cpu", Quantity: 1},
+			{Type: "Video Card", URL: "http://uk.pcpartpicker.test/product/gpu1/gpu", Quantity: 1},
+		},
+	}
+	edited, err := EditList(builder, site, list, []ListEdit{
+		{Op: EditSwap, Type: "GPU", Item: models.ListItem{URL: "http://pcpartpicker.test/product/link1/gpu"}},
+	})
+	if err != nil {
+		t.Fatalf("EditList: %v", err)
+	}
+
+	if edited.URL != "http://uk.pcpartpicker.test/list/Ses000" {
+		t.Errorf("expected a list on the uk region, got %s", edited.URL)
+	}
+	want := []standInPart{{Product: "form1", Quantity: 1}, {Product: "link1", Quantity: 1}}
+	if parts := stand.lists["0"]; !slices.Equal(parts, want) {
+		t.Errorf("unexpected parts in the list %+v", parts)
+	}
+}
@@ -0,0 +1,439 @@
+package pcpartpicker_automation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"github.com/Aquilabot/KreaPC-API/pkg/proxy"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gofiber/fiber/v2/log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	addToPartList    = "add to part list"
+	csrfCookie       = "csrftoken"
+	defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+	// DefaultAddPath is where the script of the "Add to Part List" button is assumed to post
+	// the product, relative to the region. It wasn't taken from a recorded request.
+	DefaultAddPath = "qapi/partlist/add/"
+)
+
+// addButtonIDs are the attributes assumed to hold the product of a scripted
+// "Add to Part List" button. They weren't taken from recorded markup.
+var addButtonIDs = []string{"data-pb-id", "data-product-id", "data-pid"}
+
+var (
+	// ErrNoAddButton is returned when a product page has no usable "Add to Part List" button.
+	ErrNoAddButton = errors.New("no \"Add to Part List\" button")
+	// ErrNoPermalink is returned when the part list page doesn't show the permalink of the list.
+	ErrNoPermalink = errors.New("no part list permalink")
+)
+
+// HTTPBuilder builds part lists without a browser: it keeps the PCPartPicker
+// session in a cookie jar and sends the request of the "Add to Part List"
+// button of every product. Scripted buttons post their product to AddPath.
+// When Proxies is set, each list is built through a single proxy of the pool.
+//
+// HTTPBuilder is experimental: the requests of scripted buttons, AddPath and
+// the attributes holding their product haven't been checked against
+// PCPartPicker, so PlaywrightBuilder remains the builder to rely on.
+type HTTPBuilder struct {
+	Site      *utils.Site
+	Proxies   *proxy.Pool
+	UserAgent string
+	Timeout   time.Duration
+	Transport http.RoundTripper
+	AddPath   string
+}
+
+// NewHTTPBuilder returns an HTTPBuilder for site connecting directly.
+func NewHTTPBuilder(site *utils.Site) *HTTPBuilder {
+	return &HTTPBuilder{Site: site, UserAgent: defaultUserAgent, Timeout: 30 * time.Second, AddPath: DefaultAddPath}
+}
+
+// ProcessPartLinks adds every part link to a new part list and returns the URL of the resulting list.
+func (b *HTTPBuilder) ProcessPartLinks(region string, partLinks []string) (*models.SearchPart, error) {
+	return b.ProcessListItems(region, linkItems(partLinks))
+}
+
+// ProcessListItems adds every item to a new part list, setting its quantity and
+// price, and returns the URL of the resulting list.
+func (b *HTTPBuilder) ProcessListItems(region string, items []models.ListItem) (*models.SearchPart, error) {
+	prefixURL := b.Site.BuildPrefixURL(region)
+	if !b.Site.MatchPCPPURL(prefixURL) {
+		return nil, ErrInvalidRegion
+	}
+	links, edits, err := planItems(b.Site, region, items)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := b.newSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.close()
+	session.addPath = b.AddPath
+
+	// The home page sets the session and CSRF cookies
+	if _, err := session.get(prefixURL); err != nil {
+		return nil, err
+	}
+	doc, err := session.fill(prefixURL, links, edits)
+	if err != nil {
+		return nil, err
+	}
+	return permalink(b.Site, doc)
+}
+
+// httpSession is the cookie jar and egress of a single list-building flow.
+// When loginPath is set, landing on it means the session has expired.
+type httpSession struct {
+	client    *http.Client
+	ctx       context.Context
+	userAgent string
+	referer   string
+	loginPath string
+	addPath   string
+	end       func()
+}
+
+func (b *HTTPBuilder) newSession() (*httpSession, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	session := &httpSession{
+		client:    &http.Client{Jar: jar, Timeout: b.Timeout, Transport: b.Transport},
+		ctx:       context.Background(),
+		userAgent: b.UserAgent,
+		end:       func() {},
+	}
+
+	if b.Proxies != nil {
+		key := newSessionKey()
+		proxyURL, err := b.Proxies.Session(key)
+		if err != nil {
+			return nil, err
+		}
+		if session.client.Transport == nil {
+			session.client.Transport = proxy.NewTransport(b.Proxies)
+		}
+		session.ctx = proxy.WithURL(session.ctx, proxyURL)
+		session.end = func() { b.Proxies.EndSession(key) }
+	}
+	return session, nil
+}
+
+// newSessionKey returns a random key identifying a list-building flow in the proxy pool.
+func newSessionKey() string {
+	key := make([]byte, 8)
+	rand.Read(key)
+	return hex.EncodeToString(key)
+}
+
+func (s *httpSession) close() {
+	s.end()
+}
+
+// get fetches a page and parses it.
+func (s *httpSession) get(pageURL string) (*goquery.Document, error) {
+	return s.do(http.MethodGet, pageURL, nil)
+}
+
+// do sends a request with the headers a browser would send and parses the page it lands on.
+func (s *httpSession) do(method string, target string, form url.Values) (*goquery.Document, error) {
+	return s.send(method, target, form, false)
+}
+
+// send is do for a page navigation, or for a request sent by a script of the page when xhr is set.
+func (s *httpSession) send(method string, target string, form url.Values, xhr bool) (*goquery.Document, error) {
+	body := strings.NewReader("")
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(s.ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	if s.referer != "" {
+		req.Header.Set("Referer", s.referer)
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if token := s.cookie(req.URL, csrfCookie); token != "" {
+			req.Header.Set("X-CSRFToken", token)
+		}
+	}
+	if xhr {
+		req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
+		req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: unexpected status %d", method, target, res.StatusCode)
+	}
+
+	if s.loginPath != "" && res.Request.URL.Path == s.loginPath && req.URL.Path != s.loginPath {
+		return nil, ErrSessionExpired
+	}
+
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	doc.Url = res.Request.URL
+	if !xhr {
+		s.referer = res.Request.URL.String()
+	}
+	return doc, nil
+}
+
+func (s *httpSession) cookie(u *url.URL, name string) string {
+	for _, cookie := range s.client.Jar.Cookies(u) {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// fill adds the parts of links to the part list of the session, applies the
+// edits and returns the resulting part list page.
+func (s *httpSession) fill(prefixURL string, links []string, edits []listEdit) (*goquery.Document, error) {
+	for _, link := range links {
+		if err := s.addPart(prefixURL, link); err != nil {
+			return nil, fmt.Errorf("error adding part from link %s: %w", link, err)
+		}
+	}
+
+	doc, err := s.get(prefixURL + "list/")
+	if err != nil {
+		return nil, err
+	}
+	for _, edit := range edits {
+		if doc, err = s.edit(prefixURL, doc, edit); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// addPart opens a product page and submits its "Add to Part List" button,
+// which lands on the part list of the session.
+func (s *httpSession) addPart(prefixURL string, link string) error {
+	doc, err := s.get(link)
+	if err != nil {
+		return err
+	}
+
+	method, target, form, err := addRequest(doc)
+	if errors.Is(err, ErrNoAddButton) && s.addPath != "" {
+		if productID := scriptedAddButton(doc); productID != "" {
+			return s.addScripted(prefixURL, doc, productID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := s.submit(prefixURL, method, target, form); err != nil {
+		return err
+	}
+	log.Info("Added to Part List and redirection complete")
+	return nil
+}
+
+// addScripted sends the request of a scripted "Add to Part List" button, which
+// posts the product with the CSRF token of the session and then opens the part list.
+func (s *httpSession) addScripted(prefixURL string, doc *goquery.Document, productID string) error {
+	form := url.Values{"pb_id": {productID}}
+	if token := s.cookie(doc.Url, csrfCookie); token != "" {
+		form.Set("csrfmiddlewaretoken", token)
+	}
+	if _, err := s.send(http.MethodPost, prefixURL+s.addPath, form, true); err != nil {
+		return err
+	}
+	if _, err := s.submit(prefixURL, http.MethodGet, prefixURL+"list/", nil); err != nil {
+		return err
+	}
+	log.Info("Added to Part List and redirection complete")
+	return nil
+}
+
+// edit submits the form of the part list page applying an edit, filled with its fields.
+func (s *httpSession) edit(prefixURL string, doc *goquery.Document, edit listEdit) (*goquery.Document, error) {
+	var forms *goquery.Selection
+	if edit.productID != "" {
+		forms = doc.Find("tr.tr__product").FilterFunction(func(_ int, row *goquery.Selection) bool {
+			return row.Find(productLinkSelector(edit.productID)).Length() > 0
+		}).Find("form")
+	} else {
+		forms = doc.Find("form").FilterFunction(func(_ int, form *goquery.Selection) bool {
+			return submitButton(form, addCustomPart).Length() > 0
+		})
+	}
+	for _, field := range edit.fields {
+		forms = forms.FilterFunction(func(_ int, form *goquery.Selection) bool {
+			return form.Find(fieldSelector(field.key)).Length() > 0
+		})
+	}
+	if forms.Length() == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrNoListForm, edit.fields)
+	}
+
+	form := forms.First()
+	values := formValues(form, submitButton(form, ""))
+	for _, field := range edit.fields {
+		name := form.Find(fieldSelector(field.key)).First().AttrOr("name", "")
+		values.Set(name, field.value)
+	}
+	method, target, body, err := formRequest(doc, form, values)
+	if err != nil {
+		return nil, err
+	}
+	return s.submit(prefixURL, method, target, body)
+}
+
+// submit sends a request expected to land back on the part list of the session.
+func (s *httpSession) submit(prefixURL string, method string, target string, form url.Values) (*goquery.Document, error) {
+	list, err := s.do(method, target, form)
+	if err != nil {
+		return nil, err
+	}
+	if list.Url.String() != prefixURL+"list/" {
+		return nil, fmt.Errorf("expected to land on the part list, got %s", list.Url)
+	}
+	return list, nil
+}
+
+// addRequest returns the request sent by the "Add to Part List" button of a
+// product page: the form holding the button, or the link itself.
+func addRequest(doc *goquery.Document) (string, string, url.Values, error) {
+	var form, submit *goquery.Selection
+	doc.Find("form").EachWithBreak(func(_ int, f *goquery.Selection) bool {
+		if button := submitButton(f, addToPartList); button.Length() > 0 {
+			form, submit = f, button
+		}
+		return form == nil
+	})
+	if form != nil {
+		return formRequest(doc, form, formValues(form, submit))
+	}
+
+	var link string
+	doc.Find("a[href]").EachWithBreak(func(_ int, a *goquery.Selection) bool {
+		href := a.AttrOr("href", "")
+		if strings.Contains(strings.ToLower(a.Text()), addToPartList) && href != "#" && !strings.HasPrefix(href, "javascript:") {
+			link = href
+		}
+		return link == ""
+	})
+	if link == "" {
+		return "", "", nil, ErrNoAddButton
+	}
+	target, err := doc.Url.Parse(link)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return http.MethodGet, target.String(), nil, nil
+}
+
+// scriptedAddButton returns the product of the "Add to Part List" button of a
+// product page handled by a script, or an empty string.
+func scriptedAddButton(doc *goquery.Document) string {
+	var productID string
+	doc.Find("a, button").EachWithBreak(func(_ int, button *goquery.Selection) bool {
+		if !strings.Contains(strings.ToLower(button.Text()), addToPartList) {
+			return true
+		}
+		for _, attr := range addButtonIDs {
+			if id := button.AttrOr(attr, ""); id != "" {
+				productID = id
+				break
+			}
+		}
+		return productID == ""
+	})
+	return productID
+}
+
+// submitButton returns the first submit button of a form whose label contains label.
+func submitButton(form *goquery.Selection, label string) *goquery.Selection {
+	return form.Find("button, input[type=submit]").FilterFunction(func(_ int, button *goquery.Selection) bool {
+		text := button.Text() + button.AttrOr("value", "") + button.AttrOr("aria-label", "")
+		return strings.Contains(strings.ToLower(text), label)
+	}).First()
+}
+
+// formValues returns the values a browser submits for a form with its default
+// fields, pressing the submit button.
+func formValues(form *goquery.Selection, submit *goquery.Selection) url.Values {
+	values := url.Values{}
+	form.Find("input, select, textarea").Each(func(_ int, field *goquery.Selection) {
+		name, ok := field.Attr("name")
+		kind := strings.ToLower(field.AttrOr("type", "text"))
+		if !ok || kind == "submit" || kind == "button" || (kind == "checkbox" || kind == "radio") && !field.Is("[checked]") {
+			return
+		}
+		value := field.AttrOr("value", "")
+		if field.Is("select") {
+			option := field.Find("option[selected]")
+			if option.Length() == 0 {
+				option = field.Find("option")
+			}
+			value = option.First().AttrOr("value", option.First().Text())
+		} else if field.Is("textarea") {
+			value = field.Text()
+		}
+		values.Add(name, value)
+	})
+	if name, ok := submit.Attr("name"); ok {
+		values.Add(name, submit.AttrOr("value", ""))
+	}
+	return values
+}
+
+// formRequest returns the method, target and body of a form submitted with values.
+func formRequest(doc *goquery.Document, form *goquery.Selection, values url.Values) (string, string, url.Values, error) {
+	target, err := doc.Url.Parse(form.AttrOr("action", ""))
+	if err != nil {
+		return "", "", nil, err
+	}
+	if strings.EqualFold(form.AttrOr("method", "get"), http.MethodPost) {
+		return http.MethodPost, target.String(), values, nil
+	}
+	target.RawQuery = values.Encode()
+	return http.MethodGet, target.String(), nil, nil
+}
+
+// permalink returns the part list URL shown in the textbox of the part list page.
+func permalink(site *utils.Site, doc *goquery.Document) (*models.SearchPart, error) {
+	var URL string
+	doc.Find("input, textarea").EachWithBreak(func(_ int, field *goquery.Selection) bool {
+		value := field.AttrOr("value", field.Text())
+		if URLs := site.ExtractPartListURLs(value); len(URLs) > 0 {
+			URL = URLs[0]
+		}
+		return URL == ""
+	})
+	if URL == "" {
+		return nil, ErrNoPermalink
+	}
+	return &models.SearchPart{URL: URL}, nil
+}
@@ -0,0 +1,287 @@
+package pcpartpicker_automation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// standInPart is a row of a part list of the stand-in.
+type standInPart struct {
+	Product  string
+	Quantity int
+	Price    string
+	Vendor   string
+	Custom   string
+}
+
+// standIn is a local stand-in for PCPartPicker keeping a part list per session
+// cookie. Sessions logged in to an account can save their list.
+type standIn struct {
+	*httptest.Server
+	mux *http.ServeMux
+
+	mu       sync.Mutex
+	lists    map[string][]standInPart
+	accounts map[string]string
+	saved    []standInSaved
+	savedIDs int
+}
+
+func newStandIn(t *testing.T) *standIn {
+	s := &standIn{lists: map[string][]standInPart{}, accounts: map[string]string{}}
+	mux := http.NewServeMux()
+	s.mux = mux
+
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		session := fmt.Sprint(len(s.lists))
+		s.lists[session] = nil
+		s.mu.Unlock()
+		http.SetCookie(w, &http.Cookie{Name: "csrftoken", Value: "token", Path: "/"})
+		http.SetCookie(w, &http.Cookie{Name: "sessionid", Value: session, Path: "/"})
+		fmt.Fprint(w, `<html><body>PCPartPicker</body></html>`)
+	})
+	mux.HandleFunc("GET /product/{id}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		switch id := r.PathValue("id"); id {
+		case "form1":
+			fmt.Fprintf(w, `<html><body><form method="post" action="/list/add/">
+<input type="hidden" name="csrfmiddlewaretoken" value="token">
+<input type="hidden" name="product" value="%s">
+<button type="submit">Add to Part List</button>
+</form></body></html>`, id)
+		case "link1":
+			fmt.Fprintf(w, `<html><body><a href="/list/add/?product=%s">Add to Part List</a></body></html>`, id)
+		case "script":
+			fmt.Fprint(w, `<html><body><a href="#">Add to Part List</a></body></html>`)
+		default:
+			// The button of PCPartPicker product pages is handled by a script
+			fmt.Fprintf(w, `<html><body><a href="#" class="button button--primary pp_add_part" data-pb-id="%s">Add to Part List</a></body></html>`, id)
+		}
+	})
+	mux.HandleFunc("POST /qapi/partlist/add/", func(w http.ResponseWriter, r *http.Request) {
+		session, err := r.Cookie("sessionid")
+		if err != nil || r.Header.Get("X-Requested-With") != "XMLHttpRequest" ||
+			r.Header.Get("X-CSRFToken") != "token" || r.PostFormValue("csrfmiddlewaretoken") != "token" {
+			http.Error(w, "CSRF verification failed", http.StatusForbidden)
+			return
+		}
+		s.mu.Lock()
+		s.lists[session.Value] = append(s.lists[session.Value], standInPart{Product: r.PostFormValue("pb_id"), Quantity: 1})
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"success": true}`)
+	})
+	mux.HandleFunc("/list/{action}/", s.edit)
+	mux.HandleFunc("GET /list/{$}", func(w http.ResponseWriter, r *http.Request) {
+		session, err := r.Cookie("sessionid")
+		if err != nil {
+			http.Error(w, "no session", http.StatusForbidden)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		fmt.Fprint(w, `<html><body><table>`)
+		for i, part := range s.lists[session.Value] {
+			fmt.Fprintf(w, `<tr class="tr__product"><td class="td__name"><a href="/product/%s/name">%s</a></td>
+<td><form method="post" action="/list/quantity/"><input type="hidden" name="csrfmiddlewaretoken" value="token">
+<input type="hidden" name="row" value="%d"><select name="quantity"><option value="1" selected>1</option><option value="2">2</option></select>
+<button type="submit">Update</button></form></td>
+<td><form method="post" action="/list/price/"><input type="hidden" name="csrfmiddlewaretoken" value="token">
+<input type="hidden" name="row" value="%d"><input name="custom_price"><input name="merchant"><button type="submit">Save</button></form></td></tr>`,
+				part.Product, part.Product, i, i)
+		}
+		fmt.Fprint(w, `</table>`)
+		if s.accounts[session.Value] != "" {
+			fmt.Fprint(w, `<form method="post" action="/list/new/"><input type="hidden" name="csrfmiddlewaretoken" value="token">
+<button type="submit">New List</button></form>
+<form method="post" action="/list/save/"><input type="hidden" name="csrfmiddlewaretoken" value="token">
+<input name="list_name"><button type="submit">Save As</button></form>`)
+		}
+		fmt.Fprintf(w, `<form method="post" action="/list/custom/"><input type="hidden" name="csrfmiddlewaretoken" value="token">
+<select name="category"><option value="CPU">CPU</option><option value="Case">Case</option></select>
+<input name="custom_name"><input name="custom_url"><input name="custom_price"><input name="quantity" value="1">
+<button type="submit">Add Custom Part</button></form>
+<input type="text" value="http://%s/list/Ses%s00"></body></html>`, r.Host, session.Value)
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// edit adds a part to the list of the session, or edits one of its rows.
+func (s *standIn) edit(w http.ResponseWriter, r *http.Request) {
+	session, err := r.Cookie("sessionid")
+	if err != nil {
+		http.Error(w, "no session", http.StatusForbidden)
+		return
+	}
+	if r.Method == http.MethodPost && (r.Header.Get("X-CSRFToken") != "token" || r.PostFormValue("csrfmiddlewaretoken") != "token" || r.Referer() == "") {
+		http.Error(w, "CSRF verification failed", http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := s.lists[session.Value]
+	quantity, _ := strconv.Atoi(r.FormValue("quantity"))
+	row, err := strconv.Atoi(r.FormValue("row"))
+	if action := r.PathValue("action"); (action == "quantity" || action == "price") && (err != nil || row >= len(list)) {
+		http.Error(w, "no such row", http.StatusBadRequest)
+		return
+	}
+
+	switch r.PathValue("action") {
+	case "add":
+		list = append(list, standInPart{Product: r.FormValue("product"), Quantity: 1})
+	case "quantity":
+		list[row].Quantity = quantity
+	case "price":
+		list[row].Price, list[row].Vendor = r.FormValue("custom_price"), r.FormValue("merchant")
+	case "custom":
+		name := r.FormValue("category") + ": " + r.FormValue("custom_name") + " " + r.FormValue("custom_url")
+		list = append(list, standInPart{Custom: name, Quantity: quantity, Price: r.FormValue("custom_price")})
+	case "new":
+		list = nil
+	case "save":
+		user := s.accounts[session.Value]
+		if user == "" {
+			http.Redirect(w, r, "/accounts/login/", http.StatusFound)
+			return
+		}
+		s.saved = append(s.saved, standInSaved{ID: fmt.Sprintf("Sav%d", s.savedIDs), User: user, Name: r.FormValue("list_name"), Parts: slices.Clone(list)})
+		s.savedIDs++
+	}
+	s.lists[session.Value] = list
+	http.Redirect(w, r, "/list/", http.StatusFound)
+}
+
+func (s *standIn) site(t *testing.T) *utils.Site {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return utils.MustNewSite("http", u.Host, nil)
+}
+
+// regionalBuilder returns an HTTPBuilder for the pcpartpicker.test site whose
+// regions are all served by the stand-in.
+func (s *standIn) regionalBuilder() (*HTTPBuilder, *utils.Site) {
+	site := utils.MustNewSite("http", "pcpartpicker.test", nil)
+	builder := NewHTTPBuilder(site)
+	builder.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, s.Listener.Addr().String())
+		},
+	}
+	return builder, site
+}
+
+func TestHTTPBuilderAddsParts(t *testing.T) {
+	stand := newStandIn(t)
+	site := stand.site(t)
+	builder := NewHTTPBuilder(site)
+
+	list, err := builder.ProcessPartLinks("", []string{
+		stand.URL + "/product/form1/cpu",
+		stand.URL + "/product/link1/gpu",
+		stand.URL + "/product/Yg3mP6/case",
+	})
+	if err != nil {
+		t.Fatalf("ProcessPartLinks: %v", err)
+	}
+
+	if want := site.BuildPrefixURL("") + "list/Ses000"; list.URL != want {
+		t.Errorf("expected list %s, got %s", want, list.URL)
+	}
+	want := []standInPart{{Product: "form1", Quantity: 1}, {Product: "link1", Quantity: 1}, {Product: "Yg3mP6", Quantity: 1}}
+	if parts := stand.lists["0"]; !slices.Equal(parts, want) {
+		t.Errorf("unexpected parts in the list %v", parts)
+	}
+}
+
+func TestHTTPBuilderAppliesItems(t *testing.T) {
+	stand := newStandIn(t)
+	builder := NewHTTPBuilder(stand.site(t))
+
+	_, err := builder.ProcessListItems("", []models.ListItem{
+		{URL: stand.URL + "/product/form1/memory", Quantity: 2},
+		{URL: stand.URL + "/product/link1/fan", Override: &models.PriceOverride{Vendor: "Local Store", Price: 9.5}},
+		{Custom: &models.CustomPart{Type: "Case", Name: "Wooden Case", URL: "https://example.com/case"}, Override: &models.PriceOverride{Price: 89}},
+	})
+	if err != nil {
+		t.Fatalf("ProcessListItems: %v", err)
+	}
+
+	want := []standInPart{
+		{Product: "form1", Quantity: 2},
+		{Product: "link1", Quantity: 1, Price: "9.50", Vendor: "Local Store"},
+		{Custom: "Case: Wooden Case https://example.com/case", Quantity: 1, Price: "89.00"},
+	}
+	if parts := stand.lists["0"]; !slices.Equal(parts, want) {
+		t.Errorf("unexpected parts in the list %+v", parts)
+	}
+}
+
+func TestInvalidItems(t *testing.T) {
+	stand := newStandIn(t)
+	fallback := &fakeBuilder{}
+	builder := &FallbackBuilder{Primary: NewHTTPBuilder(stand.site(t)), Fallback: fallback}
+	part := stand.URL + "/product/form1/memory"
+
+	for name, items := range map[string][]models.ListItem{
+		"negative quantity": {{URL: part, Quantity: -1}},
+		"negative price":    {{URL: part, Override: &models.PriceOverride{Price: -1}}},
+		"duplicate part":    {{URL: part}, {URL: part}},
+		"nameless custom":   {{Custom: &models.CustomPart{Type: "Case"}}},
+		"custom product":    {{URL: part, Custom: &models.CustomPart{Type: "Case", Name: "Case"}}},
+		"not a product":     {{URL: stand.URL + "/list/abcd"}},
+	} {
+		if _, err := builder.ProcessListItems("", items); !errors.Is(err, ErrInvalidItem) {
+			t.Errorf("%s: expected an invalid item, got %v", name, err)
+		}
+	}
+	if fallback.calls != 0 {
+		t.Errorf("expected invalid items not to fall back, got %d calls", fallback.calls)
+	}
+}
+
+type fakeBuilder struct {
+	calls int
+}
+
+func (b *fakeBuilder) ProcessListItems(region string, items []models.ListItem) (*models.SearchPart, error) {
+	b.calls++
+	return &models.SearchPart{URL: "fallback"}, nil
+}
+
+func TestFallbackBuilder(t *testing.T) {
+	stand := newStandIn(t)
+	fallback := &fakeBuilder{}
+	builder := &FallbackBuilder{Primary: NewHTTPBuilder(stand.site(t)), Fallback: fallback}
+
+	list, err := builder.ProcessPartLinks("", []string{stand.URL + "/product/script/case"})
+	if err != nil || list.URL != "fallback" || fallback.calls != 1 {
+		t.Errorf("expected the fallback to build the list, got %v, %v", list, err)
+	}
+
+	_, err = builder.ProcessPartLinks("Not a region", nil)
+	if !errors.Is(err, ErrInvalidRegion) || fallback.calls != 1 {
+		t.Errorf("expected an invalid region without fallback, got %v", err)
+	}
+	if _, err := NewHTTPBuilder(stand.site(t)).ProcessPartLinks("", []string{stand.URL + "/product/script/case"}); !errors.Is(err, ErrNoAddButton) {
+		t.Errorf("expected a missing button, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package pcpartpicker_automation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"strconv"
+	"strings"
+)
+
+const addCustomPart = "add custom part"
+
+var (
+	// ErrInvalidItem is returned when an item of a list to build is not valid.
+	ErrInvalidItem = errors.New("invalid list item")
+	// ErrNoListForm is returned when the part list page has no form to apply an item with.
+	ErrNoListForm = errors.New("no part list form for the item")
+)
+
+// fieldAliases holds the names of the form fields each setting of an item may be submitted as.
+var fieldAliases = map[string][]string{
+	"quantity": {"quantity", "qty"},
+	"price":    {"price"},
+	"vendor":   {"vendor", "merchant"},
+	"category": {"category", "component"},
+	"name":     {"name"},
+	"url":      {"url", "link"},
+}
+
+// formField is a setting of an item and the value to submit for it.
+type formField struct {
+	key   string
+	value string
+}
+
+// listEdit is a form of the part list page submitted to apply an item: a form
+// of the row of productID, or the custom part form when productID is empty.
+type listEdit struct {
+	productID string
+	fields    []formField
+}
+
+// linkItems returns an item with a single part for every link.
+func linkItems(partLinks []string) []models.ListItem {
+	items := make([]models.ListItem, len(partLinks))
+	for i, link := range partLinks {
+		items[i] = models.ListItem{URL: link, Quantity: 1}
+	}
+	return items
+}
+
+// planItems validates items and returns the product links to add to a list of
+// region followed by the edits applying quantities, price overrides and custom
+// parts. Products of other regions are added from region, as a session only
+// holds a part list per region.
+func planItems(site *utils.Site, region string, items []models.ListItem) ([]string, []listEdit, error) {
+	var links []string
+	var edits []listEdit
+	seen := map[string]bool{}
+
+	for i, item := range items {
+		invalid := func(reason string) error {
+			return fmt.Errorf("%w: item %d: %s", ErrInvalidItem, i, reason)
+		}
+		if item.Quantity < 0 {
+			return nil, nil, invalid("negative quantity")
+		}
+		if item.Override != nil && item.Override.Price < 0 {
+			return nil, nil, invalid("negative price")
+		}
+
+		var fields []formField
+		if item.Quantity > 1 {
+			fields = append(fields, formField{"quantity", strconv.Itoa(item.Quantity)})
+		}
+
+		if item.URL == "" {
+			if item.Custom == nil || item.Custom.Type == "" || item.Custom.Name == "" {
+				return nil, nil, invalid("custom parts need a type and a name")
+			}
+			fields = append(fields, formField{"category", item.Custom.Type}, formField{"name", item.Custom.Name})
+			if item.Custom.URL != "" {
+				fields = append(fields, formField{"url", item.Custom.URL})
+			}
+			fields = append(fields, overrideFields(item.Override)...)
+			edits = append(edits, listEdit{fields: fields})
+			continue
+		}
+
+		id := site.ExtractProductID(item.URL)
+		switch {
+		case item.Custom != nil:
+			return nil, nil, invalid("custom parts can't have a product URL")
+		case id == "":
+			return nil, nil, invalid("invalid part URL")
+		case seen[id]:
+			return nil, nil, invalid("duplicate part, use its quantity instead")
+		}
+		seen[id] = true
+		links = append(links, site.RegionalProductURL(item.URL, region))
+
+		// Quantities and prices are set with different forms of the row
+		if len(fields) > 0 {
+			edits = append(edits, listEdit{productID: id, fields: fields})
+		}
+		if item.Override != nil {
+			edits = append(edits, listEdit{productID: id, fields: overrideFields(item.Override)})
+		}
+	}
+	return links, edits, nil
+}
+
+func overrideFields(override *models.PriceOverride) []formField {
+	if override == nil {
+		return nil
+	}
+	fields := []formField{{"price", strconv.FormatFloat(override.Price, 'f', 2, 64)}}
+	if override.Vendor != "" {
+		fields = append(fields, formField{"vendor", override.Vendor})
+	}
+	return fields
+}
+
+// fieldSelector returns the CSS selector of the form fields a setting may be submitted as.
+func fieldSelector(key string) string {
+	selectors := make([]string, len(fieldAliases[key]))
+	for i, alias := range fieldAliases[key] {
+		selectors[i] = fmt.Sprintf(`[name*="%s" i]:not([type=hidden])`, alias)
+	}
+	return strings.Join(selectors, ", ")
+}
+
+// productLinkSelector returns the CSS selector of the links to a product.
+func productLinkSelector(productID string) string {
+	return fmt.Sprintf(`a[href*="/product/%s/"]`, productID)
+}
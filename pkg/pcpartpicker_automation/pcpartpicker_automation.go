@@ -1,39 +1,132 @@
 package pcpartpicker_automation
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Aquilabot/KreaPC-API/internal/models"
 	"github.com/Aquilabot/KreaPC-API/internal/utils"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/playwright-community/playwright-go"
+	"net/url"
 )
 
 const (
 	errorInvalidRegion          = "invalid region"
 	errorInitializingPlaywright = "could not start Playwright: %v"
 	errorLaunchingBrowser       = "could not launch browser: %v"
+	errorCreatingContext        = "could not create browser context: %v"
 	errorCreatingPage           = "could not create page: %v"
 	errorNavigatingURL          = "could not navigate to %s: %v"
 	logInitPlaywright           = "Initializing Playwright"
 	logErrorCookies             = "Error handling cookies, but we continue: %v"
 	logCleanupPlaywright        = "Cleaning up Playwright"
+	logErrorCloseContext        = "Could not close browser context: %v"
 	logErrorCloseBrowser        = "Could not close browser: %v"
 	logErrorStopPlaywright      = "Could not stop Playwright: %v"
 )
 
-func ProcessPartLinks(region string, partLinks []string) (*models.SearchPart, error) {
-	prefixURL := utils.BuildPrefixURL(region)
-	if !utils.MatchPCPPURL(prefixURL) {
-		return nil, errors.New(errorInvalidRegion)
+// ErrInvalidRegion is returned when building a list on a region the site doesn't support.
+var ErrInvalidRegion = errors.New(errorInvalidRegion)
+
+// ListBuilder builds a new part list out of list items.
+type ListBuilder interface {
+	ProcessListItems(region string, items []models.ListItem) (*models.SearchPart, error)
+}
+
+// FallbackBuilder builds lists with Primary, and with Fallback when Primary
+// fails. When both fail, the error holds both failures.
+type FallbackBuilder struct {
+	Primary  ListBuilder
+	Fallback ListBuilder
+}
+
+// ProcessPartLinks adds every part link to a new part list and returns the URL of the resulting list.
+func (b *FallbackBuilder) ProcessPartLinks(region string, partLinks []string) (*models.SearchPart, error) {
+	return b.ProcessListItems(region, linkItems(partLinks))
+}
+
+// ProcessListItems adds every item to a new part list, setting its quantity and
+// price, and returns the URL of the resulting list.
+func (b *FallbackBuilder) ProcessListItems(region string, items []models.ListItem) (*models.SearchPart, error) {
+	list, err := b.Primary.ProcessListItems(region, items)
+	if err == nil || errors.Is(err, ErrInvalidRegion) || errors.Is(err, ErrInvalidItem) {
+		return list, err
+	}
+	log.Warn("Could not build the part list, falling back: ", err)
+	list, fallbackErr := b.Fallback.ProcessListItems(region, items)
+	if fallbackErr != nil {
+		return nil, errors.Join(err, fallbackErr)
 	}
-	pw, browser, page, err := initializePlaywright()
+	return list, nil
+}
+
+// PlaywrightBuilder builds part lists by driving a headless browser on Site.
+// Lists are built in contexts borrowed from Browsers, or in a browser started
+// for the list when Browsers is nil.
+type PlaywrightBuilder struct {
+	Site     *utils.Site
+	Browsers *BrowserPool
+}
+
+// NewPlaywrightBuilder returns a PlaywrightBuilder for site starting a browser per list.
+func NewPlaywrightBuilder(site *utils.Site) *PlaywrightBuilder {
+	return &PlaywrightBuilder{Site: site}
+}
+
+// ProcessPartLinks adds every part link to a new part list on the given site
+// and returns the URL of the resulting list.
+func ProcessPartLinks(site *utils.Site, region string, partLinks []string) (*models.SearchPart, error) {
+	return NewPlaywrightBuilder(site).ProcessPartLinks(region, partLinks)
+}
+
+// ProcessPartLinks adds every part link to a new part list and returns the URL of the resulting list.
+func (b *PlaywrightBuilder) ProcessPartLinks(region string, partLinks []string) (*models.SearchPart, error) {
+	return b.ProcessListItems(region, linkItems(partLinks))
+}
+
+// ProcessListItems adds every item to a new part list, setting its quantity and
+// price, and returns the URL of the resulting list.
+func (b *PlaywrightBuilder) ProcessListItems(region string, items []models.ListItem) (*models.SearchPart, error) {
+	browsers := b.Browsers
+	if browsers == nil {
+		browsers = NewBrowserPool(1)
+		browsers.Warm = 0
+		if err := browsers.Start(); err != nil {
+			return nil, err
+		}
+		defer browsers.Stop()
+	}
+
+	bc, err := browsers.Borrow(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	list, err := b.BuildList(bc, region, items)
+	browsers.Return(bc, err)
+	return list, err
+}
+
+// BuildList adds every item to a new part list in a borrowed browser context
+// and returns the URL of the resulting list.
+func (b *PlaywrightBuilder) BuildList(bc *BrowserContext, region string, items []models.ListItem) (*models.SearchPart, error) {
+	prefixURL := b.Site.BuildPrefixURL(region)
+	if !b.Site.MatchPCPPURL(prefixURL) {
+		return nil, ErrInvalidRegion
+	}
+	links, edits, err := planItems(b.Site, region, items)
 	if err != nil {
 		return nil, err
 	}
-	defer cleanup(pw, browser)
+
+	page, err := bc.NewPage()
+	if err != nil {
+		return nil, fmt.Errorf(errorCreatingPage, err)
+	}
+	defer page.Close()
 
 	if err := navigateTo(page, prefixURL); err != nil {
+		bc.markProxyFailure()
 		return nil, err
 	}
 
@@ -41,28 +134,34 @@ func ProcessPartLinks(region string, partLinks []string) (*models.SearchPart, er
 		log.Warnf(logErrorCookies, err)
 	}
 
-	if err := addPartsList(prefixURL, page, partLinks); err != nil {
+	if err := addPartsList(prefixURL, page, links); err != nil {
 		return nil, err
 	}
 
+	if page.URL() != prefixURL+"list/" {
+		if err := navigateTo(page, prefixURL+"list/"); err != nil {
+			return nil, err
+		}
+	}
+	for _, edit := range edits {
+		if err := applyEdit(prefixURL, page, edit); err != nil {
+			return nil, err
+		}
+	}
+
 	return handleTextbox(page)
 }
 
-func initializePlaywright() (*playwright.Playwright, playwright.Browser, playwright.Page, error) {
-	log.Info(logInitPlaywright)
-	pw, err := playwright.Run()
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf(errorInitializingPlaywright, err)
+// launchProxy returns the browser proxy settings of proxyURL, credentials included.
+func launchProxy(proxyURL *url.URL) *playwright.Proxy {
+	settings := &playwright.Proxy{Server: proxyURL.Scheme + "://" + proxyURL.Host}
+	if proxyURL.User != nil {
+		username := proxyURL.User.Username()
+		password, _ := proxyURL.User.Password()
+		settings.Username = &username
+		settings.Password = &password
 	}
-	browser, err := pw.Chromium.Launch()
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf(errorLaunchingBrowser, err)
-	}
-	page, err := browser.NewPage()
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf(errorCreatingPage, err)
-	}
-	return pw, browser, page, nil
+	return settings
 }
 
 func navigateTo(page playwright.Page, url string) error {
@@ -98,6 +197,49 @@ func addPart(prefixURL string, page playwright.Page, url string) error {
 	return nil
 }
 
+// applyEdit fills and submits the form of the part list page applying an edit.
+func applyEdit(prefixURL string, page playwright.Page, edit listEdit) error {
+	var forms playwright.Locator
+	if edit.productID != "" {
+		row := page.Locator("tr.tr__product").Filter(playwright.LocatorFilterOptions{Has: page.Locator(productLinkSelector(edit.productID))})
+		forms = row.Locator("form")
+	} else {
+		button := page.GetByRole("button", playwright.PageGetByRoleOptions{Name: "Add Custom Part"})
+		forms = page.Locator("form").Filter(playwright.LocatorFilterOptions{Has: button})
+	}
+	for _, field := range edit.fields {
+		forms = forms.Filter(playwright.LocatorFilterOptions{Has: page.Locator(fieldSelector(field.key))})
+	}
+	if count, err := forms.Count(); err != nil || count == 0 {
+		return fmt.Errorf("%w: %v", ErrNoListForm, edit.fields)
+	}
+
+	form := forms.First()
+	for _, field := range edit.fields {
+		input := form.Locator(fieldSelector(field.key)).First()
+		tag, err := input.Evaluate("element => element.tagName", nil)
+		if err != nil {
+			return err
+		}
+		if tag == "SELECT" {
+			_, err = input.SelectOption(playwright.SelectOptionValues{Values: &[]string{field.value}})
+		} else {
+			err = input.Fill(field.value)
+		}
+		if err != nil {
+			return fmt.Errorf("could not fill the %s of the part list: %v", field.key, err)
+		}
+	}
+
+	_, err := page.ExpectNavigation(func() error {
+		return form.Locator("button, input[type=submit]").First().Click()
+	}, playwright.PageExpectNavigationOptions{URL: prefixURL + "list/"})
+	if err != nil {
+		return fmt.Errorf("error waiting for the part list to update: %v", err)
+	}
+	return nil
+}
+
 func addPartsList(prefixURL string, page playwright.Page, links []string) error {
 	for _, link := range links {
 		if err := addPart(prefixURL, page, link); err != nil {
@@ -126,13 +268,3 @@ func handleTextbox(page playwright.Page) (*models.SearchPart, error) {
 		URL: value,
 	}, nil
 }
-
-func cleanup(pw *playwright.Playwright, browser playwright.Browser) {
-	log.Info(logCleanupPlaywright)
-	if err := browser.Close(); err != nil {
-		log.Fatalf(logErrorCloseBrowser, err)
-	}
-	if err := pw.Stop(); err != nil {
-		log.Fatalf(logErrorStopPlaywright, err)
-	}
-}
@@ -0,0 +1,578 @@
+package pcpartpicker_automation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/pkg/proxy"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/playwright-community/playwright-go"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrPoolStopped is returned when borrowing from a browser pool that isn't running.
+	ErrPoolStopped = errors.New("browser pool is not running")
+	// ErrInvalidPool is returned when starting a browser pool with invalid settings.
+	ErrInvalidPool = errors.New("invalid browser pool")
+)
+
+// BrowserContext is an isolated browser session lent by a BrowserPool. When
+// the pool has proxies, every page of the context leaves through Proxy.
+type BrowserContext struct {
+	playwright.BrowserContext
+	Proxy *url.URL
+
+	browser   *pooledBrowser
+	proxies   *proxy.Pool
+	idleSince time.Time
+}
+
+// markProxyFailure counts a failed navigation against the proxy of the context.
+func (bc *BrowserContext) markProxyFailure() {
+	if bc.Proxy != nil && bc.proxies != nil {
+		bc.proxies.MarkFailure(bc.Proxy.String())
+	}
+}
+
+// browserType launches the browsers of a pool. It is Playwright's Chromium
+// outside of tests.
+type browserType interface {
+	Launch(options ...playwright.BrowserTypeLaunchOptions) (playwright.Browser, error)
+}
+
+type pooledBrowser struct {
+	browser playwright.Browser
+	open    int
+	uses    int
+}
+
+// BrowserPoolStats describes the browsers and contexts of a pool.
+type BrowserPoolStats struct {
+	Browsers int
+	Open     int
+	Idle     int
+	Borrowed int
+	Launched uint64
+	Recycled uint64
+}
+
+// BrowserPool keeps Chromium browsers running between list builds and lends
+// isolated contexts out of them. At most Size contexts are open at once, and
+// at most ContextsPerBrowser per browser. A browser is retired once it has
+// lent MaxUses contexts, idle contexts beyond Warm are closed after
+// IdleTimeout, and every CheckInterval the idle contexts are checked and the
+// pool is topped up to Warm contexts. Borrowers wait at most MaxWait for a
+// context. A Lazy pool isn't started until the first Borrow, so Playwright
+// only runs when a list needs a browser.
+type BrowserPool struct {
+	Size               int
+	ContextsPerBrowser int
+	Warm               int
+	IdleTimeout        time.Duration
+	MaxUses            int
+	CheckInterval      time.Duration
+	MaxWait            time.Duration
+	Proxies            *proxy.Pool
+	Launch             playwright.BrowserTypeLaunchOptions
+	Lazy               bool
+
+	starting sync.Mutex
+	mu       sync.Mutex
+	stopped  bool
+	pw       *playwright.Playwright
+	chromium browserType
+	browsers []*pooledBrowser
+	idle     []*BrowserContext
+	slots    chan struct{}
+	borrowed int
+	launched uint64
+	recycled uint64
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewBrowserPool returns a pool lending at most size contexts at once.
+func NewBrowserPool(size int) *BrowserPool {
+	return &BrowserPool{
+		Size:               size,
+		ContextsPerBrowser: 4,
+		Warm:               1,
+		IdleTimeout:        5 * time.Minute,
+		MaxUses:            50,
+		CheckInterval:      time.Minute,
+		MaxWait:            30 * time.Second,
+	}
+}
+
+// Start runs Playwright, warms up the pool and starts its health checks.
+func (p *BrowserPool) Start() error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	log.Info(logInitPlaywright)
+	pw, err := playwright.Run()
+	if err != nil {
+		return fmt.Errorf(errorInitializingPlaywright, err)
+	}
+	return p.start(pw, pw.Chromium)
+}
+
+// Validate checks the settings of the pool: Size, ContextsPerBrowser and
+// CheckInterval must be positive, the other limits can't be negative.
+func (p *BrowserPool) Validate() error {
+	switch {
+	case p.Size < 1:
+		return fmt.Errorf("%w: size %d", ErrInvalidPool, p.Size)
+	case p.ContextsPerBrowser < 1:
+		return fmt.Errorf("%w: %d contexts per browser", ErrInvalidPool, p.ContextsPerBrowser)
+	case p.Warm < 0:
+		return fmt.Errorf("%w: %d warm contexts", ErrInvalidPool, p.Warm)
+	case p.MaxUses < 0:
+		return fmt.Errorf("%w: %d uses per browser", ErrInvalidPool, p.MaxUses)
+	case p.CheckInterval <= 0:
+		return fmt.Errorf("%w: check interval %s", ErrInvalidPool, p.CheckInterval)
+	case p.IdleTimeout < 0 || p.MaxWait < 0:
+		return fmt.Errorf("%w: negative timeout", ErrInvalidPool)
+	}
+	return nil
+}
+
+// start warms up the pool with browsers launched by chromium and starts its health checks.
+func (p *BrowserPool) start(pw *playwright.Playwright, chromium browserType) error {
+	p.mu.Lock()
+	p.pw, p.chromium = pw, chromium
+	p.stopped = false
+	p.slots = make(chan struct{}, p.Size)
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	p.mu.Unlock()
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.check()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+
+	if err := p.warmUp(); err != nil {
+		p.shutdown()
+		return err
+	}
+	return nil
+}
+
+// startLazily starts a Lazy pool that isn't running yet and wasn't stopped.
+func (p *BrowserPool) startLazily() error {
+	if !p.Lazy {
+		return ErrPoolStopped
+	}
+	p.starting.Lock()
+	defer p.starting.Unlock()
+
+	p.mu.Lock()
+	running, stopped := p.chromium != nil, p.stopped
+	p.mu.Unlock()
+	if stopped {
+		return ErrPoolStopped
+	}
+	if running {
+		return nil
+	}
+	return p.Start()
+}
+
+// Stop closes every browser of the pool and stops Playwright. Contexts still
+// borrowed fail and are discarded when returned.
+func (p *BrowserPool) Stop() {
+	p.mu.Lock()
+	p.stopped = true
+	p.mu.Unlock()
+	p.shutdown()
+}
+
+func (p *BrowserPool) shutdown() {
+	p.mu.Lock()
+	if p.chromium == nil {
+		p.mu.Unlock()
+		return
+	}
+	pw, stop, done := p.pw, p.stop, p.done
+	browsers := p.browsers
+	p.pw, p.chromium, p.browsers, p.idle = nil, nil, nil, nil
+	p.mu.Unlock()
+
+	close(stop)
+	<-done
+
+	log.Info(logCleanupPlaywright)
+	for _, b := range browsers {
+		if err := b.browser.Close(); err != nil {
+			log.Warnf(logErrorCloseBrowser, err)
+		}
+	}
+	if pw == nil {
+		return
+	}
+	if err := pw.Stop(); err != nil {
+		log.Warnf(logErrorStopPlaywright, err)
+	}
+}
+
+// Borrow lends a context of the pool, waiting up to MaxWait for one to be
+// returned when Size contexts are already borrowed. Every borrowed context
+// must be given back with Return.
+func (p *BrowserPool) Borrow(ctx context.Context) (*BrowserContext, error) {
+	p.mu.Lock()
+	running := p.chromium != nil
+	p.mu.Unlock()
+	if !running {
+		if err := p.startLazily(); err != nil {
+			return nil, err
+		}
+	}
+	p.mu.Lock()
+	slots := p.slots
+	p.mu.Unlock()
+
+	if p.MaxWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.MaxWait)
+		defer cancel()
+	}
+
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	bc, err := p.take()
+	if err != nil {
+		<-slots
+		return nil, err
+	}
+	p.mu.Lock()
+	p.borrowed++
+	p.mu.Unlock()
+	return bc, nil
+}
+
+// Return gives a borrowed context back to the pool. Its pages and cookies are
+// cleared so the next borrower starts a new PCPartPicker session. Contexts
+// returned with an error, or whose browser or proxy went bad, are closed.
+func (p *BrowserPool) Return(bc *BrowserContext, err error) {
+	p.mu.Lock()
+	slots := p.slots
+	p.borrowed--
+	p.mu.Unlock()
+	defer func() { <-slots }()
+
+	if err == nil {
+		err = reset(bc)
+	}
+
+	p.mu.Lock()
+	if err != nil || p.chromium == nil || !p.usable(bc) {
+		p.mu.Unlock()
+		p.discard(bc)
+		return
+	}
+	bc.idleSince = time.Now()
+	p.idle = append(p.idle, bc)
+	p.mu.Unlock()
+}
+
+// Stats returns the state of the pool.
+func (p *BrowserPool) Stats() BrowserPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return BrowserPoolStats{
+		Browsers: len(p.browsers),
+		Open:     p.open(),
+		Idle:     len(p.idle),
+		Borrowed: p.borrowed,
+		Launched: p.launched,
+		Recycled: p.recycled,
+	}
+}
+
+// open returns the number of contexts open on the browsers of the pool.
+func (p *BrowserPool) open() int {
+	open := 0
+	for _, b := range p.browsers {
+		open += b.open
+	}
+	return open
+}
+
+// take returns the most recently used idle context, or a new one when none is usable.
+func (p *BrowserPool) take() (*BrowserContext, error) {
+	p.mu.Lock()
+	for len(p.idle) > 0 {
+		bc := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if p.usable(bc) {
+			bc.browser.uses++
+			p.mu.Unlock()
+			return bc, nil
+		}
+		p.mu.Unlock()
+		p.discard(bc)
+		p.mu.Lock()
+	}
+	p.mu.Unlock()
+
+	bc, err := p.newContext()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	bc.browser.uses++
+	p.mu.Unlock()
+	return bc, nil
+}
+
+// warmUp opens contexts until Warm of them are open. Every context is opened
+// holding a slot, so borrowers can't open contexts beyond Size meanwhile.
+func (p *BrowserPool) warmUp() error {
+	for {
+		p.mu.Lock()
+		open, running, slots := p.open(), p.chromium != nil, p.slots
+		p.mu.Unlock()
+		if !running || open >= min(p.Warm, p.Size) {
+			return nil
+		}
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			return nil
+		}
+		bc, err := p.newContext()
+		if err != nil {
+			<-slots
+			return err
+		}
+		p.mu.Lock()
+		bc.idleSince = time.Now()
+		p.idle = append(p.idle, bc)
+		p.mu.Unlock()
+		<-slots
+	}
+}
+
+// newContext opens a context on a browser with room left, launching a new browser if needed.
+func (p *BrowserPool) newContext() (*BrowserContext, error) {
+	b, err := p.reserveBrowser()
+	if err != nil {
+		return nil, err
+	}
+
+	options := playwright.BrowserNewContextOptions{}
+	var proxyURL *url.URL
+	if p.Proxies != nil {
+		if proxyURL, err = p.Proxies.Pick(); err != nil {
+			p.release(b)
+			return nil, err
+		}
+		options.Proxy = launchProxy(proxyURL)
+	}
+
+	browserContext, err := b.browser.NewContext(options)
+	if err != nil {
+		p.release(b)
+		return nil, fmt.Errorf(errorCreatingContext, err)
+	}
+	return &BrowserContext{BrowserContext: browserContext, Proxy: proxyURL, browser: b, proxies: p.Proxies}, nil
+}
+
+// reserveBrowser counts a new context against a browser with room left.
+func (p *BrowserPool) reserveBrowser() (*pooledBrowser, error) {
+	p.mu.Lock()
+	for _, b := range p.browsers {
+		if p.serving(b) && b.open < p.ContextsPerBrowser {
+			b.open++
+			p.mu.Unlock()
+			return b, nil
+		}
+	}
+	chromium := p.chromium
+	p.mu.Unlock()
+	if chromium == nil {
+		return nil, ErrPoolStopped
+	}
+
+	browser, err := chromium.Launch(p.Launch)
+	if err != nil {
+		return nil, fmt.Errorf(errorLaunchingBrowser, err)
+	}
+	b := &pooledBrowser{browser: browser, open: 1}
+
+	p.mu.Lock()
+	if p.chromium == nil {
+		p.mu.Unlock()
+		browser.Close()
+		return nil, ErrPoolStopped
+	}
+	p.browsers = append(p.browsers, b)
+	p.launched++
+	p.mu.Unlock()
+	return b, nil
+}
+
+// discard closes a context, and its browser when the browser is retired and has no context left.
+func (p *BrowserPool) discard(bc *BrowserContext) {
+	if err := bc.Close(); err != nil {
+		log.Warnf(logErrorCloseContext, err)
+	}
+	p.release(bc.browser)
+}
+
+// release forgets a context of b, closing b when it is retired and has no context left.
+func (p *BrowserPool) release(b *pooledBrowser) {
+	p.mu.Lock()
+	b.open--
+	retire := b.open == 0 && !p.serving(b)
+	if retire {
+		p.removeBrowser(b)
+	}
+	p.mu.Unlock()
+
+	if retire {
+		if err := b.browser.Close(); err != nil {
+			log.Warnf(logErrorCloseBrowser, err)
+		}
+	}
+}
+
+func (p *BrowserPool) removeBrowser(b *pooledBrowser) {
+	for i, other := range p.browsers {
+		if other == b {
+			p.browsers = append(p.browsers[:i], p.browsers[i+1:]...)
+			p.recycled++
+			return
+		}
+	}
+}
+
+// serving reports whether b can open and lend more contexts.
+func (p *BrowserPool) serving(b *pooledBrowser) bool {
+	return b.browser.IsConnected() && (p.MaxUses <= 0 || b.uses < p.MaxUses)
+}
+
+// usable reports whether bc can be lent again.
+func (p *BrowserPool) usable(bc *BrowserContext) bool {
+	if !p.serving(bc.browser) {
+		return false
+	}
+	return bc.Proxy == nil || p.Proxies == nil || p.Proxies.Healthy(bc.Proxy.String())
+}
+
+// check closes the idle contexts that expired or fail a health check, closes
+// the browsers left without contexts and tops the pool up to Warm contexts.
+func (p *BrowserPool) check() {
+	p.mu.Lock()
+	idle := slices.Clone(p.idle)
+	slots := p.slots
+	p.mu.Unlock()
+
+	for _, bc := range idle {
+		if !p.checkIdle(bc, slots) {
+			break
+		}
+	}
+
+	p.mu.Lock()
+	var empty []*pooledBrowser
+	for _, b := range p.browsers {
+		if b.open == 0 {
+			empty = append(empty, b)
+		}
+	}
+	for _, b := range empty {
+		p.removeBrowser(b)
+	}
+	p.mu.Unlock()
+
+	for _, b := range empty {
+		if err := b.browser.Close(); err != nil {
+			log.Warnf(logErrorCloseBrowser, err)
+		}
+	}
+
+	if err := p.warmUp(); err != nil {
+		log.Warn("Could not warm up the browser pool: ", err)
+	}
+}
+
+// checkIdle closes an idle context that expired or fails a health check. The
+// context is checked holding a slot, so borrowers can't open contexts beyond
+// Size in its place, and checkIdle reports false when every slot is taken.
+func (p *BrowserPool) checkIdle(bc *BrowserContext, slots chan struct{}) bool {
+	select {
+	case slots <- struct{}{}:
+	default:
+		return false
+	}
+	defer func() { <-slots }()
+
+	p.mu.Lock()
+	i := slices.Index(p.idle, bc)
+	if i < 0 {
+		// Lent since the check started.
+		p.mu.Unlock()
+		return true
+	}
+	p.idle = slices.Delete(p.idle, i, i+1)
+	expired := time.Since(bc.idleSince) > p.IdleTimeout && p.open() > p.Warm
+	usable := p.usable(bc)
+	p.mu.Unlock()
+
+	if expired || !usable || probe(bc) != nil {
+		p.discard(bc)
+		return true
+	}
+
+	p.mu.Lock()
+	if p.chromium == nil {
+		p.mu.Unlock()
+		p.discard(bc)
+		return true
+	}
+	p.idle = slices.Insert(p.idle, min(i, len(p.idle)), bc)
+	p.mu.Unlock()
+	return true
+}
+
+// probe checks that a context can still open and script a page.
+func probe(bc *BrowserContext) error {
+	page, err := bc.NewPage()
+	if err != nil {
+		return err
+	}
+	defer page.Close()
+	_, err = page.Evaluate("1 + 1")
+	return err
+}
+
+// reset closes the pages of a returned context and clears its cookies.
+func reset(bc *BrowserContext) error {
+	for _, page := range bc.Pages() {
+		if err := page.Close(); err != nil {
+			return err
+		}
+	}
+	return bc.ClearCookies()
+}
@@ -0,0 +1,272 @@
+package pcpartpicker_automation
+
+import (
+	"context"
+	"errors"
+	"github.com/playwright-community/playwright-go"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeChromium launches fake browsers whose contexts never load a page.
+type fakeChromium struct {
+	mu       sync.Mutex
+	browsers []*fakeBrowser
+}
+
+func (c *fakeChromium) Launch(...playwright.BrowserTypeLaunchOptions) (playwright.Browser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := &fakeBrowser{}
+	b.connected.Store(true)
+	c.browsers = append(c.browsers, b)
+	return b, nil
+}
+
+type fakeBrowser struct {
+	playwright.Browser
+	connected atomic.Bool
+}
+
+func (b *fakeBrowser) IsConnected() bool {
+	return b.connected.Load()
+}
+
+func (b *fakeBrowser) Close(...playwright.BrowserCloseOptions) error {
+	b.connected.Store(false)
+	return nil
+}
+
+func (b *fakeBrowser) NewContext(...playwright.BrowserNewContextOptions) (playwright.BrowserContext, error) {
+	return &fakeContext{}, nil
+}
+
+// fakeContext fails its health check when broken, and blocks it until
+// unblocked when blocked is set.
+type fakeContext struct {
+	playwright.BrowserContext
+	closed  atomic.Bool
+	broken  atomic.Bool
+	probing chan struct{}
+	blocked chan struct{}
+}
+
+func (c *fakeContext) Close(...playwright.BrowserContextCloseOptions) error {
+	c.closed.Store(true)
+	return nil
+}
+
+func (c *fakeContext) NewPage() (playwright.Page, error) {
+	if c.blocked != nil {
+		close(c.probing)
+		<-c.blocked
+	}
+	if c.broken.Load() {
+		return nil, errors.New("target closed")
+	}
+	return fakePage{}, nil
+}
+
+func (c *fakeContext) Pages() []playwright.Page {
+	return nil
+}
+
+func (c *fakeContext) ClearCookies(...playwright.BrowserContextClearCookiesOptions) error {
+	return nil
+}
+
+type fakePage struct {
+	playwright.Page
+}
+
+func (fakePage) Evaluate(string, ...interface{}) (interface{}, error) {
+	return 2, nil
+}
+
+func (fakePage) Close(...playwright.PageCloseOptions) error {
+	return nil
+}
+
+func startFakePool(t *testing.T, pool *BrowserPool) *fakeChromium {
+	t.Helper()
+	chromium := &fakeChromium{}
+	pool.CheckInterval = time.Hour
+	if err := pool.start(nil, chromium); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(pool.Stop)
+	return chromium
+}
+
+func fakeContextOf(bc *BrowserContext) *fakeContext {
+	return bc.BrowserContext.(*fakeContext)
+}
+
+func TestLazyBrowserPoolDoesNotStartUntilBorrowed(t *testing.T) {
+	pool := NewBrowserPool(1)
+	pool.Lazy = true
+	if stats := pool.Stats(); stats.Browsers != 0 || stats.Launched != 0 {
+		t.Errorf("expected no browser before the first fallback, got %+v", stats)
+	}
+
+	pool.Stop()
+	if _, err := pool.Borrow(context.Background()); !errors.Is(err, ErrPoolStopped) {
+		t.Errorf("expected a stopped lazy pool not to start, got %v", err)
+	}
+	if _, err := NewBrowserPool(1).Borrow(context.Background()); !errors.Is(err, ErrPoolStopped) {
+		t.Errorf("expected a pool that isn't lazy not to start on Borrow, got %v", err)
+	}
+}
+
+func TestBrowserPoolWaitsAtMostMaxWait(t *testing.T) {
+	pool := NewBrowserPool(2)
+	pool.Warm = 0
+	pool.MaxWait = 20 * time.Millisecond
+	startFakePool(t, pool)
+
+	first, err := pool.Borrow(context.Background())
+	if err != nil {
+		t.Fatalf("Borrow: %v", err)
+	}
+	if _, err := pool.Borrow(context.Background()); err != nil {
+		t.Fatalf("Borrow: %v", err)
+	}
+	if _, err := pool.Borrow(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a third borrower to give up after MaxWait, got %v", err)
+	}
+
+	pool.Return(first, nil)
+	again, err := pool.Borrow(context.Background())
+	if err != nil {
+		t.Fatalf("Borrow: %v", err)
+	}
+	if again != first {
+		t.Error("expected the returned context to be lent again")
+	}
+	if stats := pool.Stats(); stats.Open != 2 || stats.Borrowed != 2 || stats.Launched != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestBrowserPoolCountsCheckedContextsAgainstSize(t *testing.T) {
+	pool := NewBrowserPool(1)
+	pool.MaxWait = time.Second
+	startFakePool(t, pool)
+
+	bc, err := pool.Borrow(context.Background())
+	if err != nil {
+		t.Fatalf("Borrow: %v", err)
+	}
+	pool.Return(bc, nil)
+
+	fake := fakeContextOf(bc)
+	fake.probing, fake.blocked = make(chan struct{}), make(chan struct{})
+	checked := make(chan struct{})
+	go func() {
+		defer close(checked)
+		pool.check()
+	}()
+	<-fake.probing
+
+	borrowed := make(chan *BrowserContext)
+	go func() {
+		bc, err := pool.Borrow(context.Background())
+		if err != nil {
+			t.Errorf("Borrow: %v", err)
+		}
+		borrowed <- bc
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if stats := pool.Stats(); stats.Open != 1 {
+		t.Errorf("expected no context to be opened while the idle one is checked, got %+v", stats)
+	}
+
+	close(fake.blocked)
+	<-checked
+	if got := <-borrowed; got != bc {
+		t.Error("expected the checked context to be lent once checked")
+	}
+	if stats := pool.Stats(); stats.Open != 1 || stats.Launched != 1 {
+		t.Errorf("expected a single open context, got %+v", stats)
+	}
+}
+
+func TestBrowserPoolRecyclesBrowsersAfterMaxUses(t *testing.T) {
+	pool := NewBrowserPool(1)
+	pool.Warm = 0
+	pool.MaxUses = 2
+	chromium := startFakePool(t, pool)
+
+	for range 3 {
+		bc, err := pool.Borrow(context.Background())
+		if err != nil {
+			t.Fatalf("Borrow: %v", err)
+		}
+		pool.Return(bc, nil)
+	}
+
+	if stats := pool.Stats(); stats.Launched != 2 || stats.Recycled != 1 || stats.Browsers != 1 {
+		t.Errorf("expected the first browser to be replaced after 2 uses, got %+v", stats)
+	}
+	if chromium.browsers[0].IsConnected() || !chromium.browsers[1].IsConnected() {
+		t.Error("expected the retired browser to be closed")
+	}
+}
+
+func TestBrowserPoolClosesExpiredAndBrokenIdleContexts(t *testing.T) {
+	pool := NewBrowserPool(3)
+	pool.IdleTimeout = time.Millisecond
+	startFakePool(t, pool)
+
+	first, err := pool.Borrow(context.Background())
+	if err != nil {
+		t.Fatalf("Borrow: %v", err)
+	}
+	second, err := pool.Borrow(context.Background())
+	if err != nil {
+		t.Fatalf("Borrow: %v", err)
+	}
+	pool.Return(first, nil)
+	pool.Return(second, nil)
+	time.Sleep(5 * time.Millisecond)
+
+	pool.check()
+	if stats := pool.Stats(); stats.Open != 1 || stats.Idle != 1 {
+		t.Errorf("expected the idle contexts beyond Warm to expire, got %+v", stats)
+	}
+	if !fakeContextOf(first).closed.Load() || fakeContextOf(second).closed.Load() {
+		t.Error("expected the oldest idle context to be closed")
+	}
+
+	fakeContextOf(second).broken.Store(true)
+	pool.check()
+	if !fakeContextOf(second).closed.Load() {
+		t.Error("expected the context failing its health check to be closed")
+	}
+	if stats := pool.Stats(); stats.Open != 1 || stats.Idle != 1 {
+		t.Errorf("expected the pool to be topped up to Warm, got %+v", stats)
+	}
+}
+
+func TestBrowserPoolRejectsInvalidSettings(t *testing.T) {
+	for name, change := range map[string]func(pool *BrowserPool){
+		"no context":             func(pool *BrowserPool) { pool.Size = 0 },
+		"no context per browser": func(pool *BrowserPool) { pool.ContextsPerBrowser = 0 },
+		"negative warm":          func(pool *BrowserPool) { pool.Warm = -1 },
+		"negative uses":          func(pool *BrowserPool) { pool.MaxUses = -1 },
+		"no check interval":      func(pool *BrowserPool) { pool.CheckInterval = 0 },
+		"negative wait":          func(pool *BrowserPool) { pool.MaxWait = -time.Second },
+		"negative idle timeout":  func(pool *BrowserPool) { pool.IdleTimeout = -time.Second },
+	} {
+		pool := NewBrowserPool(2)
+		change(pool)
+		if err := pool.Start(); !errors.Is(err, ErrInvalidPool) {
+			t.Errorf("%s: expected invalid settings, got %v", name, err)
+		}
+	}
+	if err := NewBrowserPool(1).Validate(); err != nil {
+		t.Errorf("expected the default settings to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,370 @@
+// Package proxy spreads outbound requests across a weighted pool of HTTP and
+// SOCKS5 egress proxies, ejecting the ones that fail or get blocked.
+package proxy
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2/log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoHealthyProxy is returned when every proxy of the pool is ejected.
+var ErrNoHealthyProxy = errors.New("no healthy proxy")
+
+// Entry configures a proxy of the pool. Proxies with a higher Weight get
+// proportionally more requests.
+type Entry struct {
+	URL    string
+	Weight int
+}
+
+// Status describes a proxy of the pool, its credentials redacted.
+type Status struct {
+	URL          string
+	Weight       int
+	Healthy      bool
+	Requests     uint64
+	Failures     uint64
+	Blocked      uint64
+	EjectedUntil time.Time `json:",omitempty"`
+}
+
+type member struct {
+	id           string
+	url          *url.URL
+	weight       int
+	requests     uint64
+	failures     uint64
+	blocked      uint64
+	streak       int
+	ejectedUntil time.Time
+}
+
+func (m *member) healthy(now time.Time) bool {
+	return !now.Before(m.ejectedUntil)
+}
+
+// Pool is a weighted set of egress proxies. A proxy failing MaxFailures times in
+// a row, or getting blocked once, is ejected for EjectFor or until a health check
+// of CheckURL through it succeeds.
+type Pool struct {
+	MaxFailures int
+	EjectFor    time.Duration
+	CheckURL    string
+	Client      func(proxyURL *url.URL) *http.Client
+
+	mu       sync.Mutex
+	members  []*member
+	byURL    map[string]*member
+	sessions map[string]*member
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// ParseEntries parses a comma separated list of proxy URLs, each optionally
+// followed by "|" and its weight, such as "http://10.0.0.1:3128|3,socks5://10.0.0.2:1080".
+func ParseEntries(list string) ([]Entry, error) {
+	var entries []Entry
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		entry := Entry{URL: field, Weight: 1}
+		if i := strings.LastIndex(field, "|"); i >= 0 {
+			weight, err := strconv.Atoi(field[i+1:])
+			if err != nil {
+				return nil, errors.New("invalid proxy weight in " + field)
+			}
+			entry.URL, entry.Weight = field[:i], weight
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// NewPool returns a Pool of the given proxies, ejecting a proxy for 5 minutes
+// after 3 failures in a row.
+func NewPool(entries []Entry) (*Pool, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("empty proxy pool")
+	}
+
+	pool := &Pool{
+		MaxFailures: 3,
+		EjectFor:    5 * time.Minute,
+		Client:      checkClient,
+		byURL:       map[string]*member{},
+		sessions:    map[string]*member{},
+	}
+	for _, entry := range entries {
+		proxyURL, err := url.Parse(entry.URL)
+		if err != nil {
+			return nil, err
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, errors.New("unsupported proxy scheme " + proxyURL.Scheme)
+		}
+		if proxyURL.Host == "" || entry.Weight <= 0 {
+			return nil, errors.New("invalid proxy " + proxyURL.Redacted())
+		}
+
+		// The ID of a proxy redacts its password, so it can be logged and served
+		id := proxyURL.Redacted()
+		for n := 2; pool.byURL[id] != nil; n++ {
+			id = proxyURL.Redacted() + "#" + strconv.Itoa(n)
+		}
+		m := &member{id: id, url: proxyURL, weight: entry.Weight}
+		pool.members = append(pool.members, m)
+		pool.byURL[proxyURL.String()] = m
+		pool.byURL[id] = m
+	}
+	return pool, nil
+}
+
+func checkClient(proxyURL *url.URL) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+		Timeout:   10 * time.Second,
+	}
+}
+
+// Pick returns a healthy proxy chosen at random according to the weights.
+func (p *Pool) Pick() (*url.URL, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m, err := p.pick()
+	if err != nil {
+		return nil, err
+	}
+	m.requests++
+	return m.url, nil
+}
+
+func (p *Pool) pick() (*member, error) {
+	now := time.Now()
+	total := 0
+	for _, m := range p.members {
+		if m.healthy(now) {
+			total += m.weight
+		}
+	}
+	if total == 0 {
+		return nil, ErrNoHealthyProxy
+	}
+
+	n := rand.Intn(total)
+	for _, m := range p.members {
+		if !m.healthy(now) {
+			continue
+		}
+		if n < m.weight {
+			return m, nil
+		}
+		n -= m.weight
+	}
+	return nil, ErrNoHealthyProxy
+}
+
+// Session returns the proxy bound to key, picking one the first time, so every
+// request of a flow leaves through the same egress. A session moves to another
+// proxy when its proxy gets ejected.
+func (p *Pool) Session(key string) (*url.URL, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m, ok := p.sessions[key]
+	if !ok || !m.healthy(time.Now()) {
+		var err error
+		if m, err = p.pick(); err != nil {
+			return nil, err
+		}
+		p.sessions[key] = m
+	}
+	m.requests++
+	return m.url, nil
+}
+
+// EndSession forgets the proxy bound to key.
+func (p *Pool) EndSession(key string) {
+	p.mu.Lock()
+	delete(p.sessions, key)
+	p.mu.Unlock()
+}
+
+// ID returns the identifier of a proxy of the pool, given its URL or ID. The ID
+// is the URL with its password redacted, numbered when proxies only differ by
+// their password, and can be logged and served where the URL can't. Every
+// method of the pool accepts either.
+func (p *Pool) ID(proxyURL string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if m, ok := p.byURL[proxyURL]; ok {
+		return m.id
+	}
+	if u, err := url.Parse(proxyURL); err == nil {
+		return u.Redacted()
+	}
+	return "invalid proxy"
+}
+
+// Healthy reports whether a proxy of the pool is currently in use.
+func (p *Pool) Healthy(proxyURL string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m, ok := p.byURL[proxyURL]
+	return ok && m.healthy(time.Now())
+}
+
+// MarkSuccess resets the failure streak of a proxy.
+func (p *Pool) MarkSuccess(proxyURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if m, ok := p.byURL[proxyURL]; ok {
+		m.streak = 0
+	}
+}
+
+// MarkFailure counts a failed request through a proxy, ejecting it after MaxFailures in a row.
+func (p *Pool) MarkFailure(proxyURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m, ok := p.byURL[proxyURL]
+	if !ok {
+		return
+	}
+	m.failures++
+	m.streak++
+	if m.streak >= p.MaxFailures {
+		p.eject(m, "failing")
+	}
+}
+
+// MarkBlocked ejects a proxy PCPartPicker served a challenge page through.
+func (p *Pool) MarkBlocked(proxyURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if m, ok := p.byURL[proxyURL]; ok {
+		m.blocked++
+		p.eject(m, "blocked")
+	}
+}
+
+func (p *Pool) eject(m *member, reason string) {
+	if m.healthy(time.Now()) {
+		log.Warn("Ejecting ", reason, " proxy ", m.id, " for ", p.EjectFor)
+	}
+	m.ejectedUntil = time.Now().Add(p.EjectFor)
+	m.streak = 0
+}
+
+// Stats returns the status of every proxy of the pool.
+func (p *Pool) Stats() []Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]Status, len(p.members))
+	for i, m := range p.members {
+		stats[i] = Status{
+			URL:      m.id,
+			Weight:   m.weight,
+			Healthy:  m.healthy(now),
+			Requests: m.requests,
+			Failures: m.failures,
+			Blocked:  m.blocked,
+		}
+		if !stats[i].Healthy {
+			stats[i].EjectedUntil = m.ejectedUntil
+		}
+	}
+	return stats
+}
+
+// CheckHealth requests CheckURL through every proxy, reinstating the ones that
+// answer and ejecting the ones that don't.
+func (p *Pool) CheckHealth() {
+	if p.CheckURL == "" {
+		return
+	}
+
+	p.mu.Lock()
+	members := append([]*member(nil), p.members...)
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, m := range members {
+		wg.Add(1)
+		go func(m *member) {
+			defer wg.Done()
+
+			healthy := false
+			res, err := p.Client(m.url).Get(p.CheckURL)
+			if err == nil {
+				res.Body.Close()
+				healthy = res.StatusCode < 500 && res.StatusCode != http.StatusProxyAuthRequired
+			}
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if healthy {
+				m.ejectedUntil = time.Time{}
+				m.streak = 0
+			} else {
+				m.failures++
+				p.eject(m, "unhealthy")
+			}
+		}(m)
+	}
+	wg.Wait()
+}
+
+// Start runs a health check every interval in the background until Stop is
+// called. It does nothing when interval isn't positive.
+func (p *Pool) Start(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		p.CheckHealth()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.CheckHealth()
+			}
+		}
+	}()
+}
+
+// Stop ends the background health checks and waits for the running one to finish.
+func (p *Pool) Stop() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+type contextKey struct{}
+
+// WithURL returns a context sending the requests made with it through proxyURL.
+func WithURL(ctx context.Context, proxyURL *url.URL) context.Context {
+	return context.WithValue(ctx, contextKey{}, proxyURL)
+}
+
+// URLFromContext returns the proxy set on ctx by WithURL, or nil.
+func URLFromContext(ctx context.Context) *url.URL {
+	proxyURL, _ := ctx.Value(contextKey{}).(*url.URL)
+	return proxyURL
+}
+
+// Transport sends requests through the proxy set on their context, or through a
+// proxy picked from the pool, and reports connection failures to the pool.
+type Transport struct {
+	Pool *Pool
+	Base *http.Transport
+}
+
+// NewTransport returns a Transport for pool built on a clone of http.DefaultTransport.
+func NewTransport(pool *Pool) *Transport {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = func(req *http.Request) (*url.URL, error) {
+		return URLFromContext(req.Context()), nil
+	}
+	return &Transport{Pool: pool, Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	proxyURL := URLFromContext(req.Context())
+	if proxyURL == nil {
+		var err error
+		if proxyURL, err = t.Pool.Pick(); err != nil {
+			return nil, err
+		}
+		req = req.WithContext(WithURL(req.Context(), proxyURL))
+	}
+
+	res, err := t.Base.RoundTrip(req)
+	if err != nil || res.StatusCode == http.StatusProxyAuthRequired {
+		t.Pool.MarkFailure(proxyURL.String())
+	} else {
+		t.Pool.MarkSuccess(proxyURL.String())
+	}
+	return res, err
+}
@@ -0,0 +1,140 @@
+package scraper
+
+import (
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"github.com/gocolly/colly/v2"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func buildCategoryURL(site *utils.Site, category models.Category, region string, filter models.CategoryFilter, page uint) string {
+	query := url.Values{}
+
+	if len(filter.Manufacturers) > 0 {
+		query.Set("m", strings.Join(filter.Manufacturers, ","))
+	}
+	if len(filter.Chipsets) > 0 {
+		query.Set("c", strings.Join(filter.Chipsets, ","))
+	}
+	if filter.MinPrice > 0 || filter.MaxPrice > 0 {
+		maxPrice := ""
+		if filter.MaxPrice > 0 {
+			maxPrice = strconv.FormatInt(int64(math.Round(filter.MaxPrice*100)), 10)
+		}
+		query.Set("X", strconv.FormatInt(int64(math.Round(filter.MinPrice*100)), 10)+","+maxPrice)
+	}
+	if filter.Sort != models.SortDefault {
+		query.Set("sort", string(filter.Sort))
+	}
+	for k, v := range filter.Extra {
+		query.Set(k, strings.Join(v, ","))
+	}
+	if page > 1 {
+		query.Set("page", strconv.FormatUint(uint64(page), 10))
+	}
+
+	fullURL := site.BuildPrefixURL(region) + "products/" + string(category) + "/"
+	if len(query) > 0 {
+		fullURL += "?" + query.Encode()
+	}
+	return fullURL
+}
+
+// BrowseCategory retrieves the product listing of the given category and region,
+// narrowed down and sorted by filter.
+// Pages are followed the same way as in SearchPCParts: it starts at the given page
+// and keeps going until limit results have been collected.
+// It returns a pointer to models.CategoryResults and an error.
+// If the category or the region is invalid, it returns an error.
+func (scrap *Scraper) BrowseCategory(category models.Category, region string, filter models.CategoryFilter, page uint, limit uint) (*models.CategoryResults, error) {
+	if !category.Valid() {
+		return nil, invalidInput("invalid category")
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	if !scrap.Site.MatchPCPPURL(buildCategoryURL(scrap.Site, category, region, filter, page)) {
+		return nil, invalidInput("invalid region")
+	}
+
+	paged, err := collectPages(page, limit, func(page uint) (*resultPage[models.CategoryPart], error) {
+		return scrap.categoryPage(buildCategoryURL(scrap.Site, category, region, filter, page))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CategoryResults{
+		Category: category,
+		Parts:    paged.parts,
+		Page:     paged.page,
+		Total:    paged.total,
+		HasMore:  paged.hasMore,
+	}, nil
+}
+
+// categoryPage scrapes a single page of a product listing.
+func (scrap *Scraper) categoryPage(fullURL string) (*resultPage[models.CategoryPart], error) {
+	col := scrap.newCollector()
+	result := &resultPage[models.CategoryPart]{
+		parts: []models.CategoryPart{},
+	}
+
+	col.OnHTML("#category_content .tr__product", func(row *colly.HTMLElement) {
+		var specs []models.PartSpec
+
+		row.ForEach(".td__spec", func(i int, spec *colly.HTMLElement) {
+			label := spec.ChildText(".specLabel")
+			specs = append(specs, models.PartSpec{
+				Name:   label,
+				Values: []string{strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(spec.Text), label))},
+			})
+		})
+
+		extractedPrice := strings.TrimSpace(strings.TrimSuffix(row.ChildText(".td__price"), row.ChildText(".td__price button")))
+		price := scrap.parsePrice(extractedPrice, row.Request.URL)
+
+		vendorURL := linkURL(row.Request.URL.Scheme+"://", row.Request.URL.Host, row.ChildAttr(".td__price a", "href"))
+
+		result.parts = append(result.parts, models.CategoryPart{
+			Name:  row.ChildText(".td__nameWrapper p"),
+			Image: linkURL("https:", row.ChildAttr(".td__imageWrapper img", "src")),
+			URL:   linkURL(row.Request.URL.Scheme+"://", row.Request.URL.Host, row.ChildAttr(".td__name a", "href")),
+			Vendor: models.Vendor{
+				URL:  vendorURL,
+				Name: scrap.Site.ExtractVendorName(vendorURL),
+				Price: models.Price{
+					Total:        price.Float(),
+					TotalString:  extractedPrice,
+					Currency:     price.Symbol,
+					CurrencyCode: price.Currency,
+					Minor:        models.MinorPrice{Total: price.Amount},
+				},
+				InStock: len(extractedPrice) > 0,
+			},
+			Specs: specs,
+		})
+	})
+
+	col.OnHTML(".products__count", func(count *colly.HTMLElement) {
+		result.total = utils.ExtractResultCount(count.Text)
+	})
+
+	col.OnHTML(".pagination li", func(pageLink *colly.HTMLElement) {
+		if pageNumber, err := strconv.Atoi(strings.TrimSpace(pageLink.Text)); err == nil && uint(pageNumber) > result.lastPage {
+			result.lastPage = uint(pageNumber)
+		}
+	})
+
+	err := scrap.visit(col, fullURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
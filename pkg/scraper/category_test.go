@@ -0,0 +1,103 @@
+package scraper
+
+import (
+	"errors"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestBuildCategoryURL(t *testing.T) {
+	site := utils.MustNewSite("https", "pcpartpicker.com", nil)
+
+	tests := []struct {
+		name   string
+		filter models.CategoryFilter
+		page   uint
+		want   url.Values
+	}{
+		{"no filter", models.CategoryFilter{}, 1, url.Values{}},
+		{"price range", models.CategoryFilter{MinPrice: 0.29, MaxPrice: 19.99}, 1, url.Values{"X": {"29,1999"}}},
+		{"minimum price", models.CategoryFilter{MinPrice: 100}, 1, url.Values{"X": {"10000,"}}},
+		{"maximum price", models.CategoryFilter{MaxPrice: 1.15}, 1, url.Values{"X": {"0,115"}}},
+		{"every filter", models.CategoryFilter{
+			Manufacturers: []string{"1", "2"},
+			Chipsets:      []string{"67"},
+			Sort:          models.SortPriceDesc,
+			Extra:         map[string][]string{"t": {"2", "3"}},
+		}, 3, url.Values{"m": {"1,2"}, "c": {"67"}, "sort": {"-price"}, "t": {"2,3"}, "page": {"3"}}},
+	}
+
+	for _, test := range tests {
+		parsed, err := url.Parse(buildCategoryURL(site, models.CategoryCPU, "uk", test.filter, test.page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Host != "uk.pcpartpicker.com" || parsed.Path != "/products/cpu/" {
+			t.Errorf("%s: unexpected URL %s", test.name, parsed)
+		}
+		if query := parsed.Query(); query.Encode() != test.want.Encode() {
+			t.Errorf("%s: expected the query %q, got %q", test.name, test.want.Encode(), query.Encode())
+		}
+	}
+}
+
+func TestBrowseCategorySendsTheFilter(t *testing.T) {
+	var query string
+	scrap := newFixtureScraper(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		http.ServeFile(w, r, "testdata/category.html")
+	}))
+
+	filter := models.CategoryFilter{Manufacturers: []string{"3"}, MaxPrice: 499.99, Sort: models.SortPrice}
+	if _, err := scrap.BrowseCategory(models.CategoryCPU, "us", filter, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if query != "X=0%2C49999&m=3&sort=price" {
+		t.Errorf("unexpected query %q", query)
+	}
+}
+
+func TestCollectPages(t *testing.T) {
+	pages := map[uint][]int{1: {1, 2}, 2: {3, 4}, 3: {5}}
+	var fetched []uint
+	fetch := func(page uint) (*resultPage[int], error) {
+		fetched = append(fetched, page)
+		if page == 9 {
+			return nil, errors.New("failed")
+		}
+		return &resultPage[int]{parts: pages[page], total: 5, lastPage: 3}, nil
+	}
+
+	tests := []struct {
+		page    uint
+		limit   uint
+		parts   []int
+		fetched []uint
+		last    uint
+		hasMore bool
+	}{
+		{0, 0, []int{1, 2}, []uint{1}, 1, true},
+		{2, 0, []int{3, 4}, []uint{2}, 2, true},
+		{1, 3, []int{1, 2, 3}, []uint{1, 2}, 2, true},
+		{1, 4, []int{1, 2, 3, 4}, []uint{1, 2}, 2, true},
+		{2, 10, []int{3, 4, 5}, []uint{2, 3}, 3, false},
+	}
+	for _, test := range tests {
+		fetched = nil
+		results, err := collectPages(test.page, test.limit, fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(results.parts, test.parts) || !slices.Equal(fetched, test.fetched) || results.page != test.last || results.hasMore != test.hasMore || results.total != 5 {
+			t.Errorf("page %d limit %d: unexpected results %+v after fetching %v", test.page, test.limit, results, fetched)
+		}
+	}
+
+	if _, err := collectPages(9, 0, fetch); err == nil {
+		t.Error("expected the error of the page")
+	}
+}
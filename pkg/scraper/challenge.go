@@ -0,0 +1,169 @@
+package scraper
+
+import (
+	"bytes"
+	"github.com/gocolly/colly/v2"
+	"strconv"
+	"sync"
+)
+
+// challengeMarkers are snippets of the challenge and interstitial pages served
+// instead of content, along with the reason reported for them. Weak markers,
+// such as captcha widgets that may also sit in regular forms, only count on
+// pages without a PCPartPicker page title.
+var challengeMarkers = []struct {
+	marker []byte
+	reason string
+	weak   bool
+}{
+	{[]byte("/cdn-cgi/challenge-platform/"), "cloudflare challenge", false},
+	{[]byte("cf-browser-verification"), "cloudflare challenge", false},
+	{[]byte("cf_chl_opt"), "cloudflare challenge", false},
+	{[]byte("<title>Just a moment...</title>"), "cloudflare challenge", false},
+	{[]byte("<title>Attention Required! | Cloudflare</title>"), "cloudflare block", false},
+	{[]byte("id=\"px-captcha\""), "perimeterx captcha", false},
+	{[]byte("class=\"g-recaptcha\""), "recaptcha", true},
+	{[]byte("class=\"h-captcha\""), "hcaptcha", true},
+	{[]byte("challenges.cloudflare.com/turnstile/"), "turnstile", true},
+	{[]byte("Verify you are human"), "human verification", true},
+	{[]byte("unusual traffic from your computer network"), "unusual traffic", true},
+}
+
+var pageTitleMarker = []byte("pageTitle")
+
+// detectChallenge returns why a response is a challenge page rather than
+// content, or an empty string.
+func detectChallenge(res *colly.Response) string {
+	if res.Headers != nil && res.Headers.Get("Cf-Mitigated") == "challenge" {
+		return "cloudflare challenge"
+	}
+	hasContent := bytes.Contains(res.Body, pageTitleMarker)
+	for _, challenge := range challengeMarkers {
+		if challenge.weak && hasContent {
+			continue
+		}
+		if bytes.Contains(res.Body, challenge.marker) {
+			return challenge.reason
+		}
+	}
+	return ""
+}
+
+// BlockedError is returned when PCPartPicker answers with a captcha, a bot
+// challenge or another interstitial page instead of the requested content.
+type BlockedError struct {
+	URL        string
+	StatusCode int
+	Reason     string
+	UserAgent  string
+	Proxy      string
+}
+
+func (e *BlockedError) Error() string {
+	return "blocked " + e.URL + " (" + strconv.Itoa(e.StatusCode) + "): " + e.Reason
+}
+
+func newBlockedError(res *colly.Response, reason string) *BlockedError {
+	return &BlockedError{
+		URL:        res.Request.URL.String(),
+		StatusCode: res.StatusCode,
+		Reason:     reason,
+		UserAgent:  res.Request.Headers.Get("User-Agent"),
+		Proxy:      egress(res.Request),
+	}
+}
+
+// egress returns the ID of the proxy a request went through, or "direct".
+func egress(req *colly.Request) string {
+	if req.ProxyURL != "" {
+		return req.ProxyURL
+	}
+	return "direct"
+}
+
+// BlockRate counts the responses received through an egress proxy or with a
+// user agent and how many of them were challenge pages.
+type BlockRate struct {
+	Requests uint64
+	Blocked  uint64
+	Rate     float64
+}
+
+// BlockStats holds the block rates per egress proxy and per user agent.
+type BlockStats struct {
+	Proxies    map[string]BlockRate
+	UserAgents map[string]BlockRate
+}
+
+// blockTracker counts blocked responses and notifies the blocked callbacks.
+type blockTracker struct {
+	mu         sync.Mutex
+	proxies    map[string]*BlockRate
+	userAgents map[string]*BlockRate
+	callbacks  []func(blocked *BlockedError)
+}
+
+func newBlockTracker() *blockTracker {
+	return &blockTracker{
+		proxies:    map[string]*BlockRate{},
+		userAgents: map[string]*BlockRate{},
+	}
+}
+
+func countBlock(rates map[string]*BlockRate, key string, blocked bool) {
+	rate, ok := rates[key]
+	if !ok {
+		rate = &BlockRate{}
+		rates[key] = rate
+	}
+	rate.Requests++
+	if blocked {
+		rate.Blocked++
+	}
+	rate.Rate = float64(rate.Blocked) / float64(rate.Requests)
+}
+
+// record counts a response and, when it was blocked, calls the blocked callbacks.
+func (t *blockTracker) record(req *colly.Request, blocked *BlockedError) {
+	t.mu.Lock()
+	countBlock(t.proxies, egress(req), blocked != nil)
+	countBlock(t.userAgents, req.Headers.Get("User-Agent"), blocked != nil)
+	callbacks := t.callbacks
+	t.mu.Unlock()
+
+	if blocked != nil {
+		for _, f := range callbacks {
+			f(blocked)
+		}
+	}
+}
+
+func (t *blockTracker) stats() BlockStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := BlockStats{
+		Proxies:    make(map[string]BlockRate, len(t.proxies)),
+		UserAgents: make(map[string]BlockRate, len(t.userAgents)),
+	}
+	for key, rate := range t.proxies {
+		stats.Proxies[key] = *rate
+	}
+	for key, rate := range t.userAgents {
+		stats.UserAgents[key] = *rate
+	}
+	return stats
+}
+
+// OnBlocked registers a function called with every challenge page met while scraping,
+// so proxies and user agents can be rotated or requests slowed down.
+func (scrap *Scraper) OnBlocked(f func(blocked *BlockedError)) {
+	scrap.blocks.mu.Lock()
+	scrap.blocks.callbacks = append(scrap.blocks.callbacks, f)
+	scrap.blocks.mu.Unlock()
+}
+
+// BlockStats returns the block rates per egress proxy and per user agent.
+func (scrap *Scraper) BlockStats() BlockStats {
+	return scrap.blocks.stats()
+}
@@ -0,0 +1,105 @@
+package scraper
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+)
+
+// CoalesceStats counts the scrapes of a kind of page and the calls that shared
+// the result of a scrape already in flight instead of starting their own.
+type CoalesceStats struct {
+	Scrapes   uint64
+	Coalesced uint64
+}
+
+type flight struct {
+	done   chan struct{}
+	result []byte
+	err    error
+}
+
+type flightCounters struct {
+	scrapes   atomic.Uint64
+	coalesced atomic.Uint64
+}
+
+// flightGroup deduplicates identical scrapes running at the same time.
+type flightGroup struct {
+	mu       sync.Mutex
+	flights  map[string]*flight
+	counters map[string]*flightCounters
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{
+		flights:  map[string]*flight{},
+		counters: map[string]*flightCounters{},
+	}
+}
+
+func (g *flightGroup) countersFor(kind string) *flightCounters {
+	counters, ok := g.counters[kind]
+	if !ok {
+		counters = &flightCounters{}
+		g.counters[kind] = counters
+	}
+	return counters
+}
+
+// stats returns the counters of every kind of scrape.
+func (g *flightGroup) stats() map[string]CoalesceStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := make(map[string]CoalesceStats, len(g.counters))
+	for kind, counters := range g.counters {
+		stats[kind] = CoalesceStats{
+			Scrapes:   counters.scrapes.Load(),
+			Coalesced: counters.coalesced.Load(),
+		}
+	}
+	return stats
+}
+
+// coalesce runs scrape unless a scrape of the same kind and key is in flight, in
+// which case it waits for it. The first caller gets the scraped value and the
+// callers that joined get their own copy, so they can modify it freely.
+func coalesce[T any](g *flightGroup, kind string, key string, scrape func() (*T, error)) (*T, error) {
+	key = kind + "|" + key
+
+	g.mu.Lock()
+	counters := g.countersFor(kind)
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		counters.coalesced.Add(1)
+
+		<-f.done
+		if f.err != nil {
+			return nil, f.err
+		}
+		var value T
+		if err := json.Unmarshal(f.result, &value); err != nil {
+			return nil, err
+		}
+		return &value, nil
+	}
+
+	f := &flight{done: make(chan struct{})}
+	g.flights[key] = f
+	g.mu.Unlock()
+	counters.scrapes.Add(1)
+
+	value, err := scrape()
+	f.err = err
+	if err == nil {
+		f.result, f.err = json.Marshal(value)
+	}
+
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+	close(f.done)
+
+	return value, err
+}
@@ -0,0 +1,114 @@
+package scraper
+
+import (
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/gofiber/fiber/v2/log"
+	"slices"
+	"sync"
+)
+
+// CompareRegions fetches a product on every given region at once, or on every
+// region supported by the site when regions is empty, and keeps the cheapest
+// in-stock vendor of each region. Regions where the product doesn't exist are
+// listed in Missing, regions that couldn't be fetched in Unavailable and regions
+// whose page couldn't be parsed in Errors.
+// Prices are left in the currency of their region. Regions are fetched a few at
+// a time so that the rate limit doesn't reject them, and the comparison only
+// fails when no region could be fetched because of the rate limit or a parse failure.
+func (scrap *Scraper) CompareRegions(productURL string, regions []string) (*models.RegionComparison, error) {
+	id := scrap.Site.ExtractProductID(productURL)
+	if id == "" {
+		return nil, invalidInput("invalid part URL")
+	}
+	if len(regions) == 0 {
+		regions = scrap.Site.SupportedRegions()
+	}
+
+	URLs := make([]string, len(regions))
+	for i, region := range regions {
+		URLs[i] = scrap.Site.RegionalProductURL(productURL, region)
+		if !scrap.Site.MatchProductURL(URLs[i]) {
+			return nil, invalidInput("invalid region")
+		}
+	}
+
+	parts := make([]*models.Part, len(regions))
+	errs := make([]error, len(regions))
+	slots := make(chan struct{}, scrap.fanOut(len(URLs)))
+	var wg sync.WaitGroup
+
+	for i, URL := range URLs {
+		wg.Add(1)
+		go func(i int, URL string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			part, err := scrap.GetPart(URL)
+			if err != nil {
+				log.Warn("Could not fetch ", URL, ": ", err)
+				errs[i] = err
+				return
+			}
+			parts[i] = part
+		}(i, URL)
+	}
+	wg.Wait()
+
+	// Rate limited and unparsable regions are only reported, unless no region could be fetched
+	if !slices.ContainsFunc(parts, func(part *models.Part) bool { return part != nil }) {
+		for _, kind := range []ErrorKind{KindRateLimited, KindParse} {
+			if i := slices.IndexFunc(errs, func(err error) bool { return KindOf(err) == kind }); i >= 0 {
+				return nil, errs[i]
+			}
+		}
+	}
+
+	comparison := &models.RegionComparison{
+		ID:          id,
+		Regions:     []models.RegionPrice{},
+		Unconverted: []string{},
+		Missing:     []string{},
+		Unavailable: []string{},
+		Errors:      []models.RegionError{},
+	}
+
+	for i, part := range parts {
+		if part == nil {
+			switch kind := KindOf(errs[i]); kind {
+			case KindNotFound:
+				comparison.Missing = append(comparison.Missing, regions[i])
+			case KindParse:
+				comparison.Errors = append(comparison.Errors, models.RegionError{
+					Region: regions[i],
+					Code:   string(kind),
+					Error:  errs[i].Error(),
+				})
+			default:
+				comparison.Unavailable = append(comparison.Unavailable, regions[i])
+			}
+			continue
+		}
+		if comparison.Name == "" {
+			comparison.Name = part.Name
+			comparison.Type = part.Type
+		}
+
+		regionPrice := models.RegionPrice{
+			Region: regions[i],
+			URL:    part.URL,
+		}
+		for _, vendor := range part.Vendors {
+			if !vendor.InStock || vendor.Price.Total <= 0 {
+				continue
+			}
+			if !regionPrice.InStock || vendor.Price.Total < regionPrice.Vendor.Price.Total {
+				regionPrice.Vendor = vendor
+				regionPrice.InStock = true
+			}
+		}
+		comparison.Regions = append(comparison.Regions, regionPrice)
+	}
+
+	return comparison, nil
+}
@@ -0,0 +1,72 @@
+package scraper
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// regionalPage serves a product on us and uk, no product on de, a page without
+// a product on fr and an error on it.
+func regionalPage(w http.ResponseWriter, r *http.Request) {
+	region, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Host"), ".")
+	prices := map[string]string{"pcpartpicker": "$449.00", "uk": "£389.99"}
+	switch region {
+	case "de":
+		w.WriteHeader(http.StatusNotFound)
+	case "fr":
+		fmt.Fprint(w, `<html><body><p>Maintenance</p></body></html>`)
+	case "it":
+		w.WriteHeader(http.StatusInternalServerError)
+	default:
+		fmt.Fprintf(w, `<html><body>
+<div class="wrapper__pageTitle"><section class="xs-col-11"><h1 class="pageTitle">Ryzen 7</h1></section></div>
+<section class="breadcrumb"><ol class="list-unstyled"><li><a href="/products/">CPU</a></li></ol></section>
+<section id="prices"><table><tbody><tr>
+	<td class="td__logo"><a href="/mr/amazon/Yg3mP6"><img src="//amazon.svg" alt="Amazon"></a></td>
+	<td class="td__availability">In stock</td>
+	<td class="td__finalPrice"><a href="/mr/amazon/Yg3mP6">%s</a></td>
+</tr></tbody></table></section>
+</body></html>`, prices[region])
+	}
+}
+
+func TestCompareRegions(t *testing.T) {
+	scrap := newFixtureScraper(t, http.HandlerFunc(regionalPage))
+	scrap.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+
+	comparison, err := scrap.CompareRegions("https://pcpartpicker.com/product/Yg3mP6/cpu", []string{"us", "uk", "de", "fr", "it"})
+	if err != nil {
+		t.Fatalf("CompareRegions: %v", err)
+	}
+
+	var totals []string
+	for _, region := range comparison.Regions {
+		totals = append(totals, region.Region+" "+region.Vendor.Price.TotalString)
+	}
+	if comparison.Name != "Ryzen 7" || !reflect.DeepEqual(totals, []string{"us $449.00", "uk £389.99"}) {
+		t.Errorf("unexpected regions %v of %q", totals, comparison.Name)
+	}
+	if !reflect.DeepEqual(comparison.Missing, []string{"de"}) || !reflect.DeepEqual(comparison.Unavailable, []string{"it"}) {
+		t.Errorf("unexpected missing %v and unavailable %v", comparison.Missing, comparison.Unavailable)
+	}
+	if len(comparison.Errors) != 1 || comparison.Errors[0].Region != "fr" || comparison.Errors[0].Code != string(KindParse) {
+		t.Errorf("expected a parse failure on fr, got %+v", comparison.Errors)
+	}
+}
+
+func TestCompareRegionsFailsWhenNoRegionParses(t *testing.T) {
+	scrap := newFixtureScraper(t, http.HandlerFunc(regionalPage))
+
+	_, err := scrap.CompareRegions("https://pcpartpicker.com/product/Yg3mP6/cpu", []string{"fr", "de"})
+	if KindOf(err) != KindParse {
+		t.Errorf("expected a parse failure, got %v", err)
+	}
+
+	comparison, err := scrap.CompareRegions("https://pcpartpicker.com/product/Yg3mP6/cpu", []string{"de"})
+	if err != nil || !reflect.DeepEqual(comparison.Missing, []string{"de"}) || len(comparison.Regions) != 0 {
+		t.Errorf("expected the product to be missing, got %+v, %v", comparison, err)
+	}
+}
@@ -0,0 +1,131 @@
+package scraper
+
+import (
+	"errors"
+	"github.com/gocolly/colly/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrorKind is the category of a scrape failure.
+type ErrorKind string
+
+const (
+	KindNotFound     ErrorKind = "not_found"
+	KindBlocked      ErrorKind = "blocked"
+	KindRateLimited  ErrorKind = "rate_limited"
+	KindParse        ErrorKind = "parse_failure"
+	KindInvalidInput ErrorKind = "invalid_input"
+	KindUpstreamDown ErrorKind = "upstream_down"
+	KindUnknown      ErrorKind = "unknown"
+)
+
+// Error is a scrape failure sorted into a category. RetryAfter is set when
+// PCPartPicker or the rate limit tells when to try again.
+type Error struct {
+	Kind       ErrorKind
+	URL        string
+	StatusCode int
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	message := string(e.Kind)
+	if e.URL != "" {
+		message += " " + e.URL
+	}
+	if e.StatusCode != 0 {
+		message += " (" + strconv.Itoa(e.StatusCode) + ")"
+	}
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	return message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// temporary reports whether retrying the request may succeed.
+func (e *Error) temporary() bool {
+	if e.Kind == KindRateLimited {
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return e.Kind == KindUpstreamDown && (e.StatusCode >= 500 || isTimeout(e.Err))
+}
+
+// KindOf returns the category of an error returned by the Scraper.
+func KindOf(err error) ErrorKind {
+	var scrapeErr *Error
+	if errors.As(err, &scrapeErr) {
+		return scrapeErr.Kind
+	}
+	var blockedErr *BlockedError
+	if errors.As(err, &blockedErr) {
+		return KindBlocked
+	}
+	return KindUnknown
+}
+
+func invalidInput(message string) error {
+	return &Error{Kind: KindInvalidInput, Err: errors.New(message)}
+}
+
+func parseFailure(URL string, err error) error {
+	return &Error{Kind: KindParse, URL: URL, Err: err}
+}
+
+// classifyResponse sorts the failure of a fetched page by its status code, or by
+// the transport error when no response was received.
+func classifyResponse(res *colly.Response, err error) *Error {
+	scrapeErr := &Error{
+		Kind:       KindUpstreamDown,
+		URL:        res.Request.URL.String(),
+		StatusCode: res.StatusCode,
+		Err:        err,
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		scrapeErr.Kind = KindNotFound
+	case res.StatusCode == http.StatusTooManyRequests:
+		scrapeErr.Kind = KindRateLimited
+		if res.Headers != nil {
+			scrapeErr.RetryAfter = parseRetryAfter(res.Headers.Get("Retry-After"))
+		}
+	case res.StatusCode == http.StatusForbidden:
+		scrapeErr.Kind = KindBlocked
+	case res.StatusCode >= 400 && res.StatusCode < 500:
+		scrapeErr.Kind = KindInvalidInput
+	}
+	return scrapeErr
+}
+
+// classifyVisit sorts an error returned by Collector.Visit before any response was received.
+func classifyVisit(URL string, err error) *Error {
+	scrapeErr := &Error{Kind: KindUpstreamDown, URL: URL, Err: err}
+	if errors.Is(err, colly.ErrRobotsTxtBlocked) || errors.Is(err, colly.ErrForbiddenDomain) || errors.Is(err, colly.ErrForbiddenURL) {
+		scrapeErr.Kind = KindBlocked
+	}
+	return scrapeErr
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as a date.
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
@@ -0,0 +1,216 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// fixtureRoutes maps the PCPartPicker pages recorded in testdata to the paths they were served from.
+var fixtureRoutes = map[string]string{
+	"/search":        "search.html",
+	"/search?page=2": "search_page2.html",
+	"/product/Yg3mP6/amd-ryzen-7-7800x3d-42-ghz-8-core-processor-100-100000910wof": "product.html",
+	"/products/cpu/":            "category.html",
+	"/list/Kp7XjD":              "list.html",
+	"/user/kreapc/saved/Rt8WyQ": "saved.html",
+}
+
+// fixtureHandler serves the recorded pages. A search for "7800x3d" redirects to
+// the product page, like PCPartPicker does for exact matches.
+func fixtureHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/search" && r.URL.Query().Get("q") == "7800x3d" {
+			http.Redirect(w, r, "/product/Yg3mP6/amd-ryzen-7-7800x3d-42-ghz-8-core-processor-100-100000910wof", http.StatusFound)
+			return
+		}
+
+		path := r.URL.Path
+		if page := r.URL.Query().Get("page"); page != "" && page != "1" {
+			path += "?page=" + page
+		}
+
+		name, ok := fixtureRoutes[path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Errorf("reading fixture %s: %v", name, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(body)
+	})
+}
+
+// assertGolden compares got, encoded as indented JSON, with testdata/golden/<name>.json.
+func assertGolden(t *testing.T, name string, got any) {
+	t.Helper()
+
+	encoded, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded = append(encoded, '\n')
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *update {
+		if err := os.WriteFile(path, encoded, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -update to create it): %v", err)
+	}
+	if diff := lineDiff(string(want), string(encoded)); diff != "" {
+		t.Errorf("%s does not match the scraped output (-golden +got):\n%s", path, diff)
+	}
+}
+
+// lineDiff returns the lines that differ between want and got, or an empty string if they are equal.
+func lineDiff(want, got string) string {
+	if want == got {
+		return ""
+	}
+
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+
+	var b strings.Builder
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			fmt.Fprintf(&b, "line %d:\n-%s\n+%s\n", i+1, w, g)
+		}
+	}
+	return b.String()
+}
+
+func TestSearchPCPartsGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  uint
+		limit uint
+	}{
+		{name: "search", page: 1},
+		{name: "search_page2", page: 2},
+		{name: "search_limit", page: 1, limit: 4},
+		{name: "search_limit_all", page: 1, limit: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scrap := newFixtureScraper(t, fixtureHandler(t))
+
+			results, err := scrap.SearchPCParts("ryzen 7", "us", tt.page, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, tt.name, results)
+		})
+	}
+}
+
+func TestSearchPCPartsRedirect(t *testing.T) {
+	scrap := newFixtureScraper(t, fixtureHandler(t))
+
+	_, err := scrap.SearchPCParts("7800x3d", "us", 1, 0)
+
+	var redirectError *RedirectError
+	if !errors.As(err, &redirectError) {
+		t.Fatalf("expected a RedirectError, got %v", err)
+	}
+	want := "https://pcpartpicker.com/product/Yg3mP6/amd-ryzen-7-7800x3d-42-ghz-8-core-processor-100-100000910wof"
+	if redirectError.URL != want {
+		t.Errorf("redirect URL = %q, want %q", redirectError.URL, want)
+	}
+}
+
+func TestGetPartGolden(t *testing.T) {
+	scrap := newFixtureScraper(t, fixtureHandler(t))
+
+	part, err := scrap.GetPart("https://pcpartpicker.com/product/Yg3mP6/amd-ryzen-7-7800x3d-42-ghz-8-core-processor-100-100000910wof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "product", part)
+}
+
+func TestGetPartListGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "list", url: "https://pcpartpicker.com/list/Kp7XjD"},
+		{name: "saved", url: "https://pcpartpicker.com/user/kreapc/saved/#view=Rt8WyQ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scrap := newFixtureScraper(t, fixtureHandler(t))
+
+			partList, err := scrap.GetPartList(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, tt.name, partList)
+		})
+	}
+}
+
+func TestBrowseCategoryGolden(t *testing.T) {
+	scrap := newFixtureScraper(t, fixtureHandler(t))
+
+	filter := models.CategoryFilter{
+		Manufacturers: []string{"3"},
+		MaxPrice:      500,
+		Sort:          models.SortPrice,
+	}
+	results, err := scrap.BrowseCategory(models.CategoryCPU, "us", filter, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "category", results)
+}
+
+// The region of the history is resolved from the product URL when none is given.
+func TestGetPriceHistoryGolden(t *testing.T) {
+	scrap := newFixtureScraper(t, fixtureHandler(t))
+
+	history, err := scrap.GetPriceHistory("https://pcpartpicker.com/product/Yg3mP6/amd-ryzen-7-7800x3d-42-ghz-8-core-processor-100-100000910wof", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "price_history", history)
+}
+
+func TestGetPriceHistoryWithoutChartData(t *testing.T) {
+	scrap := newFixtureScraper(t, http.HandlerFunc(productPage))
+
+	_, err := scrap.GetPriceHistory("https://pcpartpicker.com/product/Yg3mP6/amd-ryzen-7-7800x3d", "")
+	if KindOf(err) != KindParse {
+		t.Errorf("expected a parse failure, got %v", err)
+	}
+}
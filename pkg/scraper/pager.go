@@ -0,0 +1,58 @@
+package scraper
+
+// resultPage holds the results of a single page of search results or of a product listing.
+type resultPage[T any] struct {
+	parts    []T
+	total    uint
+	lastPage uint
+}
+
+// pagedResults holds the results collected from consecutive pages.
+type pagedResults[T any] struct {
+	parts   []T
+	page    uint
+	total   uint
+	hasMore bool
+}
+
+// collectPages fetches the pages of results from the given page on, until limit
+// results have been collected, the last page was reached or a page is empty.
+// A page below 1 is treated as the first page and a limit of 0 only fetches
+// the starting page.
+func collectPages[T any](page uint, limit uint, fetch func(page uint) (*resultPage[T], error)) (*pagedResults[T], error) {
+	if page < 1 {
+		page = 1
+	}
+
+	results := &pagedResults[T]{
+		parts: []T{},
+		page:  page,
+	}
+
+	for {
+		result, err := fetch(page)
+		if err != nil {
+			return nil, err
+		}
+
+		results.parts = append(results.parts, result.parts...)
+		results.page = page
+		results.total = result.total
+		if results.total == 0 && result.lastPage == 0 {
+			results.total = uint(len(results.parts))
+		}
+		results.hasMore = page < result.lastPage
+
+		if limit == 0 || uint(len(results.parts)) >= limit || !results.hasMore || len(result.parts) == 0 {
+			break
+		}
+		page++
+	}
+
+	if limit > 0 && uint(len(results.parts)) > limit {
+		results.parts = results.parts[:limit]
+		results.hasMore = true
+	}
+
+	return results, nil
+}
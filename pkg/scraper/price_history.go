@@ -0,0 +1,107 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/gocolly/colly/v2"
+	"net/url"
+	"time"
+)
+
+// priceHistorySelector selects the price history chart data of product pages.
+// The format hasn't been checked against a recorded PCPartPicker page, so a page
+// without it fails to parse rather than returning an empty history, and the API
+// doesn't serve price histories until it is.
+const priceHistorySelector = "script#price_history_data"
+
+// priceHistoryData is the chart data embedded in product pages. Timestamps are
+// in milliseconds and prices in minor units of the region currency.
+type priceHistoryData struct {
+	Symbol string `json:"symbol"`
+	Series []struct {
+		Merchant string     `json:"merchant"`
+		Points   [][2]int64 `json:"points"`
+	} `json:"series"`
+}
+
+// GetPriceHistory retrieves the price history of every vendor of a product on the given region.
+// An empty region keeps the region of the product URL, which is the region of the history.
+// It returns a pointer to models.PriceHistory and an error.
+// If the URL is invalid, it returns an error.
+func (scrap *Scraper) GetPriceHistory(productURL string, region string) (*models.PriceHistory, error) {
+	if !scrap.Site.MatchProductURL(productURL) {
+		return nil, invalidInput("invalid part URL")
+	}
+
+	URL := productURL
+	if region != "" {
+		URL = scrap.Site.RegionalProductURL(productURL, region)
+		if !scrap.Site.MatchProductURL(URL) {
+			return nil, invalidInput("invalid region")
+		}
+	}
+
+	col := scrap.newCollector()
+	var data priceHistoryData
+	parseErr := fmt.Errorf("no price history data")
+
+	col.OnHTML(priceHistorySelector, func(script *colly.HTMLElement) {
+		parseErr = json.Unmarshal([]byte(script.Text), &data)
+	})
+
+	err := scrap.visit(col, URL)
+
+	if err != nil {
+		return nil, err
+	}
+	if parseErr != nil {
+		return nil, parseFailure(URL, fmt.Errorf("could not parse price history: %v", parseErr))
+	}
+
+	pageRegion := ""
+	if pageURL, err := url.Parse(URL); err == nil {
+		pageRegion = scrap.Site.Region(pageURL.Hostname())
+	}
+	currency := models.RegionCurrency(pageRegion)
+
+	history := &models.PriceHistory{
+		URL:     URL,
+		Region:  pageRegion,
+		Vendors: []models.VendorPriceHistory{},
+	}
+
+	for _, series := range data.Series {
+		vendor := models.VendorPriceHistory{
+			Vendor: series.Merchant,
+			Points: []models.PricePoint{},
+		}
+
+		for _, point := range series.Points {
+			total := models.ParsedPrice{Amount: point[1], Currency: currency}.Float()
+			pricePoint := models.PricePoint{
+				Time: time.UnixMilli(point[0]).UTC(),
+				Price: models.Price{
+					Total:        total,
+					Currency:     data.Symbol,
+					CurrencyCode: currency,
+					TotalString:  models.FormatRegionPrice(point[1], data.Symbol, pageRegion),
+					Minor:        models.MinorPrice{Total: point[1]},
+				},
+			}
+			vendor.Points = append(vendor.Points, pricePoint)
+
+			if len(vendor.Points) == 1 || total < vendor.Lowest.Price.Total {
+				vendor.Lowest = pricePoint
+			}
+		}
+
+		if len(vendor.Points) > 0 && (history.LowestVendor == "" || vendor.Lowest.Price.Total < history.Lowest.Price.Total) {
+			history.Lowest = vendor.Lowest
+			history.LowestVendor = vendor.Vendor
+		}
+		history.Vendors = append(history.Vendors, vendor)
+	}
+
+	return history, nil
+}
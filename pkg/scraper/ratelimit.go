@@ -0,0 +1,184 @@
+package scraper
+
+import (
+	"errors"
+	"github.com/gocolly/colly/v2"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// RateLimit configures how politely the Scraper fetches pages.
+type RateLimit struct {
+	// HostConcurrency is the maximum number of concurrent requests to a host, 0 for no limit.
+	HostConcurrency int
+	// Delay is the time waited after each request to a host before sending the next one.
+	Delay time.Duration
+	// Jitter is the maximum random duration added to Delay.
+	Jitter time.Duration
+	// RequestsPerSecond is the budget shared by every host, 0 for no limit.
+	RequestsPerSecond float64
+	// Burst is the number of requests that can be sent at once within the budget.
+	Burst int
+	// MaxWait is the longest a scrape waits for its turn before failing with a KindRateLimited Error.
+	MaxWait time.Duration
+	// BlockCooldown is how long requests to a host are held back after it served a challenge page.
+	BlockCooldown time.Duration
+	// RespectRobotsTxt skips the pages disallowed by the robots.txt of the site.
+	RespectRobotsTxt bool
+}
+
+// limiter admits requests within the per-host concurrency and the global budget,
+// rejecting the ones that would wait too long instead of queueing them forever.
+type limiter struct {
+	limit RateLimit
+
+	mu     sync.Mutex
+	hosts  map[string]chan struct{}
+	paused map[string]time.Time
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(limit RateLimit) *limiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &limiter{
+		limit:  limit,
+		hosts:  map[string]chan struct{}{},
+		paused: map[string]time.Time{},
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+	}
+}
+
+// pause holds back the requests to host for the block cooldown.
+func (l *limiter) pause(host string) {
+	if l.limit.BlockCooldown <= 0 {
+		return
+	}
+	l.mu.Lock()
+	l.paused[host] = time.Now().Add(l.limit.BlockCooldown)
+	l.mu.Unlock()
+}
+
+// acquire waits for a request slot on host and returns the function releasing it.
+func (l *limiter) acquire(host string) (func(), error) {
+	release := func() {}
+
+	l.mu.Lock()
+	pause := time.Until(l.paused[host])
+	l.mu.Unlock()
+	if pause > l.limit.MaxWait {
+		return nil, &Error{Kind: KindRateLimited, RetryAfter: pause, Err: errors.New("backing off from " + host + " after a challenge page")}
+	}
+	if pause > 0 {
+		time.Sleep(pause)
+	}
+
+	if l.limit.HostConcurrency > 0 {
+		l.mu.Lock()
+		slots, ok := l.hosts[host]
+		if !ok {
+			slots = make(chan struct{}, l.limit.HostConcurrency)
+			l.hosts[host] = slots
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(l.limit.MaxWait)
+		select {
+		case slots <- struct{}{}:
+			timer.Stop()
+		case <-timer.C:
+			return nil, &Error{Kind: KindRateLimited, RetryAfter: l.limit.MaxWait + l.limit.Delay, Err: errors.New("too many concurrent requests to " + host)}
+		}
+		release = func() { <-slots }
+	}
+
+	wait, ok := l.reserve()
+	if !ok {
+		release()
+		return nil, &Error{Kind: KindRateLimited, RetryAfter: wait, Err: errors.New("request budget exhausted")}
+	}
+	time.Sleep(wait)
+	return release, nil
+}
+
+// reserve takes a token of the global budget and returns how long to wait before
+// using it. It takes nothing when the wait would exceed MaxWait.
+func (l *limiter) reserve() (time.Duration, bool) {
+	if l.limit.RequestsPerSecond <= 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.limit.RequestsPerSecond
+	if burst := float64(l.limit.Burst); l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+
+	wait := time.Duration((1 - l.tokens) / l.limit.RequestsPerSecond * float64(time.Second))
+	if wait > l.limit.MaxWait {
+		return wait, false
+	}
+	l.tokens--
+	return wait, true
+}
+
+// fanOut returns how many of n scrapes to run at once so that they don't wait
+// longer than MaxWait for the global budget: the burst when there is a budget.
+func (scrap *Scraper) fanOut(n int) int {
+	scrap.mu.RLock()
+	limiter := scrap.limiter
+	scrap.mu.RUnlock()
+
+	if limiter == nil || limiter.limit.RequestsPerSecond <= 0 {
+		return max(n, 1)
+	}
+	return max(min(n, limiter.limit.Burst), 1)
+}
+
+// SetRateLimit applies limit to every scrape started afterwards. Delays and jitter
+// are enforced by colly limit rules for each host of the site, which can't be
+// removed once added, so it is meant to be called once before scraping.
+func (scrap *Scraper) SetRateLimit(limit RateLimit) error {
+	var rules []*colly.LimitRule
+	if limit.Delay > 0 || limit.Jitter > 0 || limit.HostConcurrency > 0 {
+		parallelism := limit.HostConcurrency
+		if parallelism <= 0 {
+			parallelism = 1 << 10
+		}
+
+		for _, region := range scrap.Site.SupportedRegions() {
+			prefix, err := url.Parse(scrap.Site.BuildPrefixURL(region))
+			if err != nil {
+				return err
+			}
+			rules = append(rules, &colly.LimitRule{
+				DomainGlob:  prefix.Host,
+				Delay:       limit.Delay,
+				RandomDelay: limit.Jitter,
+				Parallelism: parallelism,
+			})
+		}
+	}
+	if err := scrap.Collector.Limits(rules); err != nil {
+		return err
+	}
+
+	scrap.mu.Lock()
+	scrap.limiter = newLimiter(limit)
+	scrap.Collector.IgnoreRobotsTxt = !limit.RespectRobotsTxt
+	scrap.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,145 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func rateLimitError(t *testing.T, err error) *Error {
+	t.Helper()
+	var scrapeErr *Error
+	if !errors.As(err, &scrapeErr) || scrapeErr.Kind != KindRateLimited {
+		t.Fatalf("expected a rate limited error, got %v", err)
+	}
+	return scrapeErr
+}
+
+func TestLimiterRejectsRequestsOverBudget(t *testing.T) {
+	l := newLimiter(RateLimit{RequestsPerSecond: 10, Burst: 2, MaxWait: 150 * time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		if wait, ok := l.reserve(); !ok || wait != 0 {
+			t.Fatalf("reservation %d within the burst waits %s, %v", i, wait, ok)
+		}
+	}
+	if wait, ok := l.reserve(); !ok || wait <= 0 || wait > 150*time.Millisecond {
+		t.Errorf("expected the third reservation to wait for a token, got %s, %v", wait, ok)
+	}
+	if wait, ok := l.reserve(); ok || wait <= 150*time.Millisecond {
+		t.Errorf("expected the fourth reservation to be rejected, got %s, %v", wait, ok)
+	}
+
+	_, err := l.acquire("pcpartpicker.com")
+	if scrapeErr := rateLimitError(t, err); scrapeErr.RetryAfter <= 0 {
+		t.Errorf("expected a Retry-After, got %s", scrapeErr.RetryAfter)
+	}
+}
+
+func TestLimiterHostConcurrency(t *testing.T) {
+	l := newLimiter(RateLimit{HostConcurrency: 1, MaxWait: 20 * time.Millisecond})
+
+	release, err := l.acquire("pcpartpicker.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.acquire("uk.pcpartpicker.com"); err != nil {
+		t.Errorf("expected other hosts to be admitted, got %v", err)
+	}
+	_, err = l.acquire("pcpartpicker.com")
+	rateLimitError(t, err)
+
+	release()
+	if _, err := l.acquire("pcpartpicker.com"); err != nil {
+		t.Errorf("expected the released slot to be reused, got %v", err)
+	}
+}
+
+func TestLimiterBacksOffAfterChallenge(t *testing.T) {
+	l := newLimiter(RateLimit{MaxWait: 10 * time.Millisecond, BlockCooldown: time.Minute})
+	l.pause("pcpartpicker.com")
+
+	_, err := l.acquire("pcpartpicker.com")
+	if scrapeErr := rateLimitError(t, err); scrapeErr.RetryAfter < 59*time.Second {
+		t.Errorf("expected to retry after the cooldown, got %s", scrapeErr.RetryAfter)
+	}
+	if _, err := l.acquire("uk.pcpartpicker.com"); err != nil {
+		t.Errorf("expected other hosts not to be paused, got %v", err)
+	}
+}
+
+func TestTooManyRequestsCarriesRetryAfter(t *testing.T) {
+	var hits atomic.Int32
+	scrap := newFixtureScraper(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	scrap.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+
+	_, err := scrap.GetPart("https://pcpartpicker.com/product/abcd/name")
+	if scrapeErr := rateLimitError(t, err); scrapeErr.RetryAfter != 7*time.Second || scrapeErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("unexpected error %+v", scrapeErr)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected a single attempt, got %d", hits.Load())
+	}
+}
+
+func productPage(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, `<html><body>
+<div class="wrapper__pageTitle"><section class="xs-col-11"><h1 class="pageTitle">Part</h1></section></div>
+<section class="breadcrumb"><ol class="list-unstyled"><li><a href="/products/">CPU</a></li></ol></section>
+</body></html>`)
+}
+
+func TestCompareRegionsStaysWithinRateLimit(t *testing.T) {
+	scrap := newFixtureScraper(t, http.HandlerFunc(productPage))
+	// Fetching every region at once would wait up to 240ms for the budget
+	if err := scrap.SetRateLimit(RateLimit{RequestsPerSecond: 100, Burst: 1, MaxWait: 50 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	comparison, err := scrap.CompareRegions("https://pcpartpicker.com/product/abcd/name", nil)
+	if err != nil {
+		t.Fatalf("CompareRegions: %v", err)
+	}
+	if len(comparison.Regions) != len(utils.KnownRegions) || len(comparison.Unavailable) != 0 {
+		t.Errorf("expected every region, got %d regions and unavailable %v", len(comparison.Regions), comparison.Unavailable)
+	}
+}
+
+func TestCompareRegionsReportsRateLimitedRegions(t *testing.T) {
+	var hits atomic.Int32
+	limited := atomic.Bool{}
+	limited.Store(true)
+	scrap := newFixtureScraper(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only the first request is rate limited, unless every request is
+		if hits.Add(1) == 1 || limited.Load() {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		productPage(w, r)
+	}))
+	scrap.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+
+	_, err := scrap.CompareRegions("https://pcpartpicker.com/product/abcd/name", []string{"us", "uk"})
+	if scrapeErr := rateLimitError(t, err); scrapeErr.RetryAfter != 3*time.Second {
+		t.Errorf("expected to retry after 3s, got %s", scrapeErr.RetryAfter)
+	}
+
+	limited.Store(false)
+	hits.Store(0)
+	comparison, err := scrap.CompareRegions("https://pcpartpicker.com/product/abcd/name", []string{"us", "uk", "de"})
+	if err != nil {
+		t.Fatalf("CompareRegions: %v", err)
+	}
+	if len(comparison.Regions) != 2 || len(comparison.Unavailable) != 1 {
+		t.Errorf("expected a single unavailable region, got %+v", comparison)
+	}
+}
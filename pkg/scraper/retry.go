@@ -0,0 +1,119 @@
+package scraper
+
+import (
+	"errors"
+	"github.com/gocolly/colly/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"net/url"
+	"time"
+)
+
+// RetryPolicy configures how temporary failures (timeouts, 5xx and 429 responses)
+// are retried: the nth retry waits Backoff * 2^(n-1), at most MaxBackoff, or as
+// long as the Retry-After header of a 429 response asks if it is longer.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// DefaultRetryPolicy tries each page 3 times, waiting 500ms then 1s.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     500 * time.Millisecond,
+	MaxBackoff:  10 * time.Second,
+}
+
+func (p RetryPolicy) delay(attempt int, scrapeErr *Error) time.Duration {
+	delay := p.Backoff << (attempt - 1)
+	if delay > p.MaxBackoff || delay <= 0 {
+		delay = p.MaxBackoff
+	}
+	if scrapeErr.RetryAfter > delay {
+		delay = min(scrapeErr.RetryAfter, p.MaxBackoff)
+	}
+	return delay
+}
+
+// SetRetryPolicy replaces the retry policy of the Scraper.
+func (scrap *Scraper) SetRetryPolicy(policy RetryPolicy) {
+	scrap.mu.Lock()
+	scrap.retry = policy
+	scrap.mu.Unlock()
+}
+
+// visit fetches URL with col once the rate limit lets it through and waits for
+// the scrape to end, retrying temporary failures as the retry policy allows.
+// Challenge pages fail with a *BlockedError and pause the host for the block
+// cooldown of the rate limit, other failures are returned as an *Error.
+func (scrap *Scraper) visit(col *colly.Collector, URL string) error {
+	scrap.mu.RLock()
+	limiter := scrap.limiter
+	retry := scrap.retry
+	scrap.mu.RUnlock()
+
+	var responseErr error
+	blocked := func(res *colly.Response) bool {
+		reason := detectChallenge(res)
+		if reason == "" {
+			scrap.blocks.record(res.Request, nil)
+			return false
+		}
+
+		blockedErr := newBlockedError(res, reason)
+		scrap.blocks.record(res.Request, blockedErr)
+		if limiter != nil {
+			limiter.pause(res.Request.URL.Host)
+		}
+		responseErr = blockedErr
+		return true
+	}
+	col.OnResponse(func(res *colly.Response) {
+		blocked(res)
+	})
+	col.OnError(func(res *colly.Response, err error) {
+		if res.StatusCode == 0 || !blocked(res) {
+			responseErr = classifyResponse(res, err)
+		}
+	})
+
+	for attempt := 1; ; attempt++ {
+		responseErr = nil
+		if err := scrap.visitOnce(col, URL, limiter); err != nil {
+			return err
+		}
+		if responseErr == nil {
+			return nil
+		}
+
+		var scrapeErr *Error
+		if !errors.As(responseErr, &scrapeErr) || !scrapeErr.temporary() || attempt >= retry.MaxAttempts {
+			return responseErr
+		}
+
+		delay := retry.delay(attempt, scrapeErr)
+		log.Warn("Retrying ", URL, " in ", delay, ": ", responseErr)
+		time.Sleep(delay)
+	}
+}
+
+func (scrap *Scraper) visitOnce(col *colly.Collector, URL string, limiter *limiter) error {
+	if limiter != nil {
+		u, err := url.Parse(URL)
+		if err != nil {
+			return invalidInput("invalid URL")
+		}
+		release, err := limiter.acquire(u.Host)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	err := col.Visit(URL)
+	col.Wait()
+	if err != nil {
+		return classifyVisit(URL, err)
+	}
+	return nil
+}
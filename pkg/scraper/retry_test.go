@@ -0,0 +1,108 @@
+package scraper
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const retryProductURL = "https://pcpartpicker.com/product/abcd/name"
+
+// failingThen answers the first failures requests with status, then serves a product page.
+func failingThen(failures int32, status int, hits *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		productPage(w, r)
+	})
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	var hits atomic.Int32
+	scrap := newFixtureScraper(t, failingThen(2, http.StatusBadGateway, &hits))
+	scrap.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: 20 * time.Millisecond, MaxBackoff: time.Second})
+
+	start := time.Now()
+	part, err := scrap.GetPart(retryProductURL)
+	if err != nil {
+		t.Fatalf("GetPart: %v", err)
+	}
+	if part.Name != "Part" || hits.Load() != 3 {
+		t.Errorf("expected the part after 3 attempts, got %q after %d", part.Name, hits.Load())
+	}
+	// 20ms then 40ms
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("expected an exponential backoff, retried within %s", elapsed)
+	}
+}
+
+func TestDoesNotRetryNotFound(t *testing.T) {
+	var hits atomic.Int32
+	scrap := newFixtureScraper(t, failingThen(1, http.StatusNotFound, &hits))
+	scrap.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	_, err := scrap.GetPart(retryProductURL)
+	if KindOf(err) != KindNotFound {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected a single attempt, got %d", hits.Load())
+	}
+}
+
+func TestRunsOutOfRetries(t *testing.T) {
+	var hits atomic.Int32
+	scrap := newFixtureScraper(t, failingThen(5, http.StatusServiceUnavailable, &hits))
+	scrap.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	_, err := scrap.GetPart(retryProductURL)
+	var scrapeErr *Error
+	if KindOf(err) != KindUpstreamDown || !errors.As(err, &scrapeErr) || scrapeErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected the last upstream failure, got %v", err)
+	}
+	if hits.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", hits.Load())
+	}
+}
+
+func TestClassifiesClientErrors(t *testing.T) {
+	for status, kind := range map[int]ErrorKind{
+		http.StatusGone:       KindNotFound,
+		http.StatusForbidden:  KindBlocked,
+		http.StatusBadRequest: KindInvalidInput,
+	} {
+		var hits atomic.Int32
+		scrap := newFixtureScraper(t, failingThen(1, status, &hits))
+		if _, err := scrap.GetPart(retryProductURL); KindOf(err) != kind || hits.Load() != 1 {
+			t.Errorf("%d: expected a single %s attempt, got %v after %d", status, kind, err, hits.Load())
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{Backoff: 500 * time.Millisecond, MaxBackoff: 3 * time.Second}
+
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{1, 0, 500 * time.Millisecond},
+		{2, 0, time.Second},
+		{3, 0, 2 * time.Second},
+		{4, 0, 3 * time.Second},
+		{64, 0, 3 * time.Second},
+		{1, 2 * time.Second, 2 * time.Second},
+		{2, 100 * time.Millisecond, time.Second},
+		{1, time.Minute, 3 * time.Second},
+	}
+	for _, test := range tests {
+		if got := policy.delay(test.attempt, &Error{RetryAfter: test.retryAfter}); got != test.want {
+			t.Errorf("delay(%d, %s) = %s, want %s", test.attempt, test.retryAfter, got, test.want)
+		}
+	}
+}
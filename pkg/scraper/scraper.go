@@ -1,15 +1,19 @@
 package scraper
 
 import (
+	"context"
 	"errors"
 	"github.com/Aquilabot/KreaPC-API/internal/models"
 	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"github.com/Aquilabot/KreaPC-API/pkg/proxy"
 	"github.com/gocolly/colly/v2"
 	"github.com/gocolly/colly/v2/extensions"
 	"github.com/gofiber/fiber/v2/log"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 var (
@@ -30,9 +34,23 @@ var (
 	}
 )
 
+// Scraper scrapes PCPartPicker pages. Collector is only used as a template:
+// every scrape runs on its own clone so concurrent calls never share callbacks.
+// Site decides which URLs are built and accepted.
 type Scraper struct {
 	Collector *colly.Collector
 	Headers   map[string]map[string]string
+	Site      *utils.Site
+
+	mu              sync.RWMutex
+	randomUserAgent bool
+	partCallbacks   []func(part *models.Part)
+	flights         *flightGroup
+	limiter         *limiter
+	retry           RetryPolicy
+	blocks          *blockTracker
+	baseURL         *url.URL
+	proxies         *proxy.Pool
 }
 
 type RedirectError struct {
@@ -43,6 +61,72 @@ func (r RedirectError) Error() string {
 	return r.URL
 }
 
+// proxyHeader carries the proxy picked for a collector request to the transport.
+// It is removed before the request is sent.
+const proxyHeader = "X-Kreapc-Proxy"
+
+// Session is the identity an authenticated scrape is made with: the cookie jar
+// holding the session of an account, and the proxy and user agent the account
+// keeps to. The proxy is used instead of the ones picked from the proxy pool.
+type Session struct {
+	Jar       http.CookieJar
+	Proxy     *url.URL
+	UserAgent string
+}
+
+// sessionKey holds in the context of a request the Session of an authenticated
+// scrape, whose jar is used instead of the cookies shared by every scrape.
+type sessionKey struct{}
+
+// transport sends requests through the proxy picked for them and, when target
+// is set, to another origin while leaving the request URL seen by the collector
+// untouched. The original host is sent in X-Forwarded-Host so the other origin
+// can tell the regions apart.
+type transport struct {
+	target *url.URL
+	next   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	if value := out.Header.Get(proxyHeader); value != "" {
+		out.Header.Del(proxyHeader)
+		if proxyURL, err := url.Parse(value); err == nil {
+			out = out.WithContext(proxy.WithURL(out.Context(), proxyURL))
+		}
+	}
+	var jar http.CookieJar
+	if session, _ := req.Context().Value(sessionKey{}).(*Session); session != nil {
+		jar = session.Jar
+	}
+	if jar != nil {
+		out.Header.Del("Cookie")
+		for _, cookie := range jar.Cookies(req.URL) {
+			out.AddCookie(cookie)
+		}
+	}
+	if t.target != nil {
+		out.URL.Scheme = t.target.Scheme
+		out.URL.Host = t.target.Host
+		out.URL.Path = strings.TrimSuffix(t.target.Path, "/") + req.URL.Path
+		out.Host = ""
+		out.Header.Set("X-Forwarded-Host", req.URL.Host)
+	}
+
+	res, err := t.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	if jar != nil {
+		if cookies := res.Cookies(); len(cookies) > 0 {
+			jar.SetCookies(req.URL, cookies)
+		}
+		res.Header.Del("Set-Cookie")
+	}
+	res.Request = req
+	return res, nil
+}
+
 func linkURL(parts ...string) string {
 	last := parts[len(parts)-1]
 	if last == "" {
@@ -53,75 +137,267 @@ func linkURL(parts ...string) string {
 	return strings.Join(parts, "")
 }
 
-func buildSearchURL(searchTerm string, region string) string {
-	fullURL := utils.BuildPrefixURL(region) + "search?q=" + url.QueryEscape(searchTerm)
+// listQuantity returns the quantity of a part list row, shown as a number or as
+// the selected option of the quantity select when editing the list. Rows
+// without a quantity hold a single part.
+func listQuantity(prod *colly.HTMLElement) int {
+	text := prod.ChildAttr(".td__quantity option[selected]", "value")
+	if text == "" {
+		text = prod.ChildText(".td__quantity")
+	}
+	text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "x"))
+	if quantity, err := strconv.Atoi(text); err == nil && quantity > 0 {
+		return quantity
+	}
+	return 1
+}
+
+// parsePrice parses a price scraped from pageURL using the number format of the
+// page region. Prices that can't be parsed are logged and reported as empty.
+func (scrap *Scraper) parsePrice(text string, pageURL *url.URL) models.ParsedPrice {
+	price, err := models.ParseRegionPrice(text, scrap.Site.Region(pageURL.Hostname()))
+	if err != nil {
+		log.Warn("Could not parse price on ", pageURL.String(), ": ", err)
+		return models.ParsedPrice{Kind: models.PriceKindEmpty}
+	}
+	return price
+}
+
+func buildSearchURL(site *utils.Site, searchTerm string, region string, page uint) string {
+	fullURL := site.BuildPrefixURL(region) + "search?q=" + url.QueryEscape(searchTerm)
+	if page > 1 {
+		fullURL += "&page=" + strconv.FormatUint(uint64(page), 10)
+	}
 	return fullURL
 }
 
-// NewScraper initializes a new instance of the Scraper type and returns it.
-// It creates a new collector, sets the async and AllowURLRevisit properties to true,
+// NewScraper initializes a new instance of the Scraper type for utils.DefaultSite and returns it.
+// It creates the template collector, sets the async and AllowURLRevisit properties to true,
 // and initializes an empty Headers map with the "global" site.
-func NewScraper() Scraper {
+func NewScraper() *Scraper {
+	return NewSiteScraper(utils.DefaultSite)
+}
+
+// NewSiteScraper is like NewScraper but builds and matches URLs for the given site.
+func NewSiteScraper(site *utils.Site) *Scraper {
 	col := colly.NewCollector()
 	col.Async = true
 	col.AllowURLRevisit = true
 
-	s := Scraper{
+	scrap := &Scraper{
 		Collector: col,
+		Headers: map[string]map[string]string{
+			"global": {},
+		},
+		Site:    site,
+		flights: newFlightGroup(),
+		retry:   DefaultRetryPolicy,
+		blocks:  newBlockTracker(),
 	}
-	s.Headers = map[string]map[string]string{
-		"global": {},
+	scrap.installTransport()
+	return scrap
+}
+
+// UpdateHeaders replaces the headers sent to the given site with a copy of newHeaders.
+// Headers stored under "global" are sent to every site, and site specific
+// headers take precedence over them.
+func (scrap *Scraper) UpdateHeaders(site string, newHeaders map[string]string) {
+	headers := make(map[string]string, len(newHeaders))
+	for k, v := range newHeaders {
+		headers[k] = v
 	}
 
-	return s
+	scrap.mu.Lock()
+	scrap.Headers[site] = headers
+	scrap.mu.Unlock()
 }
 
-// UpdateHeaders updates the headers for the given site with the provided newHeaders map.
-// It updates the headers for the "global" site as well.
-// It also sets the headers for each request made by the Collector.
-func (scrap *Scraper) UpdateHeaders(site string, newHeaders map[string]string) {
-	scrap.Headers[site] = newHeaders
+// RandomizeUserAgent makes every collector created by the Scraper send a random
+// User-Agent and log the chosen User-Agent for every request it makes.
+func (scrap *Scraper) RandomizeUserAgent() {
+	scrap.mu.Lock()
+	scrap.randomUserAgent = true
+	scrap.mu.Unlock()
+}
 
-	for k, v := range newHeaders {
-		scrap.Headers[site][k] = v
+// SetBaseURL sends every request made by the Scraper to baseURL instead of the
+// PCPartPicker host of the requested URL, keeping the path and query.
+// Scraped URLs still point to PCPartPicker, so mirrors, recording proxies and
+// local fixture servers can stand in for the real site.
+func (scrap *Scraper) SetBaseURL(baseURL string) error {
+	target, err := url.Parse(baseURL)
+	if err != nil {
+		return err
 	}
+	if target.Scheme == "" || target.Host == "" {
+		return errors.New("invalid base URL")
+	}
+
+	scrap.mu.Lock()
+	scrap.baseURL = target
+	scrap.mu.Unlock()
+	scrap.installTransport()
+	return nil
+}
+
+// SetProxyPool spreads the requests of the Scraper across the proxies of pool,
+// picking a proxy for every request. Proxies serving challenge pages are ejected from the pool.
+func (scrap *Scraper) SetProxyPool(pool *proxy.Pool) {
+	scrap.mu.Lock()
+	scrap.proxies = pool
+	scrap.mu.Unlock()
+	scrap.installTransport()
+
+	scrap.OnBlocked(func(blocked *BlockedError) {
+		pool.MarkBlocked(blocked.Proxy)
+	})
+}
 
-	scrap.Collector.OnRequest(func(r *colly.Request) {
-		headers := scrap.Headers["global"]
-		for k, v := range scrap.Headers[r.URL.Hostname()] {
-			headers[k] = v
+func (scrap *Scraper) installTransport() {
+	scrap.mu.RLock()
+	defer scrap.mu.RUnlock()
+
+	var next http.RoundTripper
+	if scrap.proxies != nil {
+		next = proxy.NewTransport(scrap.proxies)
+	} else {
+		// Without a pool, only the proxy of a Session is used
+		base := http.DefaultTransport.(*http.Transport).Clone()
+		base.Proxy = func(req *http.Request) (*url.URL, error) {
+			if proxyURL := proxy.URLFromContext(req.Context()); proxyURL != nil {
+				return proxyURL, nil
+			}
+			return http.ProxyFromEnvironment(req)
 		}
+		next = base
+	}
+	scrap.Collector.WithTransport(&transport{
+		target: scrap.baseURL,
+		next:   next,
+	})
+}
+
+// proxyID returns the ID a proxy is reported under, which never holds its credentials.
+func (scrap *Scraper) proxyID(proxyURL *url.URL) string {
+	scrap.mu.RLock()
+	proxies := scrap.proxies
+	scrap.mu.RUnlock()
+
+	if proxies == nil {
+		return proxyURL.Redacted()
+	}
+	return proxies.ID(proxyURL.String())
+}
 
-		for k, v := range headers {
+// OnPartScraped registers a function called with every part successfully scraped by GetPart.
+func (scrap *Scraper) OnPartScraped(f func(part *models.Part)) {
+	scrap.mu.Lock()
+	scrap.partCallbacks = append(scrap.partCallbacks, f)
+	scrap.mu.Unlock()
+}
+
+// CoalesceStats returns, for "part" and "list" scrapes, how many scrapes were
+// started and how many calls shared a scrape already in flight.
+func (scrap *Scraper) CoalesceStats() map[string]CoalesceStats {
+	return scrap.flights.stats()
+}
+
+// headersFor merges the global headers with the headers of the given host.
+func (scrap *Scraper) headersFor(host string) map[string]string {
+	scrap.mu.RLock()
+	defer scrap.mu.RUnlock()
+
+	headers := make(map[string]string, len(scrap.Headers["global"])+len(scrap.Headers[host]))
+	for k, v := range scrap.Headers["global"] {
+		headers[k] = v
+	}
+	for k, v := range scrap.Headers[host] {
+		headers[k] = v
+	}
+	return headers
+}
+
+// newCollector clones the template collector and applies the configured
+// User-Agent and header settings, so callbacks registered on it only live for a single scrape.
+func (scrap *Scraper) newCollector() *colly.Collector {
+	col := scrap.Collector.Clone()
+
+	scrap.mu.RLock()
+	randomUserAgent := scrap.randomUserAgent
+	proxies := scrap.proxies
+	scrap.mu.RUnlock()
+
+	if randomUserAgent {
+		extensions.RandomUserAgent(col)
+		col.OnRequest(func(r *colly.Request) {
+			log.Info("User-Agent:", r.Headers.Get("User-Agent"))
+		})
+	}
+
+	if proxies != nil {
+		col.OnRequest(func(r *colly.Request) {
+			// Only the transport sees the proxy URL, its credentials included
+			if proxyURL, err := proxies.Pick(); err == nil {
+				r.ProxyURL = scrap.proxyID(proxyURL)
+				r.Headers.Set(proxyHeader, proxyURL.String())
+			}
+		})
+	}
+
+	col.OnRequest(func(r *colly.Request) {
+		for k, v := range scrap.headersFor(r.URL.Hostname()) {
 			if len(k) > 0 && len(v) > 0 {
 				r.Headers.Set(k, v)
 			}
 		}
 	})
-}
 
-// RandomizeUserAgent is a method of the Scraper struct.
-// It sets a random User-Agent in the Collector headers
-// and logs the chosen User-Agent for every request made by the collector.
-func (scrap *Scraper) RandomizeUserAgent() {
-	extensions.RandomUserAgent(scrap.Collector)
-	scrap.Collector.OnRequest(func(r *colly.Request) {
-		log.Info("User-Agent:", r.Headers.Get("User-Agent"))
-	})
+	return col
 }
 
 // GetPartList retrieves a list of parts from the given PCPartPicker URL.
 // It returns a pointer to models.PartList and an error.
 // If the URL is invalid, it returns an error.
+// Concurrent calls for the same list share a single scrape.
 func (scrap *Scraper) GetPartList(URL string) (*models.PartList, error) {
-	if !utils.MatchPCPPURL(URL) {
-		return nil, errors.New("invalid PCPartPicker URL")
+	if !scrap.Site.MatchPCPPURL(URL) {
+		return nil, invalidInput("invalid PCPartPicker URL")
+	}
+	URL = scrap.Site.ConvertListURL(URL)
+
+	return coalesce(scrap.flights, "list", URL, func() (*models.PartList, error) {
+		return scrap.getPartList(URL, nil)
+	})
+}
+
+// GetSavedPartList is like GetPartList for lists only their owner can see,
+// such as private saved lists, made with the cookies, proxy and user agent of
+// session. Cookies set by PCPartPicker go to the session jar and not to the
+// other scrapes, and the list isn't shared with concurrent scrapes.
+func (scrap *Scraper) GetSavedPartList(URL string, session Session) (*models.PartList, error) {
+	if !scrap.Site.MatchPartListURL(URL) {
+		return nil, invalidInput("invalid part list URL")
 	}
-	URL = utils.ConvertListURL(URL)
+	return scrap.getPartList(scrap.Site.ConvertListURL(URL), &session)
+}
 
+func (scrap *Scraper) getPartList(URL string, session *Session) (*models.PartList, error) {
+	col := scrap.newCollector()
+	if session != nil {
+		col.Context = context.WithValue(col.Context, sessionKey{}, session)
+		col.OnRequest(func(r *colly.Request) {
+			if session.Proxy != nil {
+				r.ProxyURL = scrap.proxyID(session.Proxy)
+				r.Headers.Set(proxyHeader, session.Proxy.String())
+			}
+			if session.UserAgent != "" {
+				r.Headers.Set("User-Agent", session.UserAgent)
+			}
+		})
+	}
 	var partList models.PartList
 
-	scrap.Collector.OnHTML(".partlist__wrapper", func(elem *colly.HTMLElement) {
+	col.OnHTML(".partlist__wrapper", func(elem *colly.HTMLElement) {
 		parts := []models.ListPart{}
 
 		elem.ForEach(".tr__product", func(i int, prod *colly.HTMLElement) {
@@ -133,41 +409,50 @@ func (scrap *Scraper) GetPartList(URL string) (*models.PartList, error) {
 			for k, v := range partListClassMappings {
 				toParse := prod.ChildText(v)
 
-				if strings.HasSuffix(toParse, "No Prices Available") || toParse == "FREE" {
+				stringPrice := strings.TrimSpace(strings.Replace(toParse, k, "", 1))
+				parsed := scrap.parsePrice(stringPrice, elem.Request.URL)
+				if parsed.Kind != models.PriceKindAmount && parsed.Kind != models.PriceKindDiscount {
 					continue
 				}
-				stringPrice := strings.Replace(toParse, k, "", 1)
-				price, curr, _ := models.ParsePrice(stringPrice)
+				price := parsed.Float()
 
 				switch k {
 				case "Base":
 					prodVendor.Price.Base = price
+					prodVendor.Price.Minor.Base = parsed.Amount
 				case "Promo":
 					prodVendor.Price.Discounts = price
+					prodVendor.Price.Minor.Discounts = parsed.Amount
 				case "Shipping":
 					prodVendor.Price.Shipping = price
+					prodVendor.Price.Minor.Shipping = parsed.Amount
 				case "Tax":
-					prodVendor.Price.Shipping = price
+					prodVendor.Price.Tax = price
+					prodVendor.Price.Minor.Tax = parsed.Amount
 				case "Price":
-					prodVendor.Price.TotalString = strings.TrimSpace(stringPrice)
+					prodVendor.Price.TotalString = stringPrice
 					prodVendor.Price.Total = price
-					prodVendor.Price.Currency = curr
+					prodVendor.Price.Minor.Total = parsed.Amount
+					prodVendor.Price.Currency = parsed.Symbol
+					prodVendor.Price.CurrencyCode = parsed.Currency
 					prodVendor.InStock = true
 				}
 			}
 
 			if prodVendor.InStock {
-				prodVendor.URL = linkURL("https://", elem.Request.URL.Host, prod.ChildAttr(".td__where a", "href"))
+				prodVendor.URL = linkURL(elem.Request.URL.Scheme+"://", elem.Request.URL.Host, prod.ChildAttr(".td__where a", "href"))
 				prodVendor.Image = linkURL("https:", prod.ChildAttr(".td__where a img", "src"))
-				prodVendor.Name = utils.ExtractVendorName(prodVendor.URL)
+				prodVendor.Name = scrap.Site.ExtractVendorName(prodVendor.URL)
 			}
 
 			part := models.ListPart{
-				Type:   prod.ChildText(".td__component"),
-				Name:   prod.ChildText(".td__name"),
-				Image:  linkURL("https:", prod.ChildAttr(".td__image a img", "src")),
-				URL:    linkURL("https://", elem.Request.URL.Host, prod.ChildAttr(".td__name a", "href")),
-				Vendor: prodVendor,
+				Type:     prod.ChildText(".td__component"),
+				Name:     prod.ChildText(".td__name"),
+				Image:    linkURL("https:", prod.ChildAttr(".td__image a img", "src")),
+				URL:      linkURL(elem.Request.URL.Scheme+"://", elem.Request.URL.Host, prod.ChildAttr(".td__name a", "href")),
+				Vendor:   prodVendor,
+				Quantity: listQuantity(prod),
+				Custom:   prod.DOM.Find(`.td__name a[href*="/product/"]`).Length() == 0,
 			}
 
 			parts = append(parts, part)
@@ -177,21 +462,28 @@ func (scrap *Scraper) GetPartList(URL string) (*models.PartList, error) {
 
 		elem.ForEach(".tr__total", func(i int, node *colly.HTMLElement) {
 			stringPrice := node.ChildText(".td__price")
-			val, curr, _ := models.ParsePrice(stringPrice)
+			parsed := scrap.parsePrice(stringPrice, elem.Request.URL)
+			val := parsed.Float()
 
 			switch node.ChildText(".td__label") {
 			case "Base Total:":
 				listPrice.Base = val
+				listPrice.Minor.Base = parsed.Amount
 			case "Tax:":
 				listPrice.Tax = val
+				listPrice.Minor.Tax = parsed.Amount
 			case "Promo Discounts:":
 				listPrice.Discounts = val
+				listPrice.Minor.Discounts = parsed.Amount
 			case "Shipping:":
 				listPrice.Shipping = val
+				listPrice.Minor.Shipping = parsed.Amount
 			case "Total:":
 				listPrice.Total = val
+				listPrice.Minor.Total = parsed.Amount
 				listPrice.TotalString = stringPrice
-				listPrice.Currency = curr
+				listPrice.Currency = parsed.Symbol
+				listPrice.CurrencyCode = parsed.Currency
 			}
 		})
 
@@ -213,99 +505,160 @@ func (scrap *Scraper) GetPartList(URL string) (*models.PartList, error) {
 			Compatibility: compNotes,
 		}
 	})
-	err := scrap.Collector.Visit(URL)
-	scrap.Collector.Wait()
+	err := scrap.visit(col, URL)
 
 	if err != nil {
 		return nil, err
 	}
+	if partList.URL == "" {
+		return nil, parseFailure(URL, errors.New("no part list found on page"))
+	}
 
 	return &partList, nil
 }
 
+// searchPage holds the results of a single page of search results and the URL
+// the search landed on.
+type searchPage struct {
+	resultPage[models.SearchPart]
+	reqURL string
+}
+
 // SearchPCParts retrieves a list of parts from the given search term and region.
-// It returns a slice of models.SearchPart and an error.
+// It starts at the given page and keeps following the next pages until limit results
+// have been collected. A page below 1 is treated as the first page and a limit of 0
+// only returns the starting page.
+// It returns a pointer to models.SearchResults and an error.
 // If the region is invalid, it returns an error.
-func (scrap *Scraper) SearchPCParts(searchTerm string, region string) ([]models.SearchPart, error) {
-	fullURL := buildSearchURL(searchTerm, region)
+func (scrap *Scraper) SearchPCParts(searchTerm string, region string, page uint, limit uint) (*models.SearchResults, error) {
+	if page < 1 {
+		page = 1
+	}
 
-	if !utils.MatchPCPPURL(fullURL) {
-		return nil, errors.New("invalid region")
+	if !scrap.Site.MatchPCPPURL(buildSearchURL(scrap.Site, searchTerm, region, page)) {
+		return nil, invalidInput("invalid region")
 	}
 
-	searchResults := []models.SearchPart{}
+	paged, err := collectPages(page, limit, func(page uint) (*resultPage[models.SearchPart], error) {
+		result, err := scrap.searchPage(buildSearchURL(scrap.Site, searchTerm, region, page))
+		if err != nil {
+			return nil, err
+		}
+		if scrap.Site.MatchProductURL(result.reqURL) {
+			return nil, &RedirectError{
+				URL: result.reqURL,
+			}
+		}
+		return &result.resultPage, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	var reqURL string
+	return &models.SearchResults{
+		Parts:   paged.parts,
+		Page:    paged.page,
+		Total:   paged.total,
+		HasMore: paged.hasMore,
+	}, nil
+}
 
-	scrap.Collector.OnHTML(".pageTitle", func(h *colly.HTMLElement) {
-		reqURL = h.Request.URL.String()
+// searchPage scrapes a single page of search results.
+func (scrap *Scraper) searchPage(fullURL string) (*searchPage, error) {
+	col := scrap.newCollector()
+	result := &searchPage{
+		resultPage: resultPage[models.SearchPart]{
+			parts: []models.SearchPart{},
+		},
+	}
+
+	col.OnHTML(".pageTitle", func(h *colly.HTMLElement) {
+		result.reqURL = h.Request.URL.String()
 	})
 
-	scrap.Collector.OnHTML(".search-results__pageContent .block", func(elem *colly.HTMLElement) {
+	col.OnHTML(".search-results__pageContent .block", func(elem *colly.HTMLElement) {
 		elem.ForEach(".list-unstyled li", func(i int, searchResult *colly.HTMLElement) {
-			partVendorURL := linkURL("https://", elem.Request.URL.Host, searchResult.ChildAttr(".search_results--price a", "href"))
+			partVendorURL := linkURL(elem.Request.URL.Scheme+"://", elem.Request.URL.Host, searchResult.ChildAttr(".search_results--price a", "href"))
 			extractedPrice := searchResult.ChildText(".search_results--price a")
 
-			price, curr, _ := models.ParsePrice(extractedPrice)
+			price := scrap.parsePrice(extractedPrice, elem.Request.URL)
 
 			extractedVendorName := ""
 
 			if extractedPrice != "" {
-				extractedVendorName = utils.ExtractVendorName(partVendorURL)
+				extractedVendorName = scrap.Site.ExtractVendorName(partVendorURL)
 			}
 
 			partVendor := models.Vendor{
 				URL:  partVendorURL,
 				Name: extractedVendorName,
 				Price: models.Price{
-					Total:       price,
-					TotalString: extractedPrice,
-					Currency:    curr,
+					Total:        price.Float(),
+					TotalString:  extractedPrice,
+					Currency:     price.Symbol,
+					CurrencyCode: price.Currency,
+					Minor:        models.MinorPrice{Total: price.Amount},
 				},
 				InStock: len(extractedPrice) > 0,
 			}
 
-			searchResults = append(searchResults, models.SearchPart{
+			result.parts = append(result.parts, models.SearchPart{
 				Name:   searchResult.ChildText(".search_results--link a"),
 				Image:  linkURL("https:", searchResult.ChildAttr(".search_results--img a img", "src")),
-				URL:    linkURL("https://", elem.Request.URL.Host, searchResult.ChildAttr(".search_results--link a", "href")),
+				URL:    linkURL(elem.Request.URL.Scheme+"://", elem.Request.URL.Host, searchResult.ChildAttr(".search_results--link a", "href")),
 				Vendor: partVendor,
 			})
 		})
 	})
 
-	err := scrap.Collector.Visit(fullURL)
-	scrap.Collector.Wait()
+	col.OnHTML(".search-results__pageContent .search-results__count", func(count *colly.HTMLElement) {
+		result.total = utils.ExtractResultCount(count.Text)
+	})
+
+	col.OnHTML(".search-results__pageContent .pagination li", func(pageLink *colly.HTMLElement) {
+		if pageNumber, err := strconv.Atoi(strings.TrimSpace(pageLink.Text)); err == nil && uint(pageNumber) > result.lastPage {
+			result.lastPage = uint(pageNumber)
+		}
+	})
+
+	err := scrap.visit(col, fullURL)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if utils.MatchProductURL(reqURL) {
-		return nil, &RedirectError{
-			URL: reqURL,
-		}
-	}
-
-	return searchResults, nil
+	return result, nil
 }
 
 // GetPart retrieves information about a specific part from the given URL.
 // It returns a pointer to models.Part and an error.
 // If the URL is invalid, it returns an error.
+// Concurrent calls for the same part share a single scrape.
 func (scrap *Scraper) GetPart(URL string) (*models.Part, error) {
-	if !utils.MatchProductURL(URL) {
-		return nil, errors.New("invalid part URL")
+	if !scrap.Site.MatchProductURL(URL) {
+		return nil, invalidInput("invalid part URL")
 	}
 
+	// Variants of a product URL share a scrape of the product on its region
+	key := scrap.Site.ProductKey(URL)
+	region, _, _ := strings.Cut(key, "/")
+	URL = scrap.Site.RegionalProductURL(URL, region)
+
+	return coalesce(scrap.flights, "part", key, func() (*models.Part, error) {
+		return scrap.getPart(URL)
+	})
+}
+
+func (scrap *Scraper) getPart(URL string) (*models.Part, error) {
+	col := scrap.newCollector()
 	var images []string
 
-	scrap.Collector.OnHTML(".single_image_gallery_box", func(image *colly.HTMLElement) {
+	col.OnHTML(".single_image_gallery_box", func(image *colly.HTMLElement) {
 		images = append(images, linkURL("https:", image.ChildAttr("a img", "src")))
 	})
 
 	if len(images) < 1 {
-		scrap.Collector.OnHTML("script", func(script *colly.HTMLElement) {
+		col.OnHTML("script", func(script *colly.HTMLElement) {
 			images = utils.FindScriptImages(script, images)
 		})
 	}
@@ -313,7 +666,7 @@ func (scrap *Scraper) GetPart(URL string) (*models.Part, error) {
 	rating := models.RatingStats{}
 	var name string
 
-	scrap.Collector.OnHTML(".wrapper__pageTitle section.xs-col-11", func(ratingContainer *colly.HTMLElement) {
+	col.OnHTML(".wrapper__pageTitle section.xs-col-11", func(ratingContainer *colly.HTMLElement) {
 		var stars uint
 		ratingContainer.ForEach(".product--rating li", func(i int, _ *colly.HTMLElement) {
 			stars += 1
@@ -337,7 +690,7 @@ func (scrap *Scraper) GetPart(URL string) (*models.Part, error) {
 
 	var vendors []models.Vendor
 
-	scrap.Collector.OnHTML("#prices table tbody tr", func(vendor *colly.HTMLElement) {
+	col.OnHTML("#prices table tbody tr", func(vendor *colly.HTMLElement) {
 		if vendor.Attr("class") != "" {
 			return
 		}
@@ -346,20 +699,27 @@ func (scrap *Scraper) GetPart(URL string) (*models.Part, error) {
 
 		for k, v := range partClassMappings {
 			stringPrice := vendor.ChildText(v)
-			val, curr, _ := models.ParsePrice(stringPrice)
+			parsed := scrap.parsePrice(stringPrice, vendor.Request.URL)
+			val := parsed.Float()
 
 			switch k {
 			case "Base":
 				price.Base = val
+				price.Minor.Base = parsed.Amount
 			case "Shipping":
 				price.Shipping = val
+				price.Minor.Shipping = parsed.Amount
 			case "Tax":
 				price.Tax = val
-			case "Discounts":
+				price.Minor.Tax = parsed.Amount
+			case "Promo":
 				price.Discounts = val
+				price.Minor.Discounts = parsed.Amount
 			case "Total":
 				price.Total = val
-				price.Currency = curr
+				price.Minor.Total = parsed.Amount
+				price.Currency = parsed.Symbol
+				price.CurrencyCode = parsed.Currency
 				price.TotalString = stringPrice
 			}
 		}
@@ -368,14 +728,14 @@ func (scrap *Scraper) GetPart(URL string) (*models.Part, error) {
 			Name:    vendor.ChildAttr(".td__logo a img", "alt"),
 			Image:   linkURL("https:", vendor.ChildAttr(".td__logo a img", "src")),
 			InStock: vendor.ChildText(".td__availability") == "In stock",
-			URL:     linkURL("https://", vendor.Request.URL.Host, vendor.ChildAttr(".td__finalPrice a", "href")),
+			URL:     linkURL(vendor.Request.URL.Scheme+"://", vendor.Request.URL.Host, vendor.ChildAttr(".td__finalPrice a", "href")),
 			Price:   price,
 		})
 	})
 
 	var specs []models.PartSpec
 
-	scrap.Collector.OnHTML(".specs", func(specsContainer *colly.HTMLElement) {
+	col.OnHTML(".specs", func(specsContainer *colly.HTMLElement) {
 		if len(specs) > 0 {
 			return
 		}
@@ -400,24 +760,37 @@ func (scrap *Scraper) GetPart(URL string) (*models.Part, error) {
 
 	var productType string
 
-	scrap.Collector.OnHTML("section.breadcrumb ol.list-unstyled", func(breadcrumb *colly.HTMLElement) {
+	col.OnHTML("section.breadcrumb ol.list-unstyled", func(breadcrumb *colly.HTMLElement) {
 		productType = breadcrumb.ChildText("li a")
 	})
 
-	err := scrap.Collector.Visit(URL)
-	scrap.Collector.Wait()
+	err := scrap.visit(col, URL)
 
 	if err != nil {
 		return nil, err
 	}
+	if name == "" {
+		return nil, parseFailure(URL, errors.New("no part found on page"))
+	}
 
-	return &models.Part{
-		Type:    productType,
-		Name:    name,
-		Rating:  rating,
-		Specs:   specs,
-		Vendors: vendors,
-		Images:  images,
-		URL:     URL,
-	}, nil
+	part := &models.Part{
+		Type:       productType,
+		Name:       name,
+		Rating:     rating,
+		Specs:      specs,
+		TypedSpecs: models.ParseTypedSpecs(models.CategoryFromType(productType), specs),
+		Vendors:    vendors,
+		Images:     images,
+		URL:        URL,
+	}
+
+	scrap.mu.RLock()
+	callbacks := scrap.partCallbacks
+	scrap.mu.RUnlock()
+
+	for _, f := range callbacks {
+		f(part)
+	}
+
+	return part, nil
 }
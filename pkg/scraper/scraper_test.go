@@ -0,0 +1,316 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"github.com/Aquilabot/KreaPC-API/pkg/proxy"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newFixtureScraper(t *testing.T, handler http.Handler) *Scraper {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	scrap := NewScraper()
+	if err := scrap.SetBaseURL(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	return scrap
+}
+
+func TestParallelScrapesDoNotInterfere(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/product/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Split(strings.TrimPrefix(r.URL.Path, "/product/"), "/")[0]
+		fmt.Fprintf(w, `<html><body>
+<div class="wrapper__pageTitle"><section class="xs-col-11"><h1 class="pageTitle">Part %s</h1></section></div>
+<section class="breadcrumb"><ol class="list-unstyled"><li><a href="/products/">Type %s</a></li></ol></section>
+</body></html>`, id, id)
+	})
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		fmt.Fprintf(w, `<html><body><h1 class="pageTitle">Search</h1>
+<div class="search-results__pageContent"><div class="block"><ul class="list-unstyled">
+<li><p class="search_results--link"><a href="/product/abcd/%s">Result %s</a></p></li>
+</ul></div></div></body></html>`, q, q)
+	})
+
+	scrap := newFixtureScraper(t, mux)
+	scrap.RandomizeUserAgent()
+	scrap.UpdateHeaders("global", map[string]string{"Accept-Language": "en-US"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("p%04d", i)
+
+			part, err := scrap.GetPart("https://pcpartpicker.com/product/" + id + "/name")
+			if err != nil {
+				t.Errorf("GetPart(%s): %v", id, err)
+				return
+			}
+			if part.Name != "Part "+id || part.Type != "Type "+id {
+				t.Errorf("GetPart(%s) returned %q / %q", id, part.Name, part.Type)
+			}
+		}(i)
+
+		go func(i int) {
+			defer wg.Done()
+			query := fmt.Sprintf("q%04d", i)
+
+			results, err := scrap.SearchPCParts(query, "us", 1, 0)
+			if err != nil {
+				t.Errorf("SearchPCParts(%s): %v", query, err)
+				return
+			}
+			if len(results.Parts) != 1 || results.Parts[0].Name != "Result "+query {
+				t.Errorf("SearchPCParts(%s) returned %+v", query, results)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentGetPartCoalesces(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+
+	scrap := newFixtureScraper(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		fmt.Fprint(w, `<html><body>
+<div class="wrapper__pageTitle"><section class="xs-col-11"><h1 class="pageTitle">Shared Part</h1></section></div>
+</body></html>`)
+	}))
+
+	// Variants of the same product URL share the scrape
+	variants := []string{
+		"https://pcpartpicker.com/product/abcd/shared",
+		"http://pcpartpicker.com/product/abcd/other-slug",
+		"pcpartpicker.com/product/abcd/shared?utm_source=feed",
+		"https://pcpartpicker.com/product/abcd/",
+	}
+	const callers = 8
+	parts := make([]*models.Part, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			part, err := scrap.GetPart(variants[i%len(variants)])
+			if err != nil {
+				t.Errorf("GetPart: %v", err)
+				return
+			}
+			parts[i] = part
+		}(i)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for scrap.CoalesceStats()["part"].Coalesced < callers-1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("callers were not coalesced: %+v", scrap.CoalesceStats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if hits.Load() != 1 {
+		t.Errorf("expected a single request, got %d", hits.Load())
+	}
+	if stats := scrap.CoalesceStats()["part"]; stats.Scrapes != 1 || stats.Coalesced != callers-1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	for i, part := range parts {
+		if part == nil || part.Name != "Shared Part" {
+			t.Fatalf("caller %d got %+v", i, part)
+		}
+		for j := 0; j < i; j++ {
+			if parts[j] == part {
+				t.Errorf("callers %d and %d share the same part", i, j)
+			}
+		}
+	}
+}
+
+func TestChallengePageIsBlocked(t *testing.T) {
+	scrap := newFixtureScraper(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<html><head><title>Just a moment...</title></head>
+<body><script src="/cdn-cgi/challenge-platform/h/b/orchestrate/chl_page/v1"></script></body></html>`)
+	}))
+
+	var callbacks atomic.Int32
+	scrap.OnBlocked(func(blocked *BlockedError) {
+		callbacks.Add(1)
+	})
+
+	_, err := scrap.GetPart("https://pcpartpicker.com/product/abcd/name")
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) || blocked.Reason != "cloudflare challenge" {
+		t.Fatalf("expected a BlockedError, got %v", err)
+	}
+	if KindOf(err) != KindBlocked {
+		t.Errorf("KindOf(%v) = %s", err, KindOf(err))
+	}
+	if callbacks.Load() != 1 {
+		t.Errorf("OnBlocked callbacks called %d times", callbacks.Load())
+	}
+	if rate := scrap.BlockStats().Proxies["direct"]; rate.Requests != 1 || rate.Blocked != 1 {
+		t.Errorf("unexpected block rate %+v", rate)
+	}
+}
+
+func TestProxyPoolEjectsBlockedProxy(t *testing.T) {
+	var goodHits, badHits atomic.Int32
+	var reported []string
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		goodHits.Add(1)
+		fmt.Fprintf(w, `<html><body>
+<div class="wrapper__pageTitle"><section class="xs-col-11"><h1 class="pageTitle">Part via %s</h1></section></div>
+</body></html>`, r.URL.Host)
+	}))
+	t.Cleanup(good.Close)
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		badHits.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<html><head><title>Attention Required! | Cloudflare</title></head></html>`)
+	}))
+	t.Cleanup(bad.Close)
+
+	// The credentials of a proxy never leave the transport
+	badURL := strings.Replace(bad.URL, "://", "://user:secret@", 1)
+	pool, err := proxy.NewPool([]proxy.Entry{{URL: good.URL, Weight: 1}, {URL: badURL, Weight: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scrap := NewSiteScraper(utils.MustNewSite("http", "pcpartpicker.com", nil))
+	scrap.SetProxyPool(pool)
+	scrap.OnBlocked(func(blocked *BlockedError) {
+		reported = append(reported, blocked.Proxy)
+	})
+
+	blocked := 0
+	for i := 0; i < 10; i++ {
+		part, err := scrap.GetPart("http://pcpartpicker.com/product/abcd/name")
+		if KindOf(err) == KindBlocked {
+			blocked++
+			continue
+		}
+		if err != nil {
+			t.Fatalf("GetPart: %v", err)
+		}
+		if part.Name != "Part via pcpartpicker.com" {
+			t.Errorf("unexpected part name %q", part.Name)
+		}
+	}
+
+	if blocked != 1 || badHits.Load() != 1 {
+		t.Errorf("expected the blocked proxy to be used once, got %d blocks and %d hits", blocked, badHits.Load())
+	}
+	if goodHits.Load() != 10-int32(blocked) {
+		t.Errorf("expected the remaining requests through the healthy proxy, got %d", goodHits.Load())
+	}
+	for _, status := range pool.Stats() {
+		if status.Healthy != (status.URL == good.URL) {
+			t.Errorf("unexpected proxy status %+v", status)
+		}
+	}
+	badID := strings.Replace(bad.URL, "://", "://user:xxxxx@", 1)
+	if rate := scrap.BlockStats().Proxies[badID]; rate.Blocked != 1 {
+		t.Errorf("unexpected block rate for the blocked proxy %+v", scrap.BlockStats().Proxies)
+	}
+	if len(reported) != 1 || reported[0] != badID {
+		t.Errorf("expected the blocked proxy to be reported as %s, got %v", badID, reported)
+	}
+}
+
+func TestSavedPartListUsesTheAccountCookies(t *testing.T) {
+	page, err := os.ReadFile("testdata/list.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var public atomic.Int32
+	scrap := newFixtureScraper(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if session, err := r.Cookie("sessionid"); err != nil || session.Value != "account" || r.UserAgent() != "account agent" {
+			public.Add(1)
+			http.Error(w, "private list", http.StatusNotFound)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "sessionid", Value: "account", Path: "/", MaxAge: 3600})
+		w.Write(page)
+	}))
+
+	URL := "https://pcpartpicker.com/list/Kp7XjD"
+	jar, _ := cookiejar.New(nil)
+	u, _ := url.Parse(URL)
+	jar.SetCookies(u, []*http.Cookie{{Name: "sessionid", Value: "account", Path: "/"}})
+
+	list, err := scrap.GetSavedPartList(URL, Session{Jar: jar, UserAgent: "account agent"})
+	if err != nil {
+		t.Fatalf("GetSavedPartList: %v", err)
+	}
+	if len(list.Parts) == 0 {
+		t.Error("expected the parts of the saved list")
+	}
+
+	// The session stays out of the cookies of other scrapes
+	if _, err := scrap.GetPartList(URL); err == nil || public.Load() != 1 {
+		t.Errorf("expected the public scrape not to send the session, got %v", err)
+	}
+}
+
+func TestSavedPartListUsesTheSessionProxy(t *testing.T) {
+	page, err := os.ReadFile("testdata/list.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rotated, account atomic.Int32
+	rotation := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rotated.Add(1)
+		w.Write(page)
+	}))
+	t.Cleanup(rotation.Close)
+	accountProxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account.Add(1)
+		if r.URL.Path != "/user/builder/saved/Kp7XjD" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(page)
+	}))
+	t.Cleanup(accountProxy.Close)
+
+	pool, err := proxy.NewPool([]proxy.Entry{{URL: rotation.URL, Weight: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scrap := NewSiteScraper(utils.MustNewSite("http", "pcpartpicker.com", nil))
+	scrap.SetProxyPool(pool)
+
+	proxyURL, _ := url.Parse(accountProxy.URL)
+	jar, _ := cookiejar.New(nil)
+	if _, err := scrap.GetSavedPartList("http://pcpartpicker.com/user/builder/saved/#view=Kp7XjD", Session{Jar: jar, Proxy: proxyURL}); err != nil {
+		t.Fatalf("GetSavedPartList: %v", err)
+	}
+	if account.Load() != 1 || rotated.Load() != 0 {
+		t.Errorf("expected the list through the proxy of the session, got %d requests through it and %d through the pool", account.Load(), rotated.Load())
+	}
+}
@@ -0,0 +1,240 @@
+package tracker
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	watchesFile = "watches.json"
+	historyFile = "history.jsonl"
+)
+
+// HistoryLimit is the number of records of each part kept by a FileStore.
+const HistoryLimit = 1000
+
+// FileStore is a Store persisted in a directory. The watch list is rewritten on
+// every change and records are appended to a JSON lines file, which is compacted
+// to the last HistoryLimit records of each watched part when the store is opened
+// and whenever it has grown to twice its compacted size.
+type FileStore struct {
+	*MemoryStore
+
+	mu        sync.Mutex
+	dir       string
+	history   *os.File
+	lines     int
+	compactAt int
+}
+
+// NewFileStore opens the store kept in dir, creating the directory if needed,
+// and loads the watch list and the recorded history. Watches and records are
+// kept under the key of their URL.
+func NewFileStore(dir string, key KeyFunc) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	store := &FileStore{
+		MemoryStore: NewBoundedMemoryStore(HistoryLimit, key),
+		dir:         dir,
+	}
+
+	if err := store.loadWatches(); err != nil {
+		return nil, err
+	}
+	if err := store.loadHistory(); err != nil {
+		return nil, err
+	}
+	if err := store.openHistory(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (s *FileStore) openHistory() error {
+	history, err := os.OpenFile(filepath.Join(s.dir, historyFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	s.history = history
+	return nil
+}
+
+func (s *FileStore) loadWatches() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, watchesFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var watches []Watch
+	if err := json.Unmarshal(data, &watches); err != nil {
+		return err
+	}
+	for _, watch := range watches {
+		s.MemoryStore.AddWatch(watch)
+	}
+	return nil
+}
+
+func (s *FileStore) loadHistory() error {
+	file, err := os.Open(filepath.Join(s.dir, historyFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Records are streamed into the bounded memory store, which drops the old ones
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return err
+		}
+		if err := s.MemoryStore.AddRecords([]Record{record}); err != nil {
+			return err
+		}
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Records of parts no longer watched are dropped
+	s.MemoryStore.dropUnwatched()
+	s.lines = lines
+	if len(s.MemoryStore.records()) == lines {
+		s.compactAt = 2 * max(lines, HistoryLimit)
+		return nil
+	}
+	return s.compactHistory()
+}
+
+// compactHistory rewrites the history file with the records kept in memory,
+// in the order they were scraped.
+func (s *FileStore) compactHistory() error {
+	records := s.MemoryStore.records()
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+
+	path := filepath.Join(s.dir, historyFile)
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	s.lines = len(records)
+	s.compactAt = 2 * max(s.lines, HistoryLimit)
+	return nil
+}
+
+// saveWatches writes the watch list to a temporary file and renames it over the old one.
+func (s *FileStore) saveWatches() error {
+	watches, err := s.MemoryStore.Watches()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(watches, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, watchesFile)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *FileStore) AddWatch(watch Watch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.MemoryStore.AddWatch(watch); err != nil {
+		return err
+	}
+	return s.saveWatches()
+}
+
+func (s *FileStore) RemoveWatch(URL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.MemoryStore.RemoveWatch(URL); err != nil {
+		return err
+	}
+	return s.saveWatches()
+}
+
+func (s *FileStore) AddRecords(records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writer := bufio.NewWriter(s.history)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if err := s.MemoryStore.AddRecords(records); err != nil {
+		return err
+	}
+
+	s.lines += len(records)
+	if s.lines < s.compactAt {
+		return nil
+	}
+	if err := s.history.Close(); err != nil {
+		return err
+	}
+	// The history is reopened even when compacting fails, to keep recording
+	err := s.compactHistory()
+	if openErr := s.openHistory(); openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.history.Close()
+}
@@ -0,0 +1,140 @@
+package tracker
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a Store that only keeps its data in memory.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	watches map[string]Watch
+	history map[string][]Record
+	limit   int
+	key     KeyFunc
+}
+
+// NewMemoryStore creates a MemoryStore keeping watches and records under their URL.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		watches: map[string]Watch{},
+		history: map[string][]Record{},
+		key:     func(URL string) string { return URL },
+	}
+}
+
+// NewBoundedMemoryStore creates a MemoryStore keeping only the last limit
+// records of each part, under the key of their URL.
+func NewBoundedMemoryStore(limit int, key KeyFunc) *MemoryStore {
+	store := NewMemoryStore()
+	store.limit = limit
+	if key != nil {
+		store.key = key
+	}
+	return store
+}
+
+func (s *MemoryStore) AddWatch(watch Watch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watches[s.key(watch.URL)] = watch
+	return nil
+}
+
+func (s *MemoryStore) RemoveWatch(URL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.key(URL)
+	if _, ok := s.watches[key]; !ok {
+		return ErrNotWatched
+	}
+	delete(s.watches, key)
+	return nil
+}
+
+func (s *MemoryStore) Watched(URL string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.watches[s.key(URL)]
+	return ok, nil
+}
+
+// Watches returns the watch list sorted by the time the parts were added.
+func (s *MemoryStore) Watches() ([]Watch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	watches := make([]Watch, 0, len(s.watches))
+	for _, watch := range s.watches {
+		watches = append(watches, watch)
+	}
+	sort.Slice(watches, func(i, j int) bool {
+		return watches[i].AddedAt.Before(watches[j].AddedAt)
+	})
+	return watches, nil
+}
+
+func (s *MemoryStore) AddRecords(records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range records {
+		key := s.key(record.URL)
+		history := append(s.history[key], record)
+		// Trim twice the limit at once rather than copying on every record
+		if s.limit > 0 && len(history) >= 2*s.limit {
+			history = append([]Record{}, history[len(history)-s.limit:]...)
+		}
+		s.history[key] = history
+	}
+	return nil
+}
+
+// History returns the records of the part in the order they were added, the
+// last ones only when the store is bounded.
+func (s *MemoryStore) History(URL string) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.last(s.key(URL)), nil
+}
+
+// records returns the records of every watched part, the last ones only when
+// the store is bounded.
+func (s *MemoryStore) records() []Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []Record
+	for key := range s.watches {
+		records = append(records, s.last(key)...)
+	}
+	return records
+}
+
+// dropUnwatched drops the records of the parts that aren't watched.
+func (s *MemoryStore) dropUnwatched() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.history {
+		if _, ok := s.watches[key]; !ok {
+			delete(s.history, key)
+		}
+	}
+}
+
+func (s *MemoryStore) last(key string) []Record {
+	history := s.history[key]
+	if s.limit > 0 && len(history) > s.limit {
+		history = history[len(history)-s.limit:]
+	}
+	return append([]Record{}, history...)
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
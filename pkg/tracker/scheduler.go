@@ -0,0 +1,156 @@
+package tracker
+
+import (
+	"errors"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/gofiber/fiber/v2/log"
+	"sync"
+	"time"
+)
+
+// ErrInvalidInterval is returned for a re-scrape interval that isn't positive.
+var ErrInvalidInterval = errors.New("watch interval must be positive")
+
+// PartFetcher scrapes a single part, scraper.Scraper implements it.
+type PartFetcher interface {
+	GetPart(URL string) (*models.Part, error)
+}
+
+// Scheduler re-scrapes the watched parts of a Store once their interval has passed.
+// It only fetches the parts: recording them is done by the callback returned by
+// Recorder, registered on the scraper so scrapes made for clients are recorded too.
+type Scheduler struct {
+	fetcher  PartFetcher
+	store    Store
+	interval time.Duration
+	tick     time.Duration
+
+	mu      sync.Mutex
+	lastRun map[string]time.Time
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewScheduler creates a Scheduler re-scraping watched parts every interval,
+// unless a watch sets its own interval.
+func NewScheduler(fetcher PartFetcher, store Store, interval time.Duration) (*Scheduler, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+	tick := time.Minute
+	if interval < tick {
+		tick = interval
+	}
+
+	return &Scheduler{
+		fetcher:  fetcher,
+		store:    store,
+		interval: interval,
+		tick:     tick,
+		lastRun:  map[string]time.Time{},
+	}, nil
+}
+
+// Start checks for due watches in the background until Stop is called.
+func (s *Scheduler) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.tick)
+		defer ticker.Stop()
+
+		s.RunDue(time.Now())
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-ticker.C:
+				s.RunDue(now)
+			}
+		}
+	}()
+}
+
+// Stop stops the background loop and waits for the current run to finish.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// RunDue scrapes every watched part whose interval has passed at the given time.
+// Parts not scraped since the scheduler was created are due once their interval
+// has passed since their last record, so a restart doesn't re-scrape them all.
+func (s *Scheduler) RunDue(now time.Time) {
+	watches, err := s.store.Watches()
+	if err != nil {
+		log.Errorf("Could not load watched parts: %v", err)
+		return
+	}
+
+	for _, watch := range watches {
+		interval := watch.Interval
+		if interval <= 0 {
+			interval = s.interval
+		}
+
+		lastRun, ok := s.lastScrape(watch.URL)
+		if ok && now.Sub(lastRun) < interval {
+			continue
+		}
+
+		if _, err := s.fetcher.GetPart(watch.URL); err != nil {
+			log.Warnf("Could not scrape watched part %s: %v", watch.URL, err)
+		}
+
+		s.mu.Lock()
+		s.lastRun[watch.URL] = now
+		s.mu.Unlock()
+	}
+}
+
+// lastScrape returns the time the part was last scraped by the scheduler or,
+// before its first run, the time of its last record.
+func (s *Scheduler) lastScrape(URL string) (time.Time, bool) {
+	s.mu.Lock()
+	lastRun, ok := s.lastRun[URL]
+	s.mu.Unlock()
+	if ok {
+		return lastRun, true
+	}
+
+	records, err := s.store.History(URL)
+	if err != nil {
+		log.Warnf("Could not load the history of watched part %s: %v", URL, err)
+		return time.Time{}, false
+	}
+	if len(records) == 0 {
+		return time.Time{}, false
+	}
+
+	lastRun = records[len(records)-1].Time
+	s.mu.Lock()
+	s.lastRun[URL] = lastRun
+	s.mu.Unlock()
+	return lastRun, true
+}
+
+// Recorder returns a callback recording the vendors of scraped parts in the
+// store, when they are watched.
+func Recorder(store Store) func(part *models.Part) {
+	return func(part *models.Part) {
+		watched, err := store.Watched(part.URL)
+		if err != nil {
+			log.Errorf("Could not check whether %s is watched: %v", part.URL, err)
+			return
+		}
+		if !watched {
+			return
+		}
+		if err := store.AddRecords(RecordsFromPart(part, time.Now().UTC())); err != nil {
+			log.Errorf("Could not record prices of %s: %v", part.URL, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package tracker
+
+import (
+	"errors"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"time"
+)
+
+var ErrNotWatched = errors.New("part is not watched")
+
+// Watch is a product URL that is re-scraped periodically.
+// A zero Interval uses the interval of the Scheduler.
+type Watch struct {
+	URL      string
+	Interval time.Duration
+	AddedAt  time.Time
+}
+
+// Record is the price and stock status of a part at a vendor when it was scraped.
+type Record struct {
+	URL     string
+	Name    string
+	Vendor  string
+	Price   models.Price
+	InStock bool
+	Time    time.Time
+}
+
+// KeyFunc maps the URLs of a part to the key its watch and records are kept
+// under, so that variants of the same product URL share them.
+type KeyFunc func(URL string) string
+
+// Store keeps the watch list and the price records of watched parts.
+type Store interface {
+	AddWatch(watch Watch) error
+	RemoveWatch(URL string) error
+	Watches() ([]Watch, error)
+	Watched(URL string) (bool, error)
+	AddRecords(records []Record) error
+	History(URL string) ([]Record, error)
+	Close() error
+}
+
+// RecordsFromPart returns one record per vendor of the part, scraped at the given time.
+func RecordsFromPart(part *models.Part, at time.Time) []Record {
+	records := make([]Record, 0, len(part.Vendors))
+	for _, vendor := range part.Vendors {
+		records = append(records, Record{
+			URL:     part.URL,
+			Name:    part.Name,
+			Vendor:  vendor.Name,
+			Price:   vendor.Price,
+			InStock: vendor.InStock,
+			Time:    at,
+		})
+	}
+	return records
+}
@@ -0,0 +1,233 @@
+package tracker
+
+import (
+	"bufio"
+	"errors"
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"github.com/Aquilabot/KreaPC-API/internal/utils"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testSite = utils.MustNewSite("https", "pcpartpicker.com", nil)
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, testSite.ProductKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	watches := []Watch{
+		{URL: "https://pcpartpicker.com/product/Yg3mP6/cpu", AddedAt: at},
+		{URL: "https://pcpartpicker.com/product/DsyH99/fan", Interval: time.Hour, AddedAt: at.Add(time.Minute)},
+	}
+	for _, watch := range append(watches, Watch{URL: "https://pcpartpicker.com/product/aaaa11/ssd", AddedAt: at}) {
+		if err := store.AddWatch(watch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.RemoveWatch("http://pcpartpicker.com/product/aaaa11/other-slug"); err != nil {
+		t.Fatal(err)
+	}
+	records := []Record{
+		{URL: watches[0].URL, Name: "CPU", Vendor: "Amazon", Price: models.Price{Total: 329.99, Currency: "$"}, InStock: true, Time: at},
+		{URL: watches[0].URL, Name: "CPU", Vendor: "Newegg", Price: models.Price{Total: 339.99, Currency: "$"}, Time: at},
+	}
+	if err := store.AddRecords(records); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileStore(dir, testSite.ProductKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	gotWatches, err := reopened.Watches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.EqualFunc(gotWatches, watches, func(a, b Watch) bool {
+		return a.URL == b.URL && a.Interval == b.Interval && a.AddedAt.Equal(b.AddedAt)
+	}) {
+		t.Errorf("unexpected watches %+v", gotWatches)
+	}
+	// Variants of the product URL share the history
+	history, err := reopened.History("pcpartpicker.com/product/Yg3mP6/?utm_source=feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.EqualFunc(history, records, func(a, b Record) bool {
+		return a.URL == b.URL && a.Vendor == b.Vendor && a.Price == b.Price && a.InStock == b.InStock && a.Time.Equal(b.Time)
+	}) {
+		t.Errorf("unexpected history %+v", history)
+	}
+	if err := reopened.RemoveWatch("https://pcpartpicker.com/product/zzzz99/none"); !errors.Is(err, ErrNotWatched) {
+		t.Errorf("expected a missing watch, got %v", err)
+	}
+}
+
+func TestFileStoreCompactsHistory(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, URL := range []string{"cpu", "fan", "ssd"} {
+		if err := store.AddWatch(Watch{URL: URL, AddedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var records []Record
+	for i := 0; i < HistoryLimit+10; i++ {
+		records = append(records, Record{URL: "cpu", Vendor: "Amazon", Time: at.Add(time.Duration(i) * time.Minute)})
+	}
+	records = append(records, Record{URL: "fan", Vendor: "Amazon", Time: at}, Record{URL: "ssd", Vendor: "Amazon", Time: at})
+	if err := store.AddRecords(records); err != nil {
+		t.Fatal(err)
+	}
+	// The records of parts no longer watched are dropped
+	if err := store.RemoveWatch("ssd"); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	reopened, err := NewFileStore(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	history, _ := reopened.History("cpu")
+	if len(history) != HistoryLimit || !history[0].Time.Equal(records[10].Time) {
+		t.Errorf("expected the last %d records, got %d from %s", HistoryLimit, len(history), history[0].Time)
+	}
+	if history, _ := reopened.History("fan"); len(history) != 1 {
+		t.Errorf("expected the records of other parts to be kept, got %d", len(history))
+	}
+	if history, _ := reopened.History("ssd"); len(history) != 0 {
+		t.Errorf("expected the records of an unwatched part to be dropped, got %d", len(history))
+	}
+	if lines := countLines(t, filepath.Join(dir, historyFile)); lines != HistoryLimit+1 {
+		t.Errorf("expected the history file to be compacted to %d lines, got %d", HistoryLimit+1, lines)
+	}
+}
+
+func TestFileStoreCompactsHistoryWhileOpen(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := store.AddWatch(Watch{URL: "cpu", AddedAt: at}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3*HistoryLimit; i++ {
+		if err := store.AddRecords([]Record{{URL: "cpu", Vendor: "Amazon", Time: at.Add(time.Duration(i) * time.Minute)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if lines := countLines(t, filepath.Join(dir, historyFile)); lines > 2*HistoryLimit {
+		t.Errorf("expected the history file to be compacted while open, got %d lines", lines)
+	}
+	history, _ := store.History("cpu")
+	if len(history) != HistoryLimit || !history[len(history)-1].Time.Equal(at.Add((3*HistoryLimit-1)*time.Minute)) {
+		t.Errorf("expected the last %d records, got %d", HistoryLimit, len(history))
+	}
+}
+
+func TestRecorderOnlyRecordsWatchedParts(t *testing.T) {
+	store := NewBoundedMemoryStore(HistoryLimit, testSite.ProductKey)
+	store.AddWatch(Watch{URL: "https://pcpartpicker.com/product/Yg3mP6/cpu"})
+	record := Recorder(store)
+
+	vendors := []models.Vendor{{Name: "Amazon", Price: models.Price{Total: 329.99}}}
+	record(&models.Part{URL: "https://pcpartpicker.com/product/Yg3mP6/cpu?ref=search", Vendors: vendors})
+	record(&models.Part{URL: "https://pcpartpicker.com/product/DsyH99/fan", Vendors: vendors})
+
+	if history, _ := store.History("https://pcpartpicker.com/product/Yg3mP6/"); len(history) != 1 {
+		t.Errorf("expected the watched part to be recorded, got %d records", len(history))
+	}
+	if history, _ := store.History("https://pcpartpicker.com/product/DsyH99/fan"); len(history) != 0 {
+		t.Errorf("expected the unwatched part not to be recorded, got %d records", len(history))
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines
+}
+
+type fakeFetcher struct {
+	fetched []string
+}
+
+func (f *fakeFetcher) GetPart(URL string) (*models.Part, error) {
+	f.fetched = append(f.fetched, URL)
+	return &models.Part{URL: URL}, nil
+}
+
+func TestSchedulerRunDue(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store.AddWatch(Watch{URL: "default", AddedAt: start})
+	store.AddWatch(Watch{URL: "hourly", Interval: time.Hour, AddedAt: start.Add(time.Second)})
+	// Scraped before a restart, so not due until its interval has passed
+	store.AddWatch(Watch{URL: "recorded", Interval: time.Hour, AddedAt: start.Add(2 * time.Second)})
+	store.AddRecords([]Record{{URL: "recorded", Time: start.Add(-30 * time.Minute)}})
+
+	fetcher := &fakeFetcher{}
+	scheduler, err := NewScheduler(fetcher, store, 6*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, run := range []struct {
+		after time.Duration
+		want  []string
+	}{
+		{0, []string{"default", "hourly"}},
+		{10 * time.Minute, nil},
+		{30 * time.Minute, []string{"recorded"}},
+		{time.Hour, []string{"hourly"}},
+		{6 * time.Hour, []string{"default", "hourly", "recorded"}},
+	} {
+		fetcher.fetched = nil
+		scheduler.RunDue(start.Add(run.after))
+		if !slices.Equal(fetcher.fetched, run.want) {
+			t.Errorf("after %s: expected %v to be scraped, got %v", run.after, run.want, fetcher.fetched)
+		}
+	}
+}
+
+func TestNewSchedulerRejectsInvalidIntervals(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Minute} {
+		if _, err := NewScheduler(&fakeFetcher{}, NewMemoryStore(), interval); !errors.Is(err, ErrInvalidInterval) {
+			t.Errorf("%s: expected an invalid interval, got %v", interval, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package wattage
+
+import (
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"math"
+)
+
+// psuSizes are the common power supply wattages recommendations are rounded up to.
+var psuSizes = []float64{300, 350, 400, 450, 500, 550, 600, 650, 750, 850, 1000, 1200, 1300, 1600}
+
+// Estimator estimates the power draw of a build from the typed specs of its parts.
+// Parts without a TDP in their specs fall back to the default draw of their category.
+type Estimator struct {
+	// Headroom is the share added on top of the estimated draw when recommending
+	// a power supply, 0.3 recommends a power supply 30% above the draw.
+	Headroom float64
+
+	MotherboardDraw  float64
+	MemoryModuleDraw float64
+	SSDDraw          float64
+	HDDDraw          float64
+	FanDraw          float64
+	AirCoolerDraw    float64
+	WaterCoolerDraw  float64
+	DefaultCPUDraw   float64
+	DefaultGPUDraw   float64
+}
+
+// PartDraw is the estimated draw of a single part.
+type PartDraw struct {
+	Type  string
+	Name  string
+	Watts float64
+}
+
+// Estimate is the estimated draw of a build with a breakdown per part.
+type Estimate struct {
+	Total          float64
+	Parts          []PartDraw
+	Headroom       float64
+	RecommendedPSU float64
+}
+
+// NewEstimator returns an Estimator with typical draws for each kind of part and a 30% headroom.
+func NewEstimator() *Estimator {
+	return &Estimator{
+		Headroom:         0.3,
+		MotherboardDraw:  50,
+		MemoryModuleDraw: 5,
+		SSDDraw:          6,
+		HDDDraw:          9,
+		FanDraw:          3,
+		AirCoolerDraw:    3,
+		WaterCoolerDraw:  12,
+		DefaultCPUDraw:   95,
+		DefaultGPUDraw:   200,
+	}
+}
+
+// Estimate computes the draw of every part and recommends a power supply size.
+// A part appearing several times counts once per occurrence.
+func (e *Estimator) Estimate(parts []models.Part) Estimate {
+	estimate := Estimate{
+		Parts:    []PartDraw{},
+		Headroom: e.Headroom,
+	}
+
+	for _, part := range parts {
+		watts := e.partDraw(part)
+		if watts == 0 {
+			continue
+		}
+		estimate.Parts = append(estimate.Parts, PartDraw{
+			Type:  part.Type,
+			Name:  part.Name,
+			Watts: watts,
+		})
+		estimate.Total += watts
+	}
+
+	estimate.RecommendedPSU = RecommendPSU(estimate.Total, e.Headroom)
+	return estimate
+}
+
+func (e *Estimator) partDraw(part models.Part) float64 {
+	typed := models.PartTypedSpecs(part)
+
+	switch models.CategoryFromType(part.Type) {
+	case models.CategoryCPU:
+		if typed.CPU != nil && typed.CPU.TDP.Value > 0 {
+			return typed.CPU.TDP.Value
+		}
+		return e.DefaultCPUDraw
+	case models.CategoryVideoCard:
+		if typed.GPU != nil && typed.GPU.TDP.Value > 0 {
+			return typed.GPU.TDP.Value
+		}
+		return e.DefaultGPUDraw
+	case models.CategoryMotherboard:
+		return e.MotherboardDraw
+	case models.CategoryMemory:
+		modules := 1
+		if typed.Memory != nil && typed.Memory.Modules > 0 {
+			modules = typed.Memory.Modules
+		}
+		return float64(modules) * e.MemoryModuleDraw
+	case models.CategoryInternalHardDrive:
+		if typed.Storage != nil && !typed.Storage.SSD {
+			return e.HDDDraw
+		}
+		return e.SSDDraw
+	case models.CategoryCaseFan:
+		return float64(fanCount(part.Specs)) * e.FanDraw
+	case models.CategoryCPUCooler:
+		if typed.Cooler != nil && typed.Cooler.WaterCooled {
+			return e.WaterCoolerDraw
+		}
+		if typed.Cooler != nil && typed.Cooler.Fanless {
+			return 0
+		}
+		return e.AirCoolerDraw
+	}
+	return 0
+}
+
+// fanCount returns the number of fans in a case fan pack, which is one unless the specs say otherwise.
+func fanCount(specs []models.PartSpec) int {
+	for _, spec := range specs {
+		if spec.Name == "Quantity" && len(spec.Values) > 0 {
+			if count := int(models.ParseQuantity(spec.Values[0]).Value); count > 0 {
+				return count
+			}
+		}
+	}
+	return 1
+}
+
+// RecommendPSU adds the headroom to the draw and rounds it up to a common power supply wattage.
+func RecommendPSU(draw float64, headroom float64) float64 {
+	needed := draw * (1 + headroom)
+	for _, size := range psuSizes {
+		if size >= needed {
+			return size
+		}
+	}
+	return math.Ceil(needed/100) * 100
+}
@@ -0,0 +1,107 @@
+package wattage
+
+import (
+	"github.com/Aquilabot/KreaPC-API/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	parts := []models.Part{
+		{Type: "CPU", Name: "CPU", TypedSpecs: models.TypedSpecs{CPU: &models.CPUSpecs{TDP: models.Quantity{Value: 120, Unit: "W"}}}},
+		{Type: "Video Card", Name: "GPU", Specs: []models.PartSpec{{Name: "TDP", Values: []string{"263 W"}}}},
+		{Type: "Motherboard", Name: "Motherboard"},
+		{Type: "Memory", Name: "Memory", Specs: []models.PartSpec{{Name: "Modules", Values: []string{"2 x 16GB"}}}},
+		{Type: "Storage", Name: "SSD", Specs: []models.PartSpec{{Name: "Type", Values: []string{"SSD"}}}},
+		{Type: "Storage", Name: "HDD", Specs: []models.PartSpec{{Name: "Type", Values: []string{"7200 RPM"}}}},
+		{Type: "Case Fan", Name: "Fan Pack", Specs: []models.PartSpec{{Name: "Quantity", Values: []string{"3 Pack"}}}},
+		{Type: "Case Fan", Name: "Fan"},
+		{Type: "CPU Cooler", Name: "AIO", Specs: []models.PartSpec{{Name: "Water Cooled", Values: []string{"360 mm"}}}},
+		{Type: "Case", Name: "Case"},
+	}
+
+	estimate := NewEstimator().Estimate(parts)
+
+	wantParts := []PartDraw{
+		{Type: "CPU", Name: "CPU", Watts: 120},
+		{Type: "Video Card", Name: "GPU", Watts: 263},
+		{Type: "Motherboard", Name: "Motherboard", Watts: 50},
+		{Type: "Memory", Name: "Memory", Watts: 10},
+		{Type: "Storage", Name: "SSD", Watts: 6},
+		{Type: "Storage", Name: "HDD", Watts: 9},
+		{Type: "Case Fan", Name: "Fan Pack", Watts: 9},
+		{Type: "Case Fan", Name: "Fan", Watts: 3},
+		{Type: "CPU Cooler", Name: "AIO", Watts: 12},
+	}
+	if !reflect.DeepEqual(estimate.Parts, wantParts) {
+		t.Errorf("unexpected part draws\n got %+v\nwant %+v", estimate.Parts, wantParts)
+	}
+	// 482W with 30% headroom is 626.6W
+	if estimate.Total != 482 || estimate.Headroom != 0.3 || estimate.RecommendedPSU != 650 {
+		t.Errorf("unexpected estimate %.1fW, %.1f headroom, %.0fW recommended", estimate.Total, estimate.Headroom, estimate.RecommendedPSU)
+	}
+}
+
+func TestEstimateDefaultDraws(t *testing.T) {
+	estimator := NewEstimator()
+	estimator.DefaultCPUDraw = 65
+	estimator.Headroom = 0.5
+
+	estimate := estimator.Estimate([]models.Part{
+		{Type: "CPU", Name: "CPU"},
+		{Type: "Video Card", Name: "GPU", Specs: []models.PartSpec{{Name: "TDP", Values: []string{"Unknown"}}}},
+		{Type: "Memory", Name: "Memory"},
+		{Type: "CPU Cooler", Name: "Passive", Specs: []models.PartSpec{{Name: "Fanless", Values: []string{"Yes"}}}},
+		{Type: "CPU Cooler", Name: "Air"},
+		{Type: "CPU", Name: "CPU"},
+	})
+
+	wantParts := []PartDraw{
+		{Type: "CPU", Name: "CPU", Watts: 65},
+		{Type: "Video Card", Name: "GPU", Watts: 200},
+		{Type: "Memory", Name: "Memory", Watts: 5},
+		{Type: "CPU Cooler", Name: "Air", Watts: 3},
+		{Type: "CPU", Name: "CPU", Watts: 65},
+	}
+	if !reflect.DeepEqual(estimate.Parts, wantParts) {
+		t.Errorf("unexpected part draws\n got %+v\nwant %+v", estimate.Parts, wantParts)
+	}
+	// 338W with 50% headroom is 507W
+	if estimate.Total != 338 || estimate.RecommendedPSU != 550 {
+		t.Errorf("unexpected estimate %.1fW, %.0fW recommended", estimate.Total, estimate.RecommendedPSU)
+	}
+}
+
+func TestEstimateWithoutParts(t *testing.T) {
+	estimate := NewEstimator().Estimate(nil)
+	if estimate.Total != 0 || len(estimate.Parts) != 0 || estimate.RecommendedPSU != 300 {
+		t.Errorf("unexpected estimate %+v", estimate)
+	}
+}
+
+func TestRecommendPSU(t *testing.T) {
+	tests := []struct {
+		draw     float64
+		headroom float64
+		want     float64
+	}{
+		{0, 0.3, 300},
+		{200, 0.3, 300},
+		{250, 0.3, 350},
+		{500, 0, 500},
+		{500, 0.3, 650},
+		{576, 0.3, 750},
+		{577, 0.3, 850},
+		{653, 0.3, 850},
+		{1000, 0.2, 1200},
+		{1000, 0.3, 1300},
+		{1300, 0.3, 1700},
+		{1600, 0.25, 2000},
+	}
+
+	for _, test := range tests {
+		if got := RecommendPSU(test.draw, test.headroom); got != test.want {
+			t.Errorf("RecommendPSU(%g, %g) = %g, want %g", test.draw, test.headroom, got, test.want)
+		}
+	}
+}